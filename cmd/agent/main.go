@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yunjin08/logscale/internal/agent"
+)
+
+func main() {
+	var (
+		paths    = flag.String("paths", envOr("AGENT_PATHS", ""), "comma-separated glob patterns of files to tail")
+		service  = flag.String("service", envOr("AGENT_SERVICE", ""), "service name attached to every log")
+		endpoint = flag.String("endpoint", envOr("LOGSCALE_ENDPOINT", "http://localhost:8080"), "LogScale API base URL")
+		apiKey   = flag.String("api-key", envOr("LOGSCALE_API_KEY", ""), "API key with the logs:write scope")
+
+		checkpointFile = flag.String("checkpoint", envOr("AGENT_CHECKPOINT_FILE", "/var/lib/logscale-agent/checkpoint.json"), "file storing read offsets")
+		bufferDir      = flag.String("buffer-dir", envOr("AGENT_BUFFER_DIR", "/var/lib/logscale-agent/buffer"), "directory buffering batches while the API is unreachable")
+		maxBufferMB    = flag.Int64("max-buffer-mb", int64(envInt("AGENT_MAX_BUFFER_MB", 256)), "maximum size of the on-disk buffer in MB")
+
+		batchSize     = flag.Int("batch-size", envInt("AGENT_BATCH_SIZE", 500), "maximum logs per request")
+		flushInterval = flag.Duration("flush-interval", envDuration("AGENT_FLUSH_INTERVAL", 5*time.Second), "maximum time a log waits before being sent")
+		pollInterval  = flag.Duration("poll-interval", envDuration("AGENT_POLL_INTERVAL", time.Second), "how often files are checked for new lines")
+
+		multilinePattern = flag.String("multiline-pattern", envOr("AGENT_MULTILINE_PATTERN", agent.DefaultContinuationPattern), "regexp matching lines that continue the previous record (empty disables)")
+		multilineTimeout = flag.Duration("multiline-timeout", envDuration("AGENT_MULTILINE_TIMEOUT", 2*time.Second), "how long to wait for further continuation lines")
+
+		maxRetries   = flag.Int("max-retries", envInt("AGENT_MAX_RETRIES", 5), "delivery attempts before a batch is buffered")
+		retryBackoff = flag.Duration("retry-backoff", envDuration("AGENT_RETRY_BACKOFF", time.Second), "initial delay between delivery attempts")
+	)
+	flag.Parse()
+
+	a, err := agent.New(agent.Config{
+		Paths:               splitList(*paths),
+		Service:             *service,
+		Endpoint:            *endpoint,
+		APIKey:              *apiKey,
+		CheckpointFile:      *checkpointFile,
+		BufferDir:           *bufferDir,
+		MaxBufferBytes:      *maxBufferMB << 20,
+		BatchSize:           *batchSize,
+		FlushInterval:       *flushInterval,
+		PollInterval:        *pollInterval,
+		MultilineTimeout:    *multilineTimeout,
+		ContinuationPattern: *multilinePattern,
+		MaxRetries:          *maxRetries,
+		RetryBackoff:        *retryBackoff,
+	})
+	if err != nil {
+		log.Printf("error: %v", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("LogScale agent shipping %s to %s", *paths, *endpoint)
+	if err := a.Run(ctx); err != nil {
+		log.Printf("error: agent stopped: %v", err)
+		os.Exit(1)
+	}
+	log.Println("Agent stopped")
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
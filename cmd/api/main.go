@@ -2,71 +2,420 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/analytics"
+	"github.com/yunjin08/logscale/internal/audit"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/config"
+	"github.com/yunjin08/logscale/internal/health"
+	"github.com/yunjin08/logscale/internal/ingest"
+	"github.com/yunjin08/logscale/internal/input/fluentforward"
+	"github.com/yunjin08/logscale/internal/input/gelf"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/pipeline"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/internal/ratelimit"
+	"github.com/yunjin08/logscale/internal/redact"
 	"github.com/yunjin08/logscale/internal/stream"
+	"github.com/yunjin08/logscale/internal/tlsconfig"
+	"github.com/yunjin08/logscale/internal/tracing"
+	"github.com/yunjin08/logscale/middleware"
 	"github.com/yunjin08/logscale/routes"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
-	err := godotenv.Load()
+	envErr := godotenv.Load()
+
+	cfg, err := config.Load("api", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Printf("warning: failed to load .env file: %v", err)
+		log.Printf("error: %v", err)
+		os.Exit(1)
 	}
 
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Printf("error: DATABASE_URL is not set")
+	if err := logging.Setup(os.Stderr, "logscale-api", cfg.Logging); err != nil {
+		log.Printf("error: %v", err)
 		os.Exit(1)
 	}
+	if envErr != nil {
+		slog.Debug("no .env file loaded", logging.Err(envErr))
+	}
 
-	db, err := pgxpool.New(context.Background(), databaseURL)
+	// SIGINT and SIGTERM start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, "logscale-api", cfg.Tracing)
 	if err != nil {
-		log.Printf("error: failed to connect to database: %v", err)
+		slog.Error("failed to set up tracing", logging.Err(err))
 		os.Exit(1)
 	}
 
-	// Initialize Redis Stream service (optional)
-	var streamSvc *stream.RedisStreamService
-	redisURL := os.Getenv("REDIS_URL")
+	poolConfig, err := cfg.Database.PoolConfig()
+	if err != nil {
+		slog.Error("invalid database config", logging.Err(err))
+		os.Exit(1)
+	}
 
-	if redisURL != "" {
-		streamName := os.Getenv("STREAM_NAME")
-		if streamName == "" {
-			streamName = "logscale:logs"
-		}
+	db, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		slog.Error("failed to connect to database", logging.Err(err))
+		os.Exit(1)
+	}
 
-		streamSvc, err = stream.NewRedisStreamService(redisURL, streamName)
+	// Initialize Redis Stream service (optional)
+	var streamSvc *stream.RedisStreamService
+	if cfg.Redis.URL != "" {
+		streamSvc, err = newStreamService(cfg)
 		if err != nil {
-			log.Printf("warning: failed to initialize Redis stream service: %v", err)
-			log.Printf("continuing without Redis stream functionality")
+			slog.Warn("failed to initialize Redis stream service, continuing without it", logging.Err(err))
 			streamSvc = nil // Reset to nil on failure
 		} else {
-			log.Printf("Redis stream service initialized with stream: %s", streamName)
+			slog.Info("Redis stream service initialized", logging.KeyStream, cfg.Stream.StreamName)
 		}
 	} else {
-		log.Printf("REDIS_URL not provided, running without Redis stream functionality")
+		slog.Info("REDIS_URL not provided, running without Redis stream functionality")
 	}
 
-	// Initialize handlers
-	logHandler := v1.NewLogHandler(db, streamSvc)
+	// Initialize the shared ingestion pipeline
+	levelPolicy, err := ingest.ParseLevelPolicy(cfg.Ingest.UnknownLevels)
+	if err != nil {
+		slog.Error("invalid ingest config", logging.Err(err))
+		db.Close()
+		os.Exit(1)
+	}
+
+	pipelines, err := newPipeline(cfg)
+	if err != nil {
+		slog.Error("failed to build ingest pipelines", logging.Err(err))
+		db.Close()
+		os.Exit(1)
+	}
+
+	redactor, err := newRedactor(cfg)
+	if err != nil {
+		slog.Error("failed to build redaction rules", logging.Err(err))
+		db.Close()
+		os.Exit(1)
+	}
+
+	quotaSvc := quota.NewService(helpers.NewQuotaHelper(db))
+	ingestSvc := ingest.NewService(helpers.NewLogHelper(db), streamSvc, ingest.Options{
+		UnknownLevels: levelPolicy,
+		Quotas:        quotaSvc,
+		Pipeline:      pipelines,
+		Redactor:      redactor,
+	})
+
+	// Start the Fluent Forward input (optional). inputs tracks the input
+	// servers, which stop when ctx is done and are waited for on shutdown.
+	var inputs sync.WaitGroup
+	if input := cfg.Inputs.FluentForward; input.Addr != "" {
+		forwardSrv := fluentforward.NewServer(input.Addr, input.Tenant, ingestSvc)
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			if err := forwardSrv.ListenAndServe(ctx); err != nil {
+				slog.Error("fluent forward input stopped", logging.Err(err))
+			}
+		}()
+	}
+
+	// Start the GELF UDP input (optional)
+	if input := cfg.Inputs.GELF; input.Addr != "" {
+		gelfSrv := gelf.NewServer(input.Addr, input.Tenant, ingestSvc)
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			if err := gelfSrv.ListenAndServe(ctx); err != nil {
+				slog.Error("gelf input stopped", logging.Err(err))
+			}
+		}()
+	}
+
+	// Initialize API key authentication
+	if !cfg.Auth.Enabled {
+		slog.Warn("auth disabled, API endpoints are unauthenticated")
+	}
+
+	authSvc := auth.NewService(helpers.NewAPIKeyHelper(db), helpers.NewRoleHelper(db), cfg.Auth.AdminAPIKey, cfg.Auth.Enabled)
+
+	// Initialize ingestion rate limits (shared through Redis when available)
+	limitConfig := cfg.RateLimits
+	var limitBackend *ratelimit.RedisBackend
+	if cfg.Redis.URL != "" {
+		limitBackend, err = newRateLimitBackend(cfg)
+		if err != nil {
+			slog.Warn("failed to initialize Redis rate limiting, continuing with per-replica rate limits", logging.Err(err))
+			limitBackend = nil
+		}
+	}
+	limiter := ratelimit.New(limitConfig, limitBackend)
+
+	// Reload rate limits, pipelines and redaction on SIGHUP or config file change
+	reloader := config.NewReloader("api", os.Args[1:], cfg)
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		return func() { limiter.SetConfig(next.RateLimits) }, nil
+	})
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		runner, err := newPipeline(next)
+		if err != nil {
+			return nil, err
+		}
+		return func() { pipelines.Replace(runner) }, nil
+	})
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		r, err := newRedactor(next)
+		if err != nil {
+			return nil, err
+		}
+		return func() { redactor.Replace(r) }, nil
+	})
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		if _, err := logging.ParseLevel(next.Logging.Level); err != nil {
+			return nil, err
+		}
+		return func() { _ = logging.SetLevel(next.Logging.Level) }, nil
+	})
+	go reloader.Watch(ctx, configReloadInterval)
 
 	// Setup Gin router
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(middleware.RequestID())
+	r.Use(otelgin.Middleware("logscale-api", otelgin.WithFilter(tracedRequest)))
+	r.Use(middleware.AccessLog(probePaths...))
+	r.Use(middleware.Metrics())
+	r.Use(middleware.BodyLimit(cfg.Server.MaxBodyBytes))
 
 	// Setup routes
-	routes.SetupRoutes(r, logHandler)
+	auditSvc := audit.NewService(helpers.NewAuditHelper(db))
+	routes.SetupRoutes(r, routes.Handlers{
+		Auth:       authSvc,
+		Audit:      auditSvc,
+		Logs:       v1.NewLogHandler(db, ingestSvc, limiter, cfg.Query.DefaultWindow),
+		GELF:       v1.NewGELFHandler(ingestSvc),
+		APIKeys:    v1.NewAPIKeyHandler(authSvc),
+		RateLimits: v1.NewRateLimitHandler(limiter),
+		Quotas:     v1.NewQuotaHandler(quotaSvc),
+		Roles:      v1.NewRoleHandler(authSvc),
+		Metrics:    v1.NewMetricsHandler(analytics.NewService(db), cfg.Prometheus.MaxServices),
+		Redactions: v1.NewRedactionHandler(redactor),
+		Pipelines:  v1.NewPipelineHandler(pipelines),
+		AuditLog:   v1.NewAuditHandler(auditSvc),
+		Config:     v1.NewConfigHandler(reloader),
+		Health:     v1.NewHealthHandler(health.NewChecker(cfg.Health.Timeout), newReadyChecker(cfg, db, ingestSvc)),
+
+		MaxBodyBytes: cfg.Server.MaxBodyBytes,
+		MetricsToken: cfg.Auth.MetricsToken,
+	})
 
-	log.Println("Starting LogScale API server on :8080")
-	err = r.Run(":8080")
+	server := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	// Serve HTTPS when a certificate is configured
+	tlsReloader, err := newTLSReloader(cfg.Server.TLS)
 	if err != nil {
-		log.Printf("error: failed to start server: %v", err)
-		db.Close() // Close before exit
+		slog.Error("failed to load TLS certificates", logging.Err(err))
+		db.Close()
 		os.Exit(1)
 	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsReloader != nil {
+			server.TLSConfig = tlsReloader.TLSConfig()
+			go tlsReloader.Watch(ctx, tlsReloadInterval)
+
+			slog.Info("starting LogScale API server", "addr", server.Addr, "tls", true)
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			slog.Info("starting LogScale API server", "addr", server.Addr, "tls", false)
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
+		slog.Error("failed to start server", logging.Err(err))
+		exitCode = 1
+	case <-ctx.Done():
+		slog.Info("shutting down LogScale API server")
+	}
+	stop()
+
+	// Stop accepting connections and wait for in-flight requests and the
+	// input servers, then for the stream publishes and audit events they started
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Warn("failed to drain HTTP requests", logging.Err(err))
+	}
+	if err := waitInputs(shutdownCtx, &inputs); err != nil {
+		slog.Warn("failed to stop log inputs", logging.Err(err))
+	}
+	if err := ingestSvc.Drain(shutdownCtx); err != nil {
+		slog.Warn("failed to drain stream publishes", logging.Err(err))
+	}
+	if err := auditSvc.Close(shutdownCtx); err != nil {
+		slog.Warn("failed to write queued audit events", logging.Err(err))
+	}
+
+	if streamSvc != nil {
+		if err := streamSvc.Close(); err != nil {
+			slog.Warn("failed to close Redis stream client", logging.Err(err))
+		}
+	}
+	if limitBackend != nil {
+		if err := limitBackend.Close(); err != nil {
+			slog.Warn("failed to close Redis rate limit client", logging.Err(err))
+		}
+	}
+	db.Close()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("failed to flush traces", logging.Err(err))
+	}
+	cancel()
+
+	slog.Info("LogScale API server stopped")
+	os.Exit(exitCode)
+}
+
+// waitInputs waits for the input servers to return, or for ctx to end
+func waitInputs(ctx context.Context, inputs *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		inputs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("log inputs still running: %w", ctx.Err())
+	}
+}
+
+// probePaths are polled by orchestrators and scrapers. They are left out
+// of traces and only logged at debug level.
+var probePaths = []string{"/livez", "/readyz", "/health", "/metrics"}
+
+// tracedRequest leaves probes out of traces
+func tracedRequest(r *http.Request) bool {
+	return !slices.Contains(probePaths, r.URL.Path)
+}
+
+// tlsReloadInterval is how often certificate files are checked for changes
+const tlsReloadInterval = 30 * time.Second
+
+// configReloadInterval is how often the config file is checked for changes
+const configReloadInterval = 10 * time.Second
+
+// newTLSReloader loads the configured certificates. It returns nil when TLS
+// is not configured.
+func newTLSReloader(cfg config.TLSConfig) (*tlsconfig.Reloader, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	clientAuth, err := tlsconfig.ParseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS client auth: %w", err)
+	}
+	return tlsconfig.NewReloader(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile, clientAuth)
+}
+
+// newStreamService connects the publisher of the log event stream
+func newStreamService(cfg *config.Config) (*stream.RedisStreamService, error) {
+	opts, err := cfg.Redis.Options()
+	if err != nil {
+		return nil, err
+	}
+	return stream.NewRedisStreamService(opts, cfg.Stream)
+}
+
+// newReadyChecker checks the dependencies the API needs to serve traffic.
+// Stream lag and the publish backlog only degrade readiness, since taking
+// the API out of rotation does not help a slow worker.
+func newReadyChecker(cfg *config.Config, db *pgxpool.Pool, ingestSvc *ingest.Service) *health.Checker {
+	checker := health.NewChecker(cfg.Health.Timeout)
+	checker.Critical("postgres", health.Postgres(db))
+	checker.Critical("schema", health.Schema(db, health.SchemaVersion))
+
+	if cfg.Redis.URL == "" {
+		return checker
+	}
+	opts, err := cfg.Redis.Options()
+	if err != nil {
+		slog.Warn("Redis health checks disabled", logging.Err(err))
+		return checker
+	}
+	// A small client of its own, so the checks work even when the stream
+	// service failed to connect at startup
+	opts.PoolSize = 2
+	client := redis.NewClient(opts)
+	checker.Critical("redis", health.Redis(client))
+	checker.Informational("publish_backlog", health.Backlog("logs", ingestSvc.Backlog, cfg.Health.MaxPublishBacklog))
+	checker.Informational("stream_lag", health.StreamLag(client, cfg.Stream.StreamName, cfg.Stream.ConsumerGroup, cfg.Health.MaxStreamLag))
+	return checker
+}
+
+// newRateLimitBackend connects the Redis backend shared by API replicas
+func newRateLimitBackend(cfg *config.Config) (*ratelimit.RedisBackend, error) {
+	opts, err := cfg.Redis.Options()
+	if err != nil {
+		return nil, err
+	}
+	return ratelimit.NewRedisBackend(opts)
+}
+
+// newPipeline builds the ingest pipeline from the pipelines section of the config
+func newPipeline(cfg *config.Config) (*pipeline.Runner, error) {
+	runner, err := pipeline.New(cfg.Pipelines)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline config: %w", err)
+	}
+	slog.Info("loaded ingest pipelines", logging.KeyCount, len(cfg.Pipelines))
+	return runner, nil
+}
+
+// newRedactor builds the ingest redaction stage from the redaction section
+// of the config. A disabled redactor leaves logs unchanged.
+func newRedactor(cfg *config.Config) (*redact.Redactor, error) {
+	redactor, err := redact.New(cfg.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction config: %w", err)
+	}
+	if cfg.Redaction.Enabled {
+		slog.Info("redaction enabled")
+	}
+	return redactor, nil
 }
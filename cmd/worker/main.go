@@ -2,45 +2,79 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/yunjin08/logscale/internal/analytics"
+	"github.com/yunjin08/logscale/internal/archive"
+	"github.com/yunjin08/logscale/internal/config"
+	"github.com/yunjin08/logscale/internal/health"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/partition"
+	"github.com/yunjin08/logscale/internal/retention"
+	"github.com/yunjin08/logscale/internal/tracing"
 	"github.com/yunjin08/logscale/internal/worker"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("warning: failed to load .env file: %v", err)
+	envErr := godotenv.Load()
+
+	// Load configuration
+	cfg, err := config.Load("worker", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Printf("error: %v", err)
+		os.Exit(1)
+	}
+
+	if err := logging.Setup(os.Stderr, "logscale-worker", cfg.Logging); err != nil {
+		log.Printf("error: %v", err)
+		os.Exit(1)
+	}
+	if envErr != nil {
+		slog.Debug("no .env file loaded", logging.Err(envErr))
 	}
 
-	// Get environment variables
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Printf("error: DATABASE_URL is not set")
+	shutdownTracing, err := tracing.Setup(context.Background(), "logscale-worker", cfg.Tracing)
+	if err != nil {
+		slog.Error("failed to set up tracing", logging.Err(err))
+		os.Exit(1)
+	}
+
+	if cfg.Redis.URL == "" {
+		slog.Error("REDIS_URL is not set")
 		os.Exit(1)
 	}
 
-	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		log.Printf("error: REDIS_URL is not set")
+	redisOptions, err := cfg.Redis.Options()
+	if err != nil {
+		slog.Error("invalid Redis config", logging.Err(err))
 		os.Exit(1)
 	}
 
-	streamName := os.Getenv("STREAM_NAME")
-	if streamName == "" {
-		streamName = "logscale:logs" // Default stream name
+	poolConfig, err := cfg.Database.PoolConfig()
+	if err != nil {
+		slog.Error("invalid database config", logging.Err(err))
+		os.Exit(1)
 	}
 
 	// Connect to database
-	db, err := pgxpool.New(context.Background(), databaseURL)
+	db, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
-		log.Printf("error: failed to connect to database: %v", err)
+		slog.Error("failed to connect to database", logging.Err(err))
 		os.Exit(1)
 	}
 
@@ -48,9 +82,9 @@ func main() {
 	analyticsSvc := analytics.NewService(db)
 
 	// Initialize worker
-	worker, err := worker.NewWorker(redisURL, analyticsSvc, streamName)
+	worker, err := worker.NewWorker(redisOptions, analyticsSvc, cfg.Stream)
 	if err != nil {
-		log.Printf("error: failed to create worker: %v", err)
+		slog.Error("failed to create worker", logging.Err(err))
 		db.Close()
 		os.Exit(1)
 	}
@@ -64,18 +98,95 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Create the log partitions of the coming days
+	partitions := partition.NewManager(db, cfg.Partitions.DaysAhead)
+	go partitions.Run(ctx, cfg.Partitions.CheckInterval)
+
+	// Remove logs past their retention TTL, archiving them first if enabled
+	var archiver *archive.Archiver
+	if cfg.Archive.Enabled {
+		store, err := archive.NewStore(cfg.Archive)
+		if err != nil {
+			slog.Error("failed to open archive store", logging.Err(err))
+			os.Exit(1)
+		}
+		archiver = archive.NewArchiver(db, store, cfg.Archive.Prefix)
+		slog.Info("archiving logs before removal", "store", store.Kind(), "location", store.Location())
+	}
+	enforcer := retention.NewEnforcer(db, cfg.Retention, archiver)
+	go enforcer.Run(ctx, cfg.Retention.Interval)
+
+	// Reload the log level and retention rules on SIGHUP or config file change
+	reloader := config.NewReloader("worker", os.Args[1:], cfg)
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		if _, err := logging.ParseLevel(next.Logging.Level); err != nil {
+			return nil, err
+		}
+		return func() { _ = logging.SetLevel(next.Logging.Level) }, nil
+	})
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		return func() { enforcer.Replace(next.Retention) }, nil
+	})
+	go reloader.Watch(ctx, configReloadInterval)
+
 	// Start worker in goroutine
 	go func() {
 		if err := worker.Start(ctx); err != nil {
-			log.Printf("error: worker failed: %v", err)
+			slog.Error("worker failed", logging.Err(err))
 			cancel()
 		}
 	}()
 
-	log.Printf("Worker started. Press Ctrl+C to stop.")
+	// Serve metrics, including the stream backlog, and health checks
+	var httpServer *http.Server
+	if cfg.Worker.HTTPAddr != "" {
+		metrics.Registry.MustRegister(metrics.NewStreamCollector(worker.Client(), cfg.Stream.StreamName))
+
+		live := health.NewChecker(cfg.Health.Timeout)
+		live.Critical("processing_loop", health.Heartbeat("processing loop", worker.LastProgress, cfg.Health.WorkerStallTimeout))
+
+		ready := health.NewChecker(cfg.Health.Timeout)
+		ready.Critical("postgres", health.Postgres(db))
+		ready.Critical("schema", health.Schema(db, health.SchemaVersion))
+		ready.Critical("redis", health.Redis(worker.Client()))
+		ready.Informational("partitions", partitions.Check)
+		ready.Informational("stream_lag", health.StreamLag(worker.Client(), cfg.Stream.StreamName, cfg.Stream.ConsumerGroup, cfg.Health.MaxStreamLag))
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/livez", health.Handler(live))
+		mux.Handle("/readyz", health.Handler(ready))
+		httpServer = &http.Server{
+			Addr:              cfg.Worker.HTTPAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		}
+		go func() {
+			slog.Info("serving worker metrics and health checks", "addr", cfg.Worker.HTTPAddr)
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("worker HTTP server failed", logging.Err(err))
+			}
+		}()
+	}
+
+	slog.Info("worker running, press Ctrl+C to stop")
 
 	// Wait for shutdown signal
 	<-sigChan
-	log.Println("Shutting down worker...")
+	slog.Info("shutting down worker")
 	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer shutdownCancel()
+	if httpServer != nil {
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("failed to stop worker HTTP server", logging.Err(err))
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("failed to flush traces", logging.Err(err))
+	}
 }
+
+// configReloadInterval is how often the config file is checked for changes
+const configReloadInterval = 10 * time.Second
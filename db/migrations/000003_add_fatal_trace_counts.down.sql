@@ -0,0 +1,7 @@
+-- Remove fatal and trace counters
+ALTER TABLE service_metrics
+    DROP COLUMN IF EXISTS fatal_count,
+    DROP COLUMN IF EXISTS trace_count;
+
+COMMENT ON COLUMN service_metrics.error_rate IS 'Error rate as decimal (0.0000 to 1.0000)';
+COMMENT ON COLUMN logs.level IS 'Log level (debug, info, warn, error, fatal)';
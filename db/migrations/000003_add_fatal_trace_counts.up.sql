@@ -0,0 +1,9 @@
+-- Track the fatal and trace levels introduced by level normalization
+ALTER TABLE service_metrics
+    ADD COLUMN fatal_count BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN trace_count BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN service_metrics.fatal_count IS 'Number of fatal level logs';
+COMMENT ON COLUMN service_metrics.trace_count IS 'Number of trace level logs';
+COMMENT ON COLUMN service_metrics.error_rate IS 'Share of error and fatal logs (0.0000 to 1.0000)';
+COMMENT ON COLUMN logs.level IS 'Canonical log level (fatal, error, warn, info, debug, trace)';
@@ -0,0 +1,27 @@
+-- Create api_keys table for authenticating API clients
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    key_hash CHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    rotated_from BIGINT REFERENCES api_keys(id)
+);
+
+-- Create indexes for key management queries
+CREATE INDEX idx_api_keys_created_at ON api_keys(created_at);
+
+-- Add comments
+COMMENT ON TABLE api_keys IS 'API keys used to authenticate ingestion, query and admin requests';
+COMMENT ON COLUMN api_keys.name IS 'Human readable name of the key owner';
+COMMENT ON COLUMN api_keys.prefix IS 'Public identifier embedded in the token, used for lookup';
+COMMENT ON COLUMN api_keys.key_hash IS 'SHA-256 hex digest of the secret part of the token';
+COMMENT ON COLUMN api_keys.scopes IS 'Granted scopes (logs:write, logs:read, admin)';
+COMMENT ON COLUMN api_keys.expires_at IS 'When the key stops being accepted, NULL for never';
+COMMENT ON COLUMN api_keys.revoked_at IS 'When the key was revoked, NULL if active';
+COMMENT ON COLUMN api_keys.last_used_at IS 'Approximate time the key was last used';
+COMMENT ON COLUMN api_keys.rotated_from IS 'Key this one replaced through rotation';
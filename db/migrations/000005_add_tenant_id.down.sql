@@ -0,0 +1,25 @@
+-- Remove tenant scoping
+DROP POLICY IF EXISTS tenant_isolation ON dead_letter_events;
+DROP POLICY IF EXISTS tenant_isolation ON service_metrics;
+DROP POLICY IF EXISTS tenant_isolation ON logs;
+
+ALTER TABLE dead_letter_events DISABLE ROW LEVEL SECURITY;
+ALTER TABLE service_metrics DISABLE ROW LEVEL SECURITY;
+ALTER TABLE logs DISABLE ROW LEVEL SECURITY;
+
+DROP INDEX IF EXISTS idx_api_keys_tenant;
+DROP INDEX IF EXISTS idx_dead_letter_events_tenant;
+DROP INDEX IF EXISTS idx_logs_tenant_service_level;
+DROP INDEX IF EXISTS idx_logs_tenant_timestamp;
+CREATE INDEX idx_logs_service ON logs(service);
+CREATE INDEX idx_logs_service_level ON logs(service, level);
+CREATE INDEX idx_logs_timestamp_desc ON logs(timestamp DESC);
+
+-- Fails if several tenants share a service name
+ALTER TABLE service_metrics DROP CONSTRAINT service_metrics_tenant_service_key;
+ALTER TABLE service_metrics ADD CONSTRAINT service_metrics_service_key UNIQUE (service);
+
+ALTER TABLE api_keys DROP COLUMN tenant_id;
+ALTER TABLE dead_letter_events DROP COLUMN tenant_id;
+ALTER TABLE service_metrics DROP COLUMN tenant_id;
+ALTER TABLE logs DROP COLUMN tenant_id;
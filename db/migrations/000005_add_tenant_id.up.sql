@@ -0,0 +1,40 @@
+-- Scope logs, metrics, dead letters and API keys to a tenant. Existing rows
+-- belong to the default tenant.
+ALTER TABLE logs ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE service_metrics ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE dead_letter_events ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE api_keys ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+-- Metrics are aggregated per tenant and service
+ALTER TABLE service_metrics DROP CONSTRAINT service_metrics_service_key;
+ALTER TABLE service_metrics ADD CONSTRAINT service_metrics_tenant_service_key UNIQUE (tenant_id, service);
+
+-- Every query filters by tenant first
+DROP INDEX idx_logs_service;
+DROP INDEX idx_logs_service_level;
+DROP INDEX idx_logs_timestamp_desc;
+CREATE INDEX idx_logs_tenant_timestamp ON logs(tenant_id, timestamp DESC);
+CREATE INDEX idx_logs_tenant_service_level ON logs(tenant_id, service, level);
+CREATE INDEX idx_dead_letter_events_tenant ON dead_letter_events(tenant_id);
+CREATE INDEX idx_api_keys_tenant ON api_keys(tenant_id);
+
+-- Row-level security for roles other than the table owner (e.g. read-only
+-- reporting users). Such sessions only see rows of the tenant set with
+-- SET logscale.tenant_id = '<tenant>'. The API connects as the owner and
+-- enforces the tenant in its own queries.
+ALTER TABLE logs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE service_metrics ENABLE ROW LEVEL SECURITY;
+ALTER TABLE dead_letter_events ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON logs
+    USING (tenant_id = current_setting('logscale.tenant_id', true));
+CREATE POLICY tenant_isolation ON service_metrics
+    USING (tenant_id = current_setting('logscale.tenant_id', true));
+CREATE POLICY tenant_isolation ON dead_letter_events
+    USING (tenant_id = current_setting('logscale.tenant_id', true));
+
+-- Add comments
+COMMENT ON COLUMN logs.tenant_id IS 'Tenant that owns the log, taken from the ingesting API key';
+COMMENT ON COLUMN service_metrics.tenant_id IS 'Tenant the metrics are aggregated for';
+COMMENT ON COLUMN dead_letter_events.tenant_id IS 'Tenant of the failed event';
+COMMENT ON COLUMN api_keys.tenant_id IS 'Tenant whose data the key can write and read';
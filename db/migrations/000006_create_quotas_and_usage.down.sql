@@ -0,0 +1,4 @@
+-- Drop quota tables
+DROP TABLE IF EXISTS quota_usage;
+DROP TABLE IF EXISTS ingest_usage;
+DROP TABLE IF EXISTS quotas;
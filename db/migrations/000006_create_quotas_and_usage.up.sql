@@ -0,0 +1,58 @@
+-- Create quotas table for daily ingestion budgets
+CREATE TABLE quotas (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    service VARCHAR(255) NOT NULL DEFAULT '',
+    soft_lines BIGINT NOT NULL DEFAULT 0,
+    soft_bytes BIGINT NOT NULL DEFAULT 0,
+    hard_lines BIGINT NOT NULL DEFAULT 0,
+    hard_bytes BIGINT NOT NULL DEFAULT 0,
+    hard_action VARCHAR(16) NOT NULL DEFAULT 'reject',
+    sample_rate DOUBLE PRECISION NOT NULL DEFAULT 0.1,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE(tenant_id, service),
+    CHECK (hard_action IN ('reject', 'sample')),
+    CHECK (sample_rate >= 0 AND sample_rate <= 1)
+);
+
+-- Create ingest_usage table for daily volume per tenant and service
+CREATE TABLE ingest_usage (
+    tenant_id VARCHAR(64) NOT NULL,
+    service VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    lines BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    dropped_lines BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, day, service)
+);
+
+-- Create quota_usage table, the volume counted against each quota's hard
+-- limits per day. It is checked and incremented in one statement.
+CREATE TABLE quota_usage (
+    quota_id BIGINT NOT NULL REFERENCES quotas(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    lines BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (quota_id, day)
+);
+
+-- Add comments
+COMMENT ON TABLE quotas IS 'Daily ingestion quotas per tenant or per tenant and service';
+COMMENT ON COLUMN quotas.service IS 'Service the quota applies to, empty for the whole tenant';
+COMMENT ON COLUMN quotas.soft_lines IS 'Daily lines after which a warning is emitted, 0 for none';
+COMMENT ON COLUMN quotas.soft_bytes IS 'Daily bytes after which a warning is emitted, 0 for none';
+COMMENT ON COLUMN quotas.hard_lines IS 'Daily lines after which hard_action applies, 0 for none';
+COMMENT ON COLUMN quotas.hard_bytes IS 'Daily bytes after which hard_action applies, 0 for none';
+COMMENT ON COLUMN quotas.hard_action IS 'reject or sample logs once the hard limit is reached';
+COMMENT ON COLUMN quotas.sample_rate IS 'Share of logs kept while sampling (0 to 1)';
+
+COMMENT ON TABLE ingest_usage IS 'Ingested volume per tenant, service and UTC day';
+COMMENT ON COLUMN ingest_usage.lines IS 'Number of logs stored';
+COMMENT ON COLUMN ingest_usage.bytes IS 'Size of stored logs (service, level, message and meta)';
+COMMENT ON COLUMN ingest_usage.dropped_lines IS 'Number of logs dropped by quota sampling or rejection';
+
+COMMENT ON TABLE quota_usage IS 'Volume counted against the hard limits of each quota per UTC day';
+COMMENT ON COLUMN quota_usage.lines IS 'Logs accepted under the quota, starting from the day''s usage when first counted';
+COMMENT ON COLUMN quota_usage.bytes IS 'Bytes accepted under the quota';
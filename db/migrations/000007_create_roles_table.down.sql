@@ -0,0 +1,3 @@
+-- Drop roles
+ALTER TABLE api_keys DROP COLUMN IF EXISTS role_id;
+DROP TABLE IF EXISTS roles;
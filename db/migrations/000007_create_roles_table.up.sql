@@ -0,0 +1,24 @@
+-- Create roles table restricting what API keys can read
+CREATE TABLE roles (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(255) NOT NULL,
+    service_patterns TEXT[] NOT NULL DEFAULT '{}',
+    meta_fields TEXT[],
+    meta_mode VARCHAR(16) NOT NULL DEFAULT 'redact',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (meta_mode IN ('redact', 'deny')),
+    UNIQUE (tenant_id, name)
+);
+
+ALTER TABLE api_keys ADD COLUMN role_id BIGINT REFERENCES roles(id);
+CREATE INDEX idx_api_keys_role_id ON api_keys(role_id);
+
+-- Add comments
+COMMENT ON TABLE roles IS 'Read policies assigned to API keys';
+COMMENT ON COLUMN roles.tenant_id IS 'Tenant whose keys may be assigned the role';
+COMMENT ON COLUMN roles.service_patterns IS 'Glob patterns of services the role may read, empty for all';
+COMMENT ON COLUMN roles.meta_fields IS 'Top-level meta keys the role may read, NULL for all';
+COMMENT ON COLUMN roles.meta_mode IS 'redact disallowed meta values, or deny logs carrying them';
+COMMENT ON COLUMN api_keys.role_id IS 'Role restricting what the key can read, NULL for unrestricted';
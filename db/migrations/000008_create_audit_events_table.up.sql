@@ -0,0 +1,47 @@
+-- Create audit_events table, an append-only record of queries and admin changes
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    actor_key_id BIGINT,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(255) NOT NULL,
+    resource TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE
+);
+
+CREATE INDEX idx_audit_events_tenant_occurred ON audit_events(tenant_id, occurred_at DESC);
+CREATE INDEX idx_audit_events_actor_key ON audit_events(actor_key_id);
+
+-- Rows can only be appended
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- Same tenant isolation as the other tenant tables
+ALTER TABLE audit_events ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON audit_events
+    USING (tenant_id = current_setting('logscale.tenant_id', true));
+
+-- Add comments
+COMMENT ON TABLE audit_events IS 'Append-only audit trail of queries and administrative changes, hash chained';
+COMMENT ON COLUMN audit_events.tenant_id IS 'Tenant of the caller';
+COMMENT ON COLUMN audit_events.actor_key_id IS 'API key that made the request, NULL for the bootstrap key or disabled auth';
+COMMENT ON COLUMN audit_events.action IS 'HTTP method and route, e.g. GET /v1/logs';
+COMMENT ON COLUMN audit_events.resource IS 'Requested path';
+COMMENT ON COLUMN audit_events.status IS 'HTTP status of the response';
+COMMENT ON COLUMN audit_events.details IS 'Query parameters, request body and result count';
+COMMENT ON COLUMN audit_events.prev_hash IS 'Hash of the previous event, zeros for the first';
+COMMENT ON COLUMN audit_events.hash IS 'SHA-256 over prev_hash and the event fields';
@@ -0,0 +1,3 @@
+-- Drop client certificate subjects
+DROP INDEX IF EXISTS idx_api_keys_cert_subject;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS cert_subject;
@@ -0,0 +1,9 @@
+-- Let API keys be presented as TLS client certificates
+ALTER TABLE api_keys ADD COLUMN cert_subject VARCHAR(1024);
+
+-- A subject maps to at most one active key
+CREATE UNIQUE INDEX idx_api_keys_cert_subject ON api_keys(cert_subject)
+    WHERE cert_subject IS NOT NULL AND revoked_at IS NULL;
+
+-- Add comments
+COMMENT ON COLUMN api_keys.cert_subject IS 'Client certificate subject DN (CN=...,O=...) or common name that authenticates as this key';
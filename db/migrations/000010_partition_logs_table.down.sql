@@ -0,0 +1,45 @@
+-- Merge the daily partitions back into a single logs table
+ALTER TABLE logs RENAME TO logs_partitioned;
+ALTER INDEX logs_pkey RENAME TO logs_partitioned_pkey;
+DROP INDEX idx_logs_tenant_timestamp;
+DROP INDEX idx_logs_tenant_service_level;
+
+CREATE TABLE logs (
+    id BIGINT PRIMARY KEY DEFAULT nextval('logs_id_seq'),
+    service VARCHAR(255) NOT NULL,
+    level VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    meta JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default'
+);
+
+ALTER SEQUENCE logs_id_seq OWNED BY logs.id;
+
+INSERT INTO logs (id, tenant_id, service, level, message, timestamp, meta, created_at)
+SELECT id, tenant_id, service, level, message, timestamp, meta, created_at
+FROM logs_partitioned;
+
+-- Drops every partition
+DROP TABLE logs_partitioned;
+
+CREATE INDEX idx_logs_level ON logs(level);
+CREATE INDEX idx_logs_timestamp ON logs(timestamp);
+CREATE INDEX idx_logs_tenant_timestamp ON logs(tenant_id, timestamp DESC);
+CREATE INDEX idx_logs_tenant_service_level ON logs(tenant_id, service, level);
+
+ALTER TABLE logs ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON logs
+    USING (tenant_id = current_setting('logscale.tenant_id', true));
+
+-- Add comments
+COMMENT ON TABLE logs IS 'Stores application logs with metadata';
+COMMENT ON COLUMN logs.id IS 'Unique identifier for the log entry';
+COMMENT ON COLUMN logs.tenant_id IS 'Tenant that owns the log, taken from the ingesting API key';
+COMMENT ON COLUMN logs.service IS 'Name of the service that generated the log';
+COMMENT ON COLUMN logs.level IS 'Log level (debug, info, warn, error, fatal)';
+COMMENT ON COLUMN logs.message IS 'The log message content';
+COMMENT ON COLUMN logs.timestamp IS 'When the log was generated';
+COMMENT ON COLUMN logs.meta IS 'Additional metadata as JSON';
+COMMENT ON COLUMN logs.created_at IS 'When the log was stored in the database';
@@ -0,0 +1,92 @@
+-- Range-partition logs by UTC day on timestamp, so that time-bounded queries
+-- only scan the days they cover and old days can be dropped whole. The
+-- worker creates the partitions of upcoming days; logs of days without a
+-- partition land in logs_default.
+ALTER TABLE logs RENAME TO logs_unpartitioned;
+ALTER INDEX logs_pkey RENAME TO logs_unpartitioned_pkey;
+DROP INDEX idx_logs_level;
+DROP INDEX idx_logs_timestamp;
+DROP INDEX idx_logs_tenant_timestamp;
+DROP INDEX idx_logs_tenant_service_level;
+
+-- The primary key of a partitioned table must include the partition key
+CREATE TABLE logs (
+    id BIGINT NOT NULL DEFAULT nextval('logs_id_seq'),
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    service VARCHAR(255) NOT NULL,
+    level VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    meta JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+ALTER SEQUENCE logs_id_seq OWNED BY logs.id;
+
+CREATE TABLE logs_default PARTITION OF logs DEFAULT;
+
+-- One partition per day from today through a week ahead, and for the days
+-- of the last 31 that have logs. Timestamps come from clients, so older or
+-- further ahead logs, such as ones stamped 1970-01-01, stay in logs_default
+-- rather than each getting a partition; retention deletes them in batches.
+DO $$
+DECLARE
+    today DATE := (NOW() AT TIME ZONE 'UTC')::date;
+    day DATE;
+BEGIN
+    FOR day IN
+        SELECT today + n FROM generate_series(0, 7) AS n
+        UNION
+        SELECT DISTINCT (timestamp AT TIME ZONE 'UTC')::date
+        FROM logs_unpartitioned
+        WHERE timestamp >= (today - 31)::timestamp AT TIME ZONE 'UTC'
+            AND timestamp < (today + 8)::timestamp AT TIME ZONE 'UTC'
+    LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF logs FOR VALUES FROM (%L) TO (%L)',
+            'logs_p' || to_char(day, 'YYYYMMDD'),
+            day::timestamp AT TIME ZONE 'UTC',
+            (day + 1)::timestamp AT TIME ZONE 'UTC');
+    END LOOP;
+END $$;
+
+INSERT INTO logs (id, tenant_id, service, level, message, timestamp, meta, created_at)
+SELECT id, tenant_id, service, level, message, timestamp, meta, created_at
+FROM logs_unpartitioned;
+
+DROP TABLE logs_unpartitioned;
+
+-- Every query filters by tenant first; partition pruning replaces the
+-- timestamp-only indexes
+CREATE INDEX idx_logs_tenant_timestamp ON logs(tenant_id, timestamp DESC);
+CREATE INDEX idx_logs_tenant_service_level ON logs(tenant_id, service, level, timestamp DESC);
+
+-- Same tenant isolation as before. Policies on logs only apply to queries
+-- through logs, so every partition carries the policy too; the worker adds
+-- it to the partitions it creates.
+ALTER TABLE logs ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON logs
+    USING (tenant_id = current_setting('logscale.tenant_id', true));
+
+DO $$
+DECLARE
+    part REGCLASS;
+BEGIN
+    FOR part IN SELECT inhrelid::regclass FROM pg_inherits WHERE inhparent = 'logs'::regclass LOOP
+        EXECUTE format('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', part);
+        EXECUTE format('CREATE POLICY tenant_isolation ON %s USING (tenant_id = current_setting(%L, true))',
+            part, 'logscale.tenant_id');
+    END LOOP;
+END $$;
+
+-- Add comments
+COMMENT ON TABLE logs IS 'Stores application logs with metadata, partitioned by UTC day of timestamp';
+COMMENT ON TABLE logs_default IS 'Logs of days without their own partition';
+COMMENT ON COLUMN logs.id IS 'Identifier of the log entry, unique together with timestamp';
+COMMENT ON COLUMN logs.tenant_id IS 'Tenant that owns the log, taken from the ingesting API key';
+COMMENT ON COLUMN logs.service IS 'Name of the service that generated the log';
+COMMENT ON COLUMN logs.level IS 'Log level (debug, info, warn, error, fatal)';
+COMMENT ON COLUMN logs.message IS 'The log message content';
+COMMENT ON COLUMN logs.timestamp IS 'When the log was generated; selects the partition';
+COMMENT ON COLUMN logs.meta IS 'Additional metadata as JSON';
+COMMENT ON COLUMN logs.created_at IS 'When the log was stored in the database';
@@ -0,0 +1,2 @@
+-- Drop archive_manifests table; the archive files are kept
+DROP TABLE IF EXISTS archive_manifests;
@@ -0,0 +1,35 @@
+-- Create archive_manifests table, recording the archive files of logs
+-- exported before retention removed them
+CREATE TABLE archive_manifests (
+    id BIGSERIAL PRIMARY KEY,
+    store VARCHAR(32) NOT NULL,
+    location TEXT NOT NULL,
+    object_key TEXT NOT NULL,
+    format VARCHAR(32) NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    range_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    range_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    log_count BIGINT NOT NULL,
+    stored_bytes BIGINT NOT NULL,
+    archive_bytes BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (store, location, object_key)
+);
+
+-- Finding the archives covering a time range
+CREATE INDEX idx_archive_manifests_range ON archive_manifests(range_start, range_end);
+
+-- Add comments
+COMMENT ON TABLE archive_manifests IS 'Archive files of exported logs and where they are stored';
+COMMENT ON COLUMN archive_manifests.store IS 'Blob store kind (local, s3)';
+COMMENT ON COLUMN archive_manifests.location IS 'Directory or bucket of the store';
+COMMENT ON COLUMN archive_manifests.object_key IS 'Key of the file within the store';
+COMMENT ON COLUMN archive_manifests.format IS 'File format (ndjson.gz)';
+COMMENT ON COLUMN archive_manifests.source IS 'Partition archived, or retention for logs archived as they were deleted';
+COMMENT ON COLUMN archive_manifests.range_start IS 'Earliest timestamp the file covers';
+COMMENT ON COLUMN archive_manifests.range_end IS 'Latest timestamp the file covers';
+COMMENT ON COLUMN archive_manifests.log_count IS 'Logs in the file';
+COMMENT ON COLUMN archive_manifests.stored_bytes IS 'Size of the logs in the database';
+COMMENT ON COLUMN archive_manifests.archive_bytes IS 'Size of the compressed file';
+COMMENT ON COLUMN archive_manifests.sha256 IS 'SHA-256 of the file, to verify downloads';
@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/models"
+)
+
+type APIKeyHandler struct {
+	authSvc *auth.Service
+}
+
+func NewAPIKeyHandler(authSvc *auth.Service) *APIKeyHandler {
+	return &APIKeyHandler{authSvc: authSvc}
+}
+
+// CreateKey handles
+// POST /v1/admin/keys - issues a new API key; the token is only returned once
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keys belong to the caller's tenant. Only admins of every tenant can
+	// name another.
+	if auth.ManagedTenant(c) != "" || req.TenantID == "" {
+		req.TenantID = auth.TenantFromContext(c)
+	}
+
+	resp, err := h.authSvc.CreateKey(c.Request.Context(), req)
+	if errors.Is(err, auth.ErrInvalidScope) || errors.Is(err, auth.ErrInvalidTenant) ||
+		errors.Is(err, auth.ErrInvalidRole) || errors.Is(err, auth.ErrInvalidCertSubject) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, helpers.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another active API key uses this cert_subject"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListKeys handles
+// GET /v1/admin/keys - lists the API keys of the caller's tenant without their secrets
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.authSvc.ListKeys(c.Request.Context(), auth.ManagedTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// RotateKey handles
+// POST /v1/admin/keys/:id/rotate - issues a replacement key, keeping the old one valid for an optional grace period
+func (h *APIKeyHandler) RotateKey(c *gin.Context) {
+	id, ok := pathID(c, "Invalid API key id")
+	if !ok {
+		return
+	}
+
+	var req models.RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		var err error
+		grace, err = time.ParseDuration(req.GracePeriod)
+		if err != nil || grace < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grace_period"})
+			return
+		}
+	}
+
+	resp, err := h.authSvc.RotateKey(c.Request.Context(), auth.ManagedTenant(c), id, grace)
+	if errors.Is(err, helpers.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RevokeKey handles
+// DELETE /v1/admin/keys/:id - revokes an API key immediately
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	id, ok := pathID(c, "Invalid API key id")
+	if !ok {
+		return
+	}
+
+	key, err := h.authSvc.RevokeKey(c.Request.Context(), auth.ManagedTenant(c), id)
+	if errors.Is(err, helpers.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// pathID parses the :id path parameter, writing a 400 with message on failure
+func pathID(c *gin.Context, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/audit"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/models"
+	"github.com/yunjin08/logscale/pkg/pagination"
+)
+
+type AuditHandler struct {
+	auditSvc *audit.Service
+}
+
+func NewAuditHandler(auditSvc *audit.Service) *AuditHandler {
+	return &AuditHandler{auditSvc: auditSvc}
+}
+
+// ListEvents handles
+// GET /v1/audit - audit events, newest first (paginated). The bootstrap key
+// sees every tenant and can filter by tenant_id; keys only see their own tenant.
+func (h *AuditHandler) ListEvents(c *gin.Context) {
+	var query models.AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+
+	if p := auth.PrincipalFromContext(c); p == nil || !p.IsBootstrap() {
+		query.TenantID = auth.TenantFromContext(c)
+	}
+
+	p := pagination.GetPaginationFromContext(c)
+	events, total, err := h.auditSvc.Query(c.Request.Context(), query, p)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query audit events"})
+		return
+	}
+
+	audit.AddDetail(c, "result_count", len(events))
+	p.SetTotal(total)
+	c.JSON(http.StatusOK, pagination.CreatePaginatedResponse(events, p))
+}
+
+// Verify handles
+// GET /v1/audit/verify - recomputes the hash chain and reports the first tampered event
+func (h *AuditHandler) Verify(c *gin.Context) {
+	result, err := h.auditSvc.Verify(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
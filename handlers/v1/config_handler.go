@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/config"
+)
+
+type ConfigHandler struct {
+	reloader *config.Reloader
+}
+
+func NewConfigHandler(reloader *config.Reloader) *ConfigHandler {
+	return &ConfigHandler{reloader: reloader}
+}
+
+// GetConfig handles
+// GET /v1/admin/config - effective configuration, with secrets masked. It
+// covers the whole deployment, so only admins of every tenant may read it.
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	if p := auth.PrincipalFromContext(c); p == nil || !p.ManagesAllTenants() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins of every tenant may read the configuration"})
+		return
+	}
+
+	cfg := h.reloader.Current()
+	doc, err := cfg.Masked().Document()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"config":    doc,
+		"file":      cfg.File,
+		"loaded_at": h.reloader.LoadedAt(),
+	})
+}
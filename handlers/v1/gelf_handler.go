@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/ingest"
+	"github.com/yunjin08/logscale/internal/input/gelf"
+)
+
+// maxGELFBodySize caps the (possibly compressed) request body
+const maxGELFBodySize = 8 << 20
+
+type GELFHandler struct {
+	ingestSvc *ingest.Service
+}
+
+func NewGELFHandler(ingestSvc *ingest.Service) *GELFHandler {
+	return &GELFHandler{ingestSvc: ingestSvc}
+}
+
+// CreateLog handles
+// POST /gelf - accepts a single GELF message, optionally gzip or zlib compressed
+func (h *GELFHandler) CreateLog(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGELFBodySize+1))
+	if err != nil {
+		if bodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "GELF message too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if len(body) > maxGELFBodySize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "GELF message too large"})
+		return
+	}
+
+	req, err := gelf.Parse(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.ingestSvc.IngestOne(c.Request.Context(), auth.TenantFromContext(c), req); err != nil {
+		writeIngestError(c, err)
+		return
+	}
+
+	// Graylog answers GELF HTTP inputs with an empty 202
+	c.Status(http.StatusAccepted)
+}
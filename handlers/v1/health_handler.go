@@ -0,0 +1,31 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/health"
+)
+
+type HealthHandler struct {
+	live  *health.Checker
+	ready *health.Checker
+}
+
+// NewHealthHandler creates a handler reporting the live checks on /livez
+// and the ready checks on /readyz
+func NewHealthHandler(live, ready *health.Checker) *HealthHandler {
+	return &HealthHandler{live: live, ready: ready}
+}
+
+// Livez handles
+// GET /livez - reports whether the process is working, without checking dependencies
+func (h *HealthHandler) Livez(c *gin.Context) {
+	resp := h.live.Run(c.Request.Context())
+	c.JSON(health.StatusCode(resp), resp)
+}
+
+// Readyz handles
+// GET /readyz - checks the database, schema, Redis, publish backlog and stream lag
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	resp := h.ready.Run(c.Request.Context())
+	c.JSON(health.StatusCode(resp), resp)
+}
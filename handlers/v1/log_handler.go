@@ -1,14 +1,20 @@
 package v1
 
 import (
-	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yunjin08/logscale/helpers"
-	"github.com/yunjin08/logscale/internal/stream"
+	"github.com/yunjin08/logscale/internal/audit"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/ingest"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/internal/ratelimit"
 	"github.com/yunjin08/logscale/models"
 	"github.com/yunjin08/logscale/pkg/pagination"
 )
@@ -16,14 +22,22 @@ import (
 type LogHandler struct {
 	db        *pgxpool.Pool
 	helper    *helpers.LogHelper
-	streamSvc *stream.RedisStreamService
+	ingestSvc *ingest.Service
+	limiter   *ratelimit.Limiter
+	// queryWindow is the time range searched when a query sets no start_time
+	queryWindow time.Duration
 }
 
-func NewLogHandler(db *pgxpool.Pool, streamSvc *stream.RedisStreamService) *LogHandler {
+// NewLogHandler creates a LogHandler. limiter may be nil to disable rate
+// limiting. Queries without a start_time search queryWindow back from their
+// end_time.
+func NewLogHandler(db *pgxpool.Pool, ingestSvc *ingest.Service, limiter *ratelimit.Limiter, queryWindow time.Duration) *LogHandler {
 	return &LogHandler{
-		db:        db,
-		helper:    helpers.NewLogHelper(db),
-		streamSvc: streamSvc,
+		db:          db,
+		helper:      helpers.NewLogHelper(db),
+		ingestSvc:   ingestSvc,
+		limiter:     limiter,
+		queryWindow: queryWindow,
 	}
 }
 
@@ -36,27 +50,30 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
+		if bodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
 	// Handle single log
 	if request.Log != nil {
-		log, err := h.helper.CreateSingleLog(c.Request.Context(), *request.Log)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if !h.allow(c, []models.LogRequest{*request.Log}) {
 			return
 		}
 
-		// Publish to Redis Stream (async)
-		go func() {
-			if h.streamSvc != nil {
-				if err := h.streamSvc.PublishLogEvent(context.Background(), *log); err != nil {
-					// Log error but don't fail the request
-					_ = c.Error(err) // Ignore error return from c.Error
-				}
-			}
-		}()
+		log, err := h.ingestSvc.IngestOne(c.Request.Context(), auth.TenantFromContext(c), *request.Log)
+		if err != nil {
+			writeIngestError(c, err)
+			return
+		}
+		if log == nil {
+			// Dropped by a pipeline or sampled out by a daily quota
+			c.JSON(http.StatusAccepted, gin.H{"count": 0, "dropped": 1})
+			return
+		}
 
 		c.JSON(http.StatusCreated, log)
 		return
@@ -68,25 +85,68 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 		return
 	}
 
-	logs, err := h.helper.CreateBatchLogs(c.Request.Context(), request.Logs)
+	if !h.allow(c, request.Logs) {
+		return
+	}
+
+	logs, err := h.ingestSvc.Ingest(c.Request.Context(), auth.TenantFromContext(c), request.Logs)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeIngestError(c, err)
 		return
 	}
 
-	// Publish batch to Redis Stream (async)
-	go func() {
-		if h.streamSvc != nil {
-			for _, log := range logs {
-				if err := h.streamSvc.PublishLogEvent(context.Background(), log); err != nil {
-					// Log error but don't fail the request
-					_ = c.Error(err) // Ignore error return from c.Error
-				}
-			}
+	response := gin.H{"logs": logs, "count": len(logs)}
+	if dropped := len(request.Logs) - len(logs); dropped > 0 {
+		response["dropped"] = dropped
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// allow applies the ingestion rate limits, writing a 429 with Retry-After
+// when the request is over them
+func (h *LogHandler) allow(c *gin.Context, reqs []models.LogRequest) bool {
+	if h.limiter == nil || !h.limiter.Enabled() {
+		return true
+	}
+
+	principal := auth.PrincipalFromContext(c)
+	keyID := "anonymous"
+	if principal != nil {
+		keyID = strconv.FormatInt(principal.KeyID, 10)
+		if principal.KeyID == 0 {
+			keyID = principal.Name
 		}
-	}()
+	}
 
-	c.JSON(http.StatusCreated, gin.H{"logs": logs, "count": len(logs)})
+	decision := h.limiter.AllowLogs(c.Request.Context(), keyID, auth.TenantFromContext(c), reqs)
+	if decision.Allowed {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded", "retry_after": retryAfter})
+	return false
+}
+
+// writeIngestError maps ingestion errors to HTTP responses
+func writeIngestError(c *gin.Context, err error) {
+	var exceeded *quota.ExceededError
+	switch {
+	case errors.Is(err, ingest.ErrInvalidLog):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &exceeded):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(exceeded.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// bodyTooLarge reports whether reading the request body hit the server's body limit
+func bodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
 }
 
 // GetLogs handles
@@ -98,15 +158,51 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 		return
 	}
 
+	// Bound the start so that only the partitions the range covers are
+	// scanned. The end stays open unless set, so that logs timestamped ahead
+	// of the server clock are found.
+	end, err := parseTime(query.EndTime, time.Time{})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_time, expected RFC 3339 or YYYY-MM-DD"})
+		return
+	}
+	from := end
+	if from.IsZero() {
+		from = time.Now().UTC()
+	}
+	start, err := parseTime(query.StartTime, from.Add(-h.queryWindow))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_time, expected RFC 3339 or YYYY-MM-DD"})
+		return
+	}
+	if !end.IsZero() && start.After(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time range"})
+		return
+	}
+	query.StartTime = start.Format(time.RFC3339Nano)
+	if !end.IsZero() {
+		query.EndTime = end.Format(time.RFC3339Nano)
+	}
+
+	// Accept level aliases such as ERROR or warning when filtering
+	if level, ok := models.NormalizeLevel(query.Level); ok {
+		query.Level = level
+	}
+
 	// Get pagination from context (set by middleware)
 	p := pagination.GetPaginationFromContext(c)
 
-	logs, total, err := h.helper.QueryLogs(c.Request.Context(), query, p)
+	logs, total, err := h.helper.QueryLogs(c.Request.Context(), auth.TenantFromContext(c), auth.PolicyFromContext(c), query, p)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	audit.AddDetail(c, "start_time", query.StartTime)
+	audit.AddDetail(c, "end_time", query.EndTime)
+	audit.AddDetail(c, "result_count", len(logs))
+	audit.AddDetail(c, "total", total)
+
 	// Set total and create paginated response
 	p.SetTotal(total)
 	response := pagination.CreatePaginatedResponse(logs, p)
@@ -114,24 +210,14 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// Health handles
-// GET /health - readiness/liveness
-func (h *LogHandler) Health(c *gin.Context) {
-	// Check database connectivity
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
-
-	err := h.helper.PingDatabase(ctx)
-	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, models.HealthResponse{
-			Status:    "unhealthy",
-			Timestamp: time.Now().Format(time.RFC3339),
-		})
-		return
+// parseTime parses an RFC 3339 time or a YYYY-MM-DD date, returning fallback
+// when s is empty
+func parseTime(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
 	}
-
-	c.JSON(http.StatusOK, models.HealthResponse{
-		Status:    "healthy",
-		Timestamp: time.Now().Format(time.RFC3339),
-	})
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
 }
@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yunjin08/logscale/internal/analytics"
+	"github.com/yunjin08/logscale/internal/audit"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/metrics"
+)
+
+type MetricsHandler struct {
+	analytics *analytics.Service
+	// maxServices bounds the services exported with their own series
+	maxServices int
+}
+
+func NewMetricsHandler(analyticsSvc *analytics.Service, maxServices int) *MetricsHandler {
+	return &MetricsHandler{analytics: analyticsSvc, maxServices: maxServices}
+}
+
+// ListServiceMetrics handles
+// GET /v1/services/metrics - returns metrics for every service the caller may read
+func (h *MetricsHandler) ListServiceMetrics(c *gin.Context) {
+	metrics, err := h.analytics.GetAllServiceMetrics(c.Request.Context(), auth.TenantFromContext(c), auth.PolicyFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service metrics"})
+		return
+	}
+
+	audit.AddDetail(c, "result_count", len(metrics))
+	c.JSON(http.StatusOK, gin.H{"data": metrics})
+}
+
+// GetServiceMetrics handles
+// GET /v1/services/:service/metrics - returns metrics for one service
+func (h *MetricsHandler) GetServiceMetrics(c *gin.Context) {
+	metrics, err := h.analytics.GetServiceMetrics(c.Request.Context(), auth.TenantFromContext(c), auth.PolicyFromContext(c), c.Param("service"))
+	if errors.Is(err, analytics.ErrMetricsNotFound) {
+		// Services outside the caller's role look the same as unknown ones
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service metrics not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service metrics"})
+		return
+	}
+
+	audit.AddDetail(c, "result_count", 1)
+	c.JSON(http.StatusOK, metrics)
+}
+
+// ExportServiceMetrics handles
+// GET /v1/services/metrics/prometheus - exports the caller's service metrics in the Prometheus text format
+func (h *MetricsHandler) ExportServiceMetrics(c *gin.Context) {
+	services, err := h.analytics.GetAllServiceMetrics(c.Request.Context(), auth.TenantFromContext(c), auth.PolicyFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service metrics"})
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics.NewServiceCollector(services, h.maxServices))
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(c.Writer, c.Request)
+}
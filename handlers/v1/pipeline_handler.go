@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/pipeline"
+	"github.com/yunjin08/logscale/models"
+)
+
+const (
+	// maxDryRunLogs caps the sample logs of one dry run
+	maxDryRunLogs = 100
+	// maxDryRunBytes caps the request body of one dry run, pipelines included
+	maxDryRunBytes = 1 << 20
+)
+
+type PipelineHandler struct {
+	runner *pipeline.Runner
+}
+
+// NewPipelineHandler creates a handler for the configured runner, which is nil without pipelines
+func NewPipelineHandler(runner *pipeline.Runner) *PipelineHandler {
+	if runner == nil {
+		runner, _ = pipeline.New(nil)
+	}
+	return &PipelineHandler{runner: runner}
+}
+
+// DryRun handles
+// POST /v1/pipelines/dry-run - runs sample logs through the configured pipelines, or through
+// the pipelines in the request, without storing anything
+func (h *PipelineHandler) DryRun(c *gin.Context) {
+	var request struct {
+		Pipelines []pipeline.Config   `json:"pipelines"`
+		Logs      []models.LogRequest `json:"logs" binding:"required"`
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDryRunBytes)
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Logs) == 0 || len(request.Logs) > maxDryRunLogs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Provide between 1 and %d logs", maxDryRunLogs)})
+		return
+	}
+
+	runner := h.runner
+	if request.Pipelines != nil {
+		var err error
+		runner, err = pipeline.New(request.Pipelines)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": runner.DryRun(request.Logs)})
+}
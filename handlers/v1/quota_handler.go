@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/audit"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/models"
+)
+
+const (
+	// defaultUsageDays is the range GET /v1/usage covers without from/to
+	defaultUsageDays = 7
+	// maxUsageDays caps the range of a usage query
+	maxUsageDays = 366
+)
+
+type QuotaHandler struct {
+	quotaSvc *quota.Service
+}
+
+func NewQuotaHandler(quotaSvc *quota.Service) *QuotaHandler {
+	return &QuotaHandler{quotaSvc: quotaSvc}
+}
+
+// GetUsage handles
+// GET /v1/usage - daily ingested volume of the caller's tenant per service, with its quotas
+func (h *QuotaHandler) GetUsage(c *gin.Context) {
+	var query models.UsageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to, err := parseDay(query.To, today)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+		return
+	}
+	from, err := parseDay(query.From, to.AddDate(0, 0, -(defaultUsageDays-1)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+		return
+	}
+	if from.After(to) || to.Sub(from) > maxUsageDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
+		return
+	}
+
+	tenantID := auth.TenantFromContext(c)
+	usage, err := h.quotaSvc.Usage(c.Request.Context(), tenantID, query.Service,
+		from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query usage"})
+		return
+	}
+
+	audit.AddDetail(c, "result_count", len(usage))
+	c.JSON(http.StatusOK, gin.H{
+		"data":   usage,
+		"from":   from.Format(time.DateOnly),
+		"to":     to.Format(time.DateOnly),
+		"quotas": h.quotaSvc.TenantQuotas(c.Request.Context(), tenantID),
+	})
+}
+
+// SetQuota handles
+// PUT /v1/admin/quotas - creates or replaces the quota of a tenant or tenant service
+func (h *QuotaHandler) SetQuota(c *gin.Context) {
+	var req models.QuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Quotas are set for the caller's tenant. Only admins of every tenant
+	// can name another.
+	if auth.ManagedTenant(c) != "" || req.TenantID == "" {
+		req.TenantID = auth.TenantFromContext(c)
+	}
+	if err := models.ValidateTenantID(req.TenantID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q := models.Quota{
+		TenantID:   req.TenantID,
+		Service:    req.Service,
+		SoftLines:  req.SoftLines,
+		SoftBytes:  req.SoftBytes,
+		HardLines:  req.HardLines,
+		HardBytes:  req.HardBytes,
+		HardAction: req.HardAction,
+		SampleRate: 0.1,
+	}
+	if q.HardAction == "" {
+		q.HardAction = models.QuotaActionReject
+	}
+	if req.SampleRate != nil {
+		q.SampleRate = *req.SampleRate
+	}
+
+	saved, err := h.quotaSvc.SetQuota(c.Request.Context(), q)
+	if errors.Is(err, quota.ErrInvalidQuota) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// ListQuotas handles
+// GET /v1/admin/quotas - lists the quotas of the caller's tenant
+func (h *QuotaHandler) ListQuotas(c *gin.Context) {
+	quotas, err := h.quotaSvc.ListQuotas(c.Request.Context(), auth.ManagedTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list quotas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quotas})
+}
+
+// DeleteQuota handles
+// DELETE /v1/admin/quotas/:id - removes a quota
+func (h *QuotaHandler) DeleteQuota(c *gin.Context) {
+	id, ok := pathID(c, "Invalid quota id")
+	if !ok {
+		return
+	}
+
+	err := h.quotaSvc.DeleteQuota(c.Request.Context(), auth.ManagedTenant(c), id)
+	if errors.Is(err, helpers.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quota not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quota"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseDay parses a YYYY-MM-DD date, returning fallback when s is empty
+func parseDay(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
@@ -0,0 +1,29 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/ratelimit"
+)
+
+type RateLimitHandler struct {
+	limiter *ratelimit.Limiter
+}
+
+func NewRateLimitHandler(limiter *ratelimit.Limiter) *RateLimitHandler {
+	return &RateLimitHandler{limiter: limiter}
+}
+
+// Dropped handles
+// GET /v1/admin/rate-limits/dropped - logs of the caller's tenant rejected by rate limiting, per service
+func (h *RateLimitHandler) Dropped(c *gin.Context) {
+	dropped, err := h.limiter.Dropped(c.Request.Context(), auth.ManagedTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read dropped log counts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dropped})
+}
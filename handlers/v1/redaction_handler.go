@@ -0,0 +1,31 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/redact"
+	"github.com/yunjin08/logscale/models"
+)
+
+type RedactionHandler struct {
+	redactor *redact.Redactor
+}
+
+// NewRedactionHandler creates a handler for redactor, which may be nil
+func NewRedactionHandler(redactor *redact.Redactor) *RedactionHandler {
+	return &RedactionHandler{redactor: redactor}
+}
+
+// Counts handles
+// GET /v1/admin/redactions - values redacted at ingest since startup, per tenant, service and rule;
+// admins of other tenants than the default one only see their own
+func (h *RedactionHandler) Counts(c *gin.Context) {
+	counts := []models.RedactionCount{}
+	if h.redactor != nil {
+		counts = h.redactor.Counts(auth.ManagedTenant(c))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": counts, "enabled": h.redactor != nil && h.redactor.Enabled()})
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/models"
+)
+
+type RoleHandler struct {
+	authSvc *auth.Service
+}
+
+func NewRoleHandler(authSvc *auth.Service) *RoleHandler {
+	return &RoleHandler{authSvc: authSvc}
+}
+
+// CreateRole handles
+// POST /v1/admin/roles - creates a read policy that can be assigned to keys
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if auth.ManagedTenant(c) != "" || req.TenantID == "" {
+		req.TenantID = auth.TenantFromContext(c)
+	}
+
+	role, err := h.authSvc.CreateRole(c.Request.Context(), req)
+	if errors.Is(err, auth.ErrInvalidRole) || errors.Is(err, auth.ErrInvalidTenant) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// ListRoles handles
+// GET /v1/admin/roles - lists the roles of the caller's tenant
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.authSvc.ListRoles(c.Request.Context(), auth.ManagedTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+// UpdateRole handles
+// PUT /v1/admin/roles/:id - replaces a role; keys using it are affected immediately
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, ok := pathID(c, "Invalid role id")
+	if !ok {
+		return
+	}
+
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.authSvc.UpdateRole(c.Request.Context(), auth.ManagedTenant(c), id, req)
+	if errors.Is(err, auth.ErrInvalidRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, helpers.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole handles
+// DELETE /v1/admin/roles/:id - deletes a role that no key uses
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, ok := pathID(c, "Invalid role id")
+	if !ok {
+		return
+	}
+
+	err := h.authSvc.DeleteRole(c.Request.Context(), auth.ManagedTenant(c), id)
+	if errors.Is(err, helpers.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if errors.Is(err, helpers.ErrInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is assigned to API keys"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetKeyRole handles
+// PUT /v1/admin/keys/:id/role - assigns a role to an API key; a null role_id removes it
+func (h *RoleHandler) SetKeyRole(c *gin.Context) {
+	id, ok := pathID(c, "Invalid API key id")
+	if !ok {
+		return
+	}
+
+	var req models.SetAPIKeyRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := h.authSvc.SetKeyRole(c.Request.Context(), auth.ManagedTenant(c), id, req.RoleID)
+	if errors.Is(err, auth.ErrInvalidRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, helpers.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set API key role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
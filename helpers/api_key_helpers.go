@@ -0,0 +1,230 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/models"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when a record conflicts with a unique one
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+const apiKeyColumns = `id, tenant_id, name, prefix, scopes, created_at, expires_at, revoked_at, last_used_at, rotated_from, role_id, cert_subject`
+
+// APIKeyHelper contains database operations for API keys
+type APIKeyHelper struct {
+	db *pgxpool.Pool
+}
+
+// NewAPIKeyHelper creates a new APIKeyHelper instance
+func NewAPIKeyHelper(db *pgxpool.Pool) *APIKeyHelper {
+	return &APIKeyHelper{db: db}
+}
+
+// CreateAPIKey stores a new key with the hash of its secret
+func (h *APIKeyHelper) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error) {
+	return insertAPIKey(ctx, h.db, key, keyHash)
+}
+
+// GetAPIKeyByPrefix looks up a key and its secret hash by the token prefix
+func (h *APIKeyHelper) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, string, error) {
+	row := h.db.QueryRow(ctx, `SELECT `+apiKeyColumns+`, key_hash FROM api_keys WHERE prefix = $1`, prefix)
+
+	var key models.APIKey
+	var keyHash string
+	err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedAt,
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.RotatedFrom, &key.RoleID, &key.CertSubject, &keyHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get api key: %w", err)
+	}
+	return &key, keyHash, nil
+}
+
+// GetAPIKeyByCertSubject returns the active key mapped to one of subjects,
+// preferring earlier subjects
+func (h *APIKeyHelper) GetAPIKeyByCertSubject(ctx context.Context, subjects []string) (*models.APIKey, error) {
+	key, err := scanAPIKey(h.db.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE cert_subject = ANY($1) AND revoked_at IS NULL
+		ORDER BY array_position($1, cert_subject)
+		LIMIT 1`, subjects))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key by certificate subject: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns the keys of a tenant, newest first. An empty tenantID
+// matches every tenant.
+func (h *APIKeyHelper) ListAPIKeys(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+	where, args := tenantClause("WHERE", tenantID, nil)
+	rows, err := h.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys`+where+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey marks a key of a tenant as revoked. An empty tenantID
+// matches every tenant.
+func (h *APIKeyHelper) RevokeAPIKey(ctx context.Context, tenantID string, id int64) (*models.APIKey, error) {
+	where, args := tenantClause("AND", tenantID, []interface{}{id})
+	row := h.db.QueryRow(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1`+where+`
+		RETURNING `+apiKeyColumns, args...)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// RotateAPIKey stores a replacement for oldID and sets the old key to
+// expire at oldExpiresAt, in a single transaction. The replacement takes
+// over the old key's certificate subject. An empty tenantID matches keys of
+// every tenant.
+func (h *APIKeyHelper) RotateAPIKey(ctx context.Context, tenantID string, oldID int64, key models.APIKey, keyHash string, oldExpiresAt time.Time) (*models.APIKey, error) {
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx) // no-op after commit
+	}()
+
+	where, args := tenantClause("AND", tenantID, []interface{}{oldID, oldExpiresAt})
+	tag, err := tx.Exec(ctx, `
+		UPDATE api_keys
+		SET expires_at = LEAST(COALESCE(expires_at, $2), $2), cert_subject = NULL
+		WHERE id = $1 AND revoked_at IS NULL`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNotFound
+	}
+
+	key.RotatedFrom = &oldID
+	created, err := insertAPIKey(ctx, tx, key, keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
+// SetAPIKeyRole assigns a role to a key of a tenant, or removes it when
+// roleID is nil. An empty tenantID matches every tenant.
+func (h *APIKeyHelper) SetAPIKeyRole(ctx context.Context, tenantID string, id int64, roleID *int64) (*models.APIKey, error) {
+	where, args := tenantClause("AND", tenantID, []interface{}{id, roleID})
+	key, err := scanAPIKey(h.db.QueryRow(ctx, `
+		UPDATE api_keys SET role_id = $2
+		WHERE id = $1`+where+`
+		RETURNING `+apiKeyColumns, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set api key role: %w", err)
+	}
+	return key, nil
+}
+
+// GetAPIKey returns a key of a tenant by id. An empty tenantID matches
+// every tenant.
+func (h *APIKeyHelper) GetAPIKey(ctx context.Context, tenantID string, id int64) (*models.APIKey, error) {
+	where, args := tenantClause("AND", tenantID, []interface{}{id})
+	key, err := scanAPIKey(h.db.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`+where, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// TouchAPIKey records that a key was just used
+func (h *APIKeyHelper) TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := h.db.Exec(ctx, `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to update api key usage: %w", err)
+	}
+	return nil
+}
+
+// tenantClause returns a condition restricting rows to tenantID, starting
+// with keyword (WHERE or AND), and args with the tenant appended. It returns
+// no condition for an empty tenantID, which matches every tenant.
+func tenantClause(keyword, tenantID string, args []interface{}) (string, []interface{}) {
+	if tenantID == "" {
+		return "", args
+	}
+	args = append(args, tenantID)
+	return fmt.Sprintf(" %s tenant_id = $%d", keyword, len(args)), args
+}
+
+// querier is satisfied by both the pool and a transaction
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+func insertAPIKey(ctx context.Context, q querier, key models.APIKey, keyHash string) (*models.APIKey, error) {
+	row := q.QueryRow(ctx, `
+		INSERT INTO api_keys (tenant_id, name, prefix, key_hash, scopes, expires_at, rotated_from, role_id, cert_subject)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+apiKeyColumns,
+		key.TenantID, key.Name, key.Prefix, keyHash, key.Scopes, key.ExpiresAt, key.RotatedFrom, key.RoleID, key.CertSubject)
+
+	created, err := scanAPIKey(row)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyExists, pgErr.Detail)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+	return created, nil
+}
+
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedAt,
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.RotatedFrom, &key.RoleID, &key.CertSubject)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,173 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/models"
+	"github.com/yunjin08/logscale/pkg/pagination"
+)
+
+// auditLockKey is the advisory lock serializing appends to the audit chain
+const auditLockKey = 0x6c6f6761756469 // "logaudi"
+
+const auditEventColumns = `id, tenant_id, occurred_at, actor_key_id, actor, action, resource, status, details, prev_hash, hash`
+
+type AuditHelper struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditHelper(db *pgxpool.Pool) *AuditHelper {
+	return &AuditHelper{db: db}
+}
+
+// AppendAuditEvent chains event to the newest stored event and stores it
+func (h *AuditHelper) AppendAuditEvent(ctx context.Context, event models.AuditEvent) (*models.AuditEvent, error) {
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(auditLockKey)); err != nil {
+		return nil, fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&event.PrevHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		event.PrevHash = models.GenesisHash
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+
+	// Postgres keeps microseconds; hash what will be stored
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+	if len(event.Details) == 0 {
+		event.Details = []byte(`{}`)
+	}
+	event.Hash = event.ComputeHash()
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO audit_events (tenant_id, occurred_at, actor_key_id, actor, action, resource, status, details, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		event.TenantID, event.OccurredAt, event.ActorKeyID, event.Actor, event.Action,
+		event.Resource, event.Status, event.Details, event.PrevHash, event.Hash,
+	).Scan(&event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert audit event: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit audit event: %w", err)
+	}
+	return &event, nil
+}
+
+// QueryAuditEvents returns audit events matching query, newest first. An
+// empty query.TenantID matches every tenant.
+func (h *AuditHelper) QueryAuditEvents(ctx context.Context, query models.AuditQuery, pagination pagination.Pagination) ([]models.AuditEvent, int64, error) {
+	whereClause := "WHERE TRUE"
+	args := []interface{}{}
+	argCount := 1
+
+	if query.TenantID != "" {
+		whereClause += fmt.Sprintf(" AND tenant_id = $%d", argCount)
+		args = append(args, query.TenantID)
+		argCount++
+	}
+
+	if query.ActorKeyID != nil {
+		whereClause += fmt.Sprintf(" AND actor_key_id = $%d", argCount)
+		args = append(args, *query.ActorKeyID)
+		argCount++
+	}
+
+	if query.Action != "" {
+		whereClause += fmt.Sprintf(" AND action = $%d", argCount)
+		args = append(args, query.Action)
+		argCount++
+	}
+
+	if query.From != "" {
+		whereClause += fmt.Sprintf(" AND occurred_at >= $%d", argCount)
+		args = append(args, query.From)
+		argCount++
+	}
+
+	if query.To != "" {
+		whereClause += fmt.Sprintf(" AND occurred_at <= $%d", argCount)
+		args = append(args, query.To)
+		argCount++
+	}
+
+	var total int64
+	err := h.db.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM audit_events %s", whereClause), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	args = append(args, pagination.GetLimit(), pagination.GetOffset())
+	rows, err := h.db.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM audit_events %s
+		ORDER BY id DESC
+		LIMIT $%d OFFSET $%d
+	`, auditEventColumns, whereClause, argCount, argCount+1), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// ForEachAuditEvent calls fn with every audit event in chain order,
+// stopping early when fn returns false
+func (h *AuditHelper) ForEachAuditEvent(ctx context.Context, fn func(models.AuditEvent) bool) error {
+	rows, err := h.db.Query(ctx, `SELECT `+auditEventColumns+` FROM audit_events ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if !fn(*event) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating audit events: %w", err)
+	}
+	return nil
+}
+
+func scanAuditEvent(row pgx.Row) (*models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := row.Scan(&e.ID, &e.TenantID, &e.OccurredAt, &e.ActorKeyID, &e.Actor, &e.Action,
+		&e.Resource, &e.Status, &e.Details, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
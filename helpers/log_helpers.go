@@ -3,10 +3,12 @@ package helpers
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
 	"github.com/yunjin08/logscale/models"
 	"github.com/yunjin08/logscale/pkg/pagination"
 )
@@ -21,27 +23,30 @@ func NewLogHelper(db *pgxpool.Pool) *LogHelper {
 	return &LogHelper{db: db}
 }
 
-// CreateSingleLog creates a single log entry in the database
-func (h *LogHelper) CreateSingleLog(ctx context.Context, req models.LogRequest) (*models.Log, error) {
+// CreateSingleLog creates a single log entry for a tenant in the database
+func (h *LogHelper) CreateSingleLog(ctx context.Context, tenantID string, req models.LogRequest) (*models.Log, error) {
+	defer metrics.ObserveDBQuery("insert", time.Now())
+
 	timestamp := time.Now()
 	if req.Timestamp != nil {
 		timestamp = *req.Timestamp
 	}
 
 	query := `
-		INSERT INTO logs (service, level, message, timestamp, meta)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, service, level, message, timestamp, meta
+		INSERT INTO logs (tenant_id, service, level, message, timestamp, meta)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, tenant_id, service, level, message, timestamp, meta
 	`
 
 	var log models.Log
 	err := h.db.QueryRow(ctx, query,
+		tenantID,
 		req.Service,
 		req.Level,
 		req.Message,
 		timestamp,
 		req.Meta,
-	).Scan(&log.ID, &log.Service, &log.Level, &log.Message, &log.Timestamp, &log.Meta)
+	).Scan(&log.ID, &log.TenantID, &log.Service, &log.Level, &log.Message, &log.Timestamp, &log.Meta)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create log: %w", err)
@@ -50,8 +55,10 @@ func (h *LogHelper) CreateSingleLog(ctx context.Context, req models.LogRequest)
 	return &log, nil
 }
 
-// CreateBatchLogs creates multiple log entries in a single transaction
-func (h *LogHelper) CreateBatchLogs(ctx context.Context, requests []models.LogRequest) ([]models.Log, error) {
+// CreateBatchLogs creates multiple log entries for a tenant in a single transaction
+func (h *LogHelper) CreateBatchLogs(ctx context.Context, tenantID string, requests []models.LogRequest) ([]models.Log, error) {
+	defer metrics.ObserveDBQuery("insert_batch", time.Now())
+
 	tx, err := h.db.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -60,14 +67,14 @@ func (h *LogHelper) CreateBatchLogs(ctx context.Context, requests []models.LogRe
 		if err := tx.Rollback(ctx); err != nil {
 			// Log the rollback error, but don't return it
 			// because we're in a defer function
-			log.Printf("warning: rollback failed: %v\n", err)
+			slog.WarnContext(ctx, "failed to roll back log batch", logging.Err(err))
 		}
 	}()
 
 	query := `
-		INSERT INTO logs (service, level, message, timestamp, meta)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, service, level, message, timestamp, meta
+		INSERT INTO logs (tenant_id, service, level, message, timestamp, meta)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, tenant_id, service, level, message, timestamp, meta
 	`
 
 	var logs []models.Log
@@ -79,12 +86,13 @@ func (h *LogHelper) CreateBatchLogs(ctx context.Context, requests []models.LogRe
 
 		var log models.Log
 		err := tx.QueryRow(ctx, query,
+			tenantID,
 			req.Service,
 			req.Level,
 			req.Message,
 			timestamp,
 			req.Meta,
-		).Scan(&log.ID, &log.Service, &log.Level, &log.Message, &log.Timestamp, &log.Meta)
+		).Scan(&log.ID, &log.TenantID, &log.Service, &log.Level, &log.Message, &log.Timestamp, &log.Meta)
 
 		if err != nil {
 			return nil, fmt.Errorf("failed to create log in batch: %w", err)
@@ -100,12 +108,31 @@ func (h *LogHelper) CreateBatchLogs(ctx context.Context, requests []models.LogRe
 	return logs, nil
 }
 
-// QueryLogs retrieves logs with filtering and pagination
-func (h *LogHelper) QueryLogs(ctx context.Context, query models.LogQuery, pagination pagination.Pagination) ([]models.Log, int64, error) {
-	// Build WHERE clause
-	whereClause := "WHERE 1=1"
-	args := []interface{}{}
-	argCount := 1
+// QueryLogs retrieves a tenant's logs with filtering and pagination. A
+// non-nil policy limits the services returned and hides or redacts meta keys
+// the caller may not read. Setting both StartTime and EndTime limits the
+// scan to the daily partitions they cover.
+func (h *LogHelper) QueryLogs(ctx context.Context, tenantID string, policy *models.AccessPolicy, query models.LogQuery, pagination pagination.Pagination) ([]models.Log, int64, error) {
+	defer metrics.ObserveDBQuery("query", time.Now())
+
+	// Build WHERE clause; the tenant filter is always applied
+	whereClause := "WHERE tenant_id = $1"
+	args := []interface{}{tenantID}
+	argCount := 2
+
+	if likes := policy.ServiceLikePatterns(); likes != nil {
+		whereClause += fmt.Sprintf(" AND service LIKE ANY($%d)", argCount)
+		args = append(args, likes)
+		argCount++
+	}
+
+	if policy.RestrictsMeta() && policy.MetaMode == models.MetaModeDeny {
+		// Hide logs carrying any meta key outside the allowed set
+		whereClause += fmt.Sprintf(` AND NOT (jsonb_typeof(meta) = 'object' AND EXISTS (
+			SELECT 1 FROM jsonb_object_keys(meta) AS k WHERE k <> ALL($%d)))`, argCount)
+		args = append(args, policy.MetaFields)
+		argCount++
+	}
 
 	if query.Service != "" {
 		whereClause += fmt.Sprintf(" AND service = $%d", argCount)
@@ -143,7 +170,7 @@ func (h *LogHelper) QueryLogs(ctx context.Context, query models.LogQuery, pagina
 	args = append(args, pagination.GetLimit(), pagination.GetOffset())
 
 	dataQuery := fmt.Sprintf(`
-		SELECT id, service, level, message, timestamp, meta
+		SELECT id, tenant_id, service, level, message, timestamp, meta
 		FROM logs %s
 		ORDER BY timestamp DESC
 		LIMIT $%d OFFSET $%d
@@ -158,10 +185,11 @@ func (h *LogHelper) QueryLogs(ctx context.Context, query models.LogQuery, pagina
 	var logs []models.Log
 	for rows.Next() {
 		var log models.Log
-		err := rows.Scan(&log.ID, &log.Service, &log.Level, &log.Message, &log.Timestamp, &log.Meta)
+		err := rows.Scan(&log.ID, &log.TenantID, &log.Service, &log.Level, &log.Message, &log.Timestamp, &log.Meta)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan log: %w", err)
 		}
+		log.Meta = policy.RedactMeta(log.Meta)
 		logs = append(logs, log)
 	}
 
@@ -171,8 +199,3 @@ func (h *LogHelper) QueryLogs(ctx context.Context, query models.LogQuery, pagina
 
 	return logs, total, nil
 }
-
-// PingDatabase checks database connectivity
-func (h *LogHelper) PingDatabase(ctx context.Context) error {
-	return h.db.Ping(ctx)
-}
@@ -0,0 +1,217 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/models"
+)
+
+const quotaColumns = `id, tenant_id, service, soft_lines, soft_bytes, hard_lines, hard_bytes, hard_action, sample_rate, created_at, updated_at`
+
+// QuotaHelper contains database operations for quotas and ingest usage
+type QuotaHelper struct {
+	db *pgxpool.Pool
+}
+
+// NewQuotaHelper creates a new QuotaHelper instance
+func NewQuotaHelper(db *pgxpool.Pool) *QuotaHelper {
+	return &QuotaHelper{db: db}
+}
+
+// UpsertQuota creates the quota for a tenant and service, or replaces it
+func (h *QuotaHelper) UpsertQuota(ctx context.Context, q models.Quota) (*models.Quota, error) {
+	row := h.db.QueryRow(ctx, `
+		INSERT INTO quotas (tenant_id, service, soft_lines, soft_bytes, hard_lines, hard_bytes, hard_action, sample_rate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id, service) DO UPDATE
+		SET soft_lines = EXCLUDED.soft_lines, soft_bytes = EXCLUDED.soft_bytes,
+		    hard_lines = EXCLUDED.hard_lines, hard_bytes = EXCLUDED.hard_bytes,
+		    hard_action = EXCLUDED.hard_action, sample_rate = EXCLUDED.sample_rate,
+		    updated_at = NOW()
+		RETURNING `+quotaColumns,
+		q.TenantID, q.Service, q.SoftLines, q.SoftBytes, q.HardLines, q.HardBytes, q.HardAction, q.SampleRate)
+
+	quota, err := scanQuota(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save quota: %w", err)
+	}
+	return quota, nil
+}
+
+// ListQuotas returns every quota ordered by tenant and service
+func (h *QuotaHelper) ListQuotas(ctx context.Context) ([]models.Quota, error) {
+	rows, err := h.db.Query(ctx, `SELECT `+quotaColumns+` FROM quotas ORDER BY tenant_id, service`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quotas: %w", err)
+	}
+	defer rows.Close()
+
+	quotas := []models.Quota{}
+	for rows.Next() {
+		quota, err := scanQuota(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan quota: %w", err)
+		}
+		quotas = append(quotas, *quota)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating quotas: %w", err)
+	}
+	return quotas, nil
+}
+
+// DeleteQuota removes a quota of a tenant. An empty tenantID matches every
+// tenant.
+func (h *QuotaHelper) DeleteQuota(ctx context.Context, tenantID string, id int64) error {
+	where, args := tenantClause("AND", tenantID, []interface{}{id})
+	tag, err := h.db.Exec(ctx, `DELETE FROM quotas WHERE id = $1`+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete quota: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetDailyUsage returns the lines and bytes ingested on day (YYYY-MM-DD) by
+// a tenant, or by one of its services when service is not empty
+func (h *QuotaHelper) GetDailyUsage(ctx context.Context, tenantID, service, day string) (lines, bytes int64, err error) {
+	err = h.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(lines), 0), COALESCE(SUM(bytes), 0)
+		FROM ingest_usage
+		WHERE tenant_id = $1 AND day = $2::date AND ($3 = '' OR service = $3)
+	`, tenantID, day, service).Scan(&lines, &bytes)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return lines, bytes, nil
+}
+
+// ReserveUsage atomically adds every reservation to the usage of its quota
+// on day (YYYY-MM-DD), unless one would take its quota past a hard limit.
+// Then nothing is added and that quota is returned.
+func (h *QuotaHelper) ReserveUsage(ctx context.Context, tenantID, day string, reservations []models.QuotaReservation) (*models.Quota, error) {
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, r := range reservations {
+		ok, err := reserveQuota(ctx, tx, tenantID, day, r)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			q := r.Quota
+			return &q, nil
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit quota usage: %w", err)
+	}
+	return nil, nil
+}
+
+// reserveQuota adds a reservation to its quota's usage on day and reports
+// whether it fit under the hard limits. The row lock taken by the UPDATE
+// makes concurrent reservations wait for each other.
+func reserveQuota(ctx context.Context, tx pgx.Tx, tenantID, day string, r models.QuotaReservation) (bool, error) {
+	reserve := func() (bool, error) {
+		tag, err := tx.Exec(ctx, `
+			UPDATE quota_usage
+			SET lines = lines + $3, bytes = bytes + $4
+			WHERE quota_id = $1 AND day = $2::date
+			  AND ($5::bigint = 0 OR lines + $3 <= $5::bigint)
+			  AND ($6::bigint = 0 OR bytes + $4 <= $6::bigint)
+		`, r.Quota.ID, day, r.Lines, r.Bytes, r.Quota.HardLines, r.Quota.HardBytes)
+		if err != nil {
+			return false, fmt.Errorf("failed to reserve quota usage: %w", err)
+		}
+		return tag.RowsAffected() == 1, nil
+	}
+
+	ok, err := reserve()
+	if ok || err != nil {
+		return ok, err
+	}
+
+	// Either over the limit or the first reservation of the day. Usage
+	// starts from what was already ingested that day, for quotas created
+	// during it.
+	_, err = tx.Exec(ctx, `
+		INSERT INTO quota_usage (quota_id, day, lines, bytes)
+		SELECT $1, $2::date, COALESCE(SUM(lines), 0), COALESCE(SUM(bytes), 0)
+		FROM ingest_usage
+		WHERE tenant_id = $3 AND day = $2::date AND ($4 = '' OR service = $4)
+		ON CONFLICT (quota_id, day) DO NOTHING
+	`, r.Quota.ID, day, tenantID, r.Quota.Service)
+	if err != nil {
+		return false, fmt.Errorf("failed to start quota usage: %w", err)
+	}
+	return reserve()
+}
+
+// AddUsage adds stored and dropped volume for a service on day (YYYY-MM-DD)
+func (h *QuotaHelper) AddUsage(ctx context.Context, tenantID, service, day string, lines, bytes, dropped int64) error {
+	_, err := h.db.Exec(ctx, `
+		INSERT INTO ingest_usage (tenant_id, service, day, lines, bytes, dropped_lines)
+		VALUES ($1, $2, $3::date, $4, $5, $6)
+		ON CONFLICT (tenant_id, day, service) DO UPDATE
+		SET lines = ingest_usage.lines + EXCLUDED.lines,
+		    bytes = ingest_usage.bytes + EXCLUDED.bytes,
+		    dropped_lines = ingest_usage.dropped_lines + EXCLUDED.dropped_lines,
+		    updated_at = NOW()
+	`, tenantID, service, day, lines, bytes, dropped)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// QueryUsage returns a tenant's usage per day and service between the days
+// from and to (YYYY-MM-DD, inclusive)
+func (h *QuotaHelper) QueryUsage(ctx context.Context, tenantID, service, from, to string) ([]models.Usage, error) {
+	rows, err := h.db.Query(ctx, `
+		SELECT to_char(day, 'YYYY-MM-DD'), service, lines, bytes, dropped_lines
+		FROM ingest_usage
+		WHERE tenant_id = $1 AND day BETWEEN $2::date AND $3::date AND ($4 = '' OR service = $4)
+		ORDER BY day DESC, service
+	`, tenantID, from, to, service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []models.Usage{}
+	for rows.Next() {
+		var u models.Usage
+		if err := rows.Scan(&u.Day, &u.Service, &u.Lines, &u.Bytes, &u.DroppedLines); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage: %w", err)
+	}
+	return usage, nil
+}
+
+func scanQuota(row pgx.Row) (*models.Quota, error) {
+	var q models.Quota
+	err := row.Scan(&q.ID, &q.TenantID, &q.Service, &q.SoftLines, &q.SoftBytes, &q.HardLines,
+		&q.HardBytes, &q.HardAction, &q.SampleRate, &q.CreatedAt, &q.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/models"
+)
+
+// ErrInUse is returned when deleting a record that others still reference
+var ErrInUse = errors.New("in use")
+
+const roleColumns = `id, tenant_id, name, service_patterns, meta_fields, meta_mode, created_at, updated_at`
+
+// RoleHelper contains database operations for roles
+type RoleHelper struct {
+	db *pgxpool.Pool
+}
+
+// NewRoleHelper creates a new RoleHelper instance
+func NewRoleHelper(db *pgxpool.Pool) *RoleHelper {
+	return &RoleHelper{db: db}
+}
+
+// CreateRole stores a new role
+func (h *RoleHelper) CreateRole(ctx context.Context, role models.Role) (*models.Role, error) {
+	created, err := scanRole(h.db.QueryRow(ctx, `
+		INSERT INTO roles (tenant_id, name, service_patterns, meta_fields, meta_mode)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+roleColumns,
+		role.TenantID, role.Name, nonNil(role.ServicePatterns), role.MetaFields, role.MetaMode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateRole replaces the name and policy of a role of a tenant. An empty
+// tenantID matches every tenant.
+func (h *RoleHelper) UpdateRole(ctx context.Context, tenantID string, role models.Role) (*models.Role, error) {
+	where, args := tenantClause("AND", tenantID, []interface{}{
+		role.ID, role.Name, nonNil(role.ServicePatterns), role.MetaFields, role.MetaMode,
+	})
+	updated, err := scanRole(h.db.QueryRow(ctx, `
+		UPDATE roles
+		SET name = $2, service_patterns = $3, meta_fields = $4, meta_mode = $5, updated_at = NOW()
+		WHERE id = $1`+where+`
+		RETURNING `+roleColumns, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+	return updated, nil
+}
+
+// GetRole returns a role of a tenant by id. An empty tenantID matches every
+// tenant.
+func (h *RoleHelper) GetRole(ctx context.Context, tenantID string, id int64) (*models.Role, error) {
+	where, args := tenantClause("AND", tenantID, []interface{}{id})
+	role, err := scanRole(h.db.QueryRow(ctx, `SELECT `+roleColumns+` FROM roles WHERE id = $1`+where, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+// ListRoles returns the roles of a tenant ordered by name. An empty tenantID
+// returns every tenant's roles.
+func (h *RoleHelper) ListRoles(ctx context.Context, tenantID string) ([]models.Role, error) {
+	where, args := tenantClause("WHERE", tenantID, nil)
+	rows, err := h.db.Query(ctx, `SELECT `+roleColumns+` FROM roles`+where+` ORDER BY tenant_id, name`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, *role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating roles: %w", err)
+	}
+	return roles, nil
+}
+
+// DeleteRole removes a role of a tenant that no API key uses. An empty
+// tenantID matches every tenant.
+func (h *RoleHelper) DeleteRole(ctx context.Context, tenantID string, id int64) error {
+	where, args := tenantClause("AND", tenantID, []interface{}{id})
+	tag, err := h.db.Exec(ctx, `DELETE FROM roles WHERE id = $1`+where, args...)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+		return ErrInUse
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanRole(row pgx.Row) (*models.Role, error) {
+	var r models.Role
+	err := row.Scan(&r.ID, &r.TenantID, &r.Name, &r.ServicePatterns, &r.MetaFields, &r.MetaMode, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// nonNil turns a nil slice into an empty one for NOT NULL array columns
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// maxQueuedBatches bounds how many undeliverable batches are held in memory
+// before the tailer is paused
+const maxQueuedBatches = 10
+
+// levelPattern finds a level keyword in an unstructured log line
+var levelPattern = regexp.MustCompile(`(?i)\b(trace|debug|info|notice|warn|warning|error|err|fatal|critical|panic)\b`)
+
+// Config holds the agent settings
+type Config struct {
+	Paths               []string
+	Service             string
+	Endpoint            string
+	APIKey              string
+	CheckpointFile      string
+	BufferDir           string
+	MaxBufferBytes      int64
+	BatchSize           int
+	FlushInterval       time.Duration
+	PollInterval        time.Duration
+	MultilineTimeout    time.Duration
+	ContinuationPattern string
+	MaxRetries          int
+	RetryBackoff        time.Duration
+}
+
+// Agent tails files and ships their records to the LogScale API
+type Agent struct {
+	config     Config
+	hostname   string
+	checkpoint *Checkpoint
+	tailer     *Tailer
+	shipper    *Shipper
+}
+
+// New creates an agent from its configuration
+func New(config Config) (*Agent, error) {
+	if len(config.Paths) == 0 {
+		return nil, fmt.Errorf("at least one path is required")
+	}
+	if config.Service == "" {
+		return nil, fmt.Errorf("service is required")
+	}
+
+	var continuation *regexp.Regexp
+	if config.ContinuationPattern != "" {
+		var err error
+		continuation, err = regexp.Compile(config.ContinuationPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid continuation pattern: %w", err)
+		}
+	}
+
+	checkpoint, err := LoadCheckpoint(config.CheckpointFile)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer, err := NewDiskBuffer(config.BufferDir, config.MaxBufferBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &Agent{
+		config:     config,
+		hostname:   hostname,
+		checkpoint: checkpoint,
+		tailer:     NewTailer(config.Paths, config.PollInterval, config.MultilineTimeout, continuation, checkpoint),
+		shipper:    NewShipper(config.Endpoint, config.APIKey, config.MaxRetries, config.RetryBackoff, buffer),
+	}, nil
+}
+
+// Run tails and ships logs until ctx is cancelled, then flushes what it has
+func (a *Agent) Run(ctx context.Context) error {
+	records := make(chan Record, a.config.BatchSize)
+
+	tailCtx, stopTailer := context.WithCancel(ctx)
+	defer stopTailer()
+	go a.tailer.Run(tailCtx, records)
+
+	ticker := time.NewTicker(a.config.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []Record
+	for {
+		// Stop reading while too many undelivered records are queued
+		in := records
+		if len(batch) >= a.config.BatchSize*maxQueuedBatches {
+			in = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			a.flush(shutdownCtx, batch)
+			return a.checkpoint.Save()
+		case rec := <-in:
+			batch = append(batch, rec)
+			if len(batch) >= a.config.BatchSize {
+				batch = a.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = a.flush(ctx, batch)
+		}
+	}
+}
+
+// flush ships the batch and commits its offsets. Records that could not be
+// delivered or buffered are returned to be retried.
+func (a *Agent) flush(ctx context.Context, batch []Record) []Record {
+	if len(batch) == 0 {
+		a.shipper.Replay(ctx)
+		return batch
+	}
+
+	for len(batch) > 0 {
+		n := min(len(batch), a.config.BatchSize)
+		if err := a.shipper.Ship(ctx, a.toLogRequests(batch[:n])); err != nil {
+			log.Printf("error: %v", err)
+			break
+		}
+
+		a.checkpoint.Commit(batch[:n])
+		batch = batch[n:]
+	}
+
+	if err := a.checkpoint.Save(); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	return batch
+}
+
+// toLogRequests converts records into API log requests
+func (a *Agent) toLogRequests(records []Record) []models.LogRequest {
+	reqs := make([]models.LogRequest, len(records))
+	for i, rec := range records {
+		meta, _ := json.Marshal(map[string]string{
+			"file": rec.Path,
+			"host": a.hostname,
+		})
+		ts := rec.Time
+		reqs[i] = models.LogRequest{
+			Service:   a.config.Service,
+			Level:     DetectLevel(rec.Line),
+			Message:   rec.Line,
+			Timestamp: &ts,
+			Meta:      meta,
+		}
+	}
+	return reqs
+}
+
+// DetectLevel guesses the level of an unstructured line from the first
+// level keyword in it, defaulting to info
+func DetectLevel(line string) string {
+	first, _, _ := strings.Cut(line, "\n")
+	if level, ok := models.NormalizeLevel(levelPattern.FindString(first)); ok {
+		return level
+	}
+	return models.LevelInfo
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const bufferSuffix = ".json.gz"
+
+// DiskBuffer stores encoded batches on disk while the API is unreachable.
+// Batches are kept as individual files named by creation time so they can
+// be replayed in order; the oldest are dropped when maxBytes is exceeded.
+type DiskBuffer struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+}
+
+// NewDiskBuffer creates the buffer directory if needed
+func NewDiskBuffer(dir string, maxBytes int64) (*DiskBuffer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+	return &DiskBuffer{dir: dir, maxBytes: maxBytes}, nil
+}
+
+// Put writes a batch payload to the buffer
+func (b *DiskBuffer) Put(payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.makeRoom(int64(len(payload))); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%020d%s", time.Now().UnixNano(), bufferSuffix)
+	tmp := filepath.Join(b.dir, name+".tmp")
+	if err := os.WriteFile(tmp, payload, 0o600); err != nil {
+		return fmt.Errorf("failed to write buffered batch: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(b.dir, name)); err != nil {
+		return fmt.Errorf("failed to commit buffered batch: %w", err)
+	}
+	return nil
+}
+
+// Pending returns the buffered batch files, oldest first
+func (b *DiskBuffer) Pending() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.list()
+}
+
+// Read returns the payload of a buffered batch
+func (b *DiskBuffer) Read(path string) ([]byte, error) {
+	return os.ReadFile(path) // #nosec G304 -- path is listed from the buffer directory
+}
+
+// Remove deletes a buffered batch once it has been delivered
+func (b *DiskBuffer) Remove(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return os.Remove(path)
+}
+
+func (b *DiskBuffer) list() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buffer: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), bufferSuffix) {
+			files = append(files, filepath.Join(b.dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// makeRoom drops the oldest batches until size more bytes fit
+func (b *DiskBuffer) makeRoom(size int64) error {
+	if size > b.maxBytes {
+		return fmt.Errorf("batch of %d bytes exceeds buffer size %d", size, b.maxBytes)
+	}
+
+	files, err := b.list()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	sizes := make([]int64, len(files))
+	for i, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+
+	for i := 0; total+size > b.maxBytes && i < len(files); i++ {
+		log.Printf("warning: buffer full, dropping oldest batch %s", filepath.Base(files[i]))
+		if err := os.Remove(files[i]); err != nil {
+			return fmt.Errorf("failed to drop buffered batch: %w", err)
+		}
+		total -= sizes[i]
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileState is the persisted read position of a tailed file
+type FileState struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// Checkpoint tracks how far each file has been shipped and persists it
+// so the agent resumes where it left off after a restart
+type Checkpoint struct {
+	path  string
+	mu    sync.Mutex
+	files map[string]FileState
+}
+
+// LoadCheckpoint reads the checkpoint file at path. A missing file yields an empty checkpoint.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{path: path, files: make(map[string]FileState)}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from agent configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cp.files); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// Get returns the saved state for a file
+func (c *Checkpoint) Get(path string) (FileState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.files[path]
+	return st, ok
+}
+
+// Track records that path now refers to a new file, e.g. after rotation
+func (c *Checkpoint) Track(path string, inode uint64, offset int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = FileState{Inode: inode, Offset: offset}
+}
+
+// Commit advances the offsets of the files the shipped records came from.
+// Records from a file that has since been rotated away are ignored.
+func (c *Checkpoint) Commit(records []Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range records {
+		st, ok := c.files[r.Path]
+		if ok && st.Inode != r.Inode {
+			continue
+		}
+		if r.Offset > st.Offset || !ok {
+			c.files[r.Path] = FileState{Inode: r.Inode, Offset: r.Offset}
+		}
+	}
+}
+
+// Forget drops the state of a file that no longer exists
+func (c *Checkpoint) Forget(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.files, path)
+}
+
+// Save atomically writes the checkpoint to disk
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.files, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint: %w", err)
+	}
+	return nil
+}
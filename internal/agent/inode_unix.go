@@ -0,0 +1,16 @@
+//go:build !windows
+
+package agent
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the inode number used to recognise a file across renames
+func inodeOf(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino) // #nosec G115 -- inode numbers are non-negative
+	}
+	return 0
+}
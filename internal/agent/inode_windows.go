@@ -0,0 +1,10 @@
+//go:build windows
+
+package agent
+
+import "os"
+
+// inodeOf is unavailable on Windows; rotation is detected by truncation only
+func inodeOf(_ os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// maxRecordLines caps how many lines a multi-line record may collect
+	maxRecordLines = 500
+	// maxRecordBytes caps the size of a multi-line record
+	maxRecordBytes = 1 << 20
+)
+
+// DefaultContinuationPattern matches the follow-up lines of Java, Python and
+// Go stack traces: indented frames, "Caused by:" and "... N more"
+const DefaultContinuationPattern = `^(\s+|Caused by:|\.\.\. \d+ more|Traceback|goroutine \d+ \[)`
+
+// multiline joins continuation lines onto the record that precedes them
+type multiline struct {
+	continuation *regexp.Regexp
+	pending      *Record
+	lines        int
+	updated      time.Time
+}
+
+func newMultiline(continuation *regexp.Regexp) *multiline {
+	return &multiline{continuation: continuation}
+}
+
+// add feeds a line into the aggregator and returns a record if the line
+// completed the previous one
+func (m *multiline) add(rec Record) *Record {
+	if m.pending != nil && m.continuation != nil && m.continuation.MatchString(rec.Line) &&
+		m.lines < maxRecordLines && len(m.pending.Line)+len(rec.Line) < maxRecordBytes {
+		var b strings.Builder
+		b.Grow(len(m.pending.Line) + len(rec.Line) + 1)
+		b.WriteString(m.pending.Line)
+		b.WriteByte('\n')
+		b.WriteString(rec.Line)
+		m.pending.Line = b.String()
+		m.pending.Offset = rec.Offset
+		m.lines++
+		m.updated = rec.Time
+		return nil
+	}
+
+	done := m.pending
+	next := rec
+	m.pending = &next
+	m.lines = 1
+	m.updated = rec.Time
+	return done
+}
+
+// flush returns the pending record if it has been idle for at least wait
+func (m *multiline) flush(now time.Time, wait time.Duration) *Record {
+	if m.pending == nil || now.Sub(m.updated) < wait {
+		return nil
+	}
+	done := m.pending
+	m.pending = nil
+	m.lines = 0
+	return done
+}
@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// errPermanent marks a batch the API rejected and will never accept
+var errPermanent = errors.New("batch rejected")
+
+// Shipper POSTs gzip-compressed batches to /v1/logs, retrying transient
+// failures and falling back to the disk buffer when the API is unreachable
+type Shipper struct {
+	url        string
+	apiKey     string
+	client     *http.Client
+	maxRetries int
+	backoff    time.Duration
+	buffer     *DiskBuffer
+}
+
+// NewShipper creates a shipper for the API at endpoint (e.g. http://logscale:8080).
+// apiKey, when set, is sent as a bearer token.
+func NewShipper(endpoint, apiKey string, maxRetries int, backoff time.Duration, buffer *DiskBuffer) *Shipper {
+	return &Shipper{
+		url:        strings.TrimRight(endpoint, "/") + "/v1/logs",
+		apiKey:     apiKey,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		buffer:     buffer,
+	}
+}
+
+// Ship delivers a batch, buffering it on disk if delivery fails. It returns
+// an error only when the batch could neither be sent nor buffered.
+func (s *Shipper) Ship(ctx context.Context, logs []models.LogRequest) error {
+	payload, err := encodeBatch(logs)
+	if err != nil {
+		return err
+	}
+
+	// Preserve ordering: while older batches are buffered, queue behind them
+	if !s.Replay(ctx) {
+		return s.bufferBatch(payload, len(logs))
+	}
+
+	err = s.send(ctx, payload)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errPermanent):
+		log.Printf("error: dropping batch of %d logs: %v", len(logs), err)
+		return nil
+	default:
+		log.Printf("warning: failed to ship batch of %d logs: %v", len(logs), err)
+		return s.bufferBatch(payload, len(logs))
+	}
+}
+
+// Replay sends buffered batches oldest first and reports whether the buffer is now empty
+func (s *Shipper) Replay(ctx context.Context) bool {
+	files, err := s.buffer.Pending()
+	if err != nil {
+		log.Printf("warning: %v", err)
+		return false
+	}
+
+	for _, file := range files {
+		payload, err := s.buffer.Read(file)
+		if err != nil {
+			log.Printf("warning: failed to read buffered batch %s: %v", file, err)
+			return false
+		}
+
+		if err := s.sendOnce(ctx, payload); err != nil && !errors.Is(err, errPermanent) {
+			return false
+		} else if err != nil {
+			log.Printf("error: dropping buffered batch %s: %v", file, err)
+		}
+
+		if err := s.buffer.Remove(file); err != nil {
+			log.Printf("warning: failed to remove buffered batch %s: %v", file, err)
+			return false
+		}
+	}
+
+	if len(files) > 0 {
+		log.Printf("Replayed %d buffered batches", len(files))
+	}
+	return true
+}
+
+func (s *Shipper) bufferBatch(payload []byte, count int) error {
+	if err := s.buffer.Put(payload); err != nil {
+		return fmt.Errorf("failed to buffer batch of %d logs: %w", count, err)
+	}
+	return nil
+}
+
+// send posts payload with exponential backoff between attempts
+func (s *Shipper) send(ctx context.Context, payload []byte) error {
+	delay := s.backoff
+	var err error
+
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		err = s.sendOnce(ctx, payload)
+		if err == nil || errors.Is(err, errPermanent) {
+			return err
+		}
+	}
+	return err
+}
+
+// sendOnce performs a single POST of an encoded batch
+func (s *Shipper) sendOnce(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("api returned %d: %s", resp.StatusCode, body)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		// An expired or rotated key is fixed by configuration, so keep the
+		// batch buffered until a valid key is in place
+		return fmt.Errorf("api rejected the API key with %d: %s", resp.StatusCode, body)
+	default:
+		return fmt.Errorf("%w: api returned %d: %s", errPermanent, resp.StatusCode, body)
+	}
+}
+
+// encodeBatch builds the gzip-compressed {"logs": [...]} request body
+func encodeBatch(logs []models.LogRequest) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(map[string][]models.LogRequest{"logs": logs}); err != nil {
+		return nil, fmt.Errorf("failed to encode batch: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress batch: %w", err)
+	}
+	return buf.Bytes(), nil
+}
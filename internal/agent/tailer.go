@@ -0,0 +1,261 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// rotateWait is how long a rotated file keeps being read, since writers may
+// still append to the old file until they reopen their log
+const rotateWait = 5 * time.Second
+
+// Record is a single (possibly multi-line) log record read from a file
+type Record struct {
+	Path  string
+	Inode uint64
+	// Offset is the position just past the record, used for checkpointing
+	Offset int64
+	Line   string
+	Time   time.Time
+}
+
+// tailedFile is an open file being followed
+type tailedFile struct {
+	path      string
+	file      *os.File
+	inode     uint64
+	offset    int64
+	reader    *bufio.Reader
+	partial   []byte
+	lines     *multiline
+	rotatedAt time.Time
+}
+
+// Tailer follows every file matching a set of glob patterns, surviving
+// rotation (rename and recreate) and truncation (copytruncate)
+type Tailer struct {
+	patterns         []string
+	pollInterval     time.Duration
+	multilineTimeout time.Duration
+	continuation     *regexp.Regexp
+	checkpoint       *Checkpoint
+
+	files    map[string]*tailedFile
+	draining []*tailedFile
+}
+
+// NewTailer creates a tailer for the given glob patterns
+func NewTailer(patterns []string, pollInterval, multilineTimeout time.Duration, continuation *regexp.Regexp, checkpoint *Checkpoint) *Tailer {
+	return &Tailer{
+		patterns:         patterns,
+		pollInterval:     pollInterval,
+		multilineTimeout: multilineTimeout,
+		continuation:     continuation,
+		checkpoint:       checkpoint,
+		files:            make(map[string]*tailedFile),
+	}
+}
+
+// Run polls the matched files and sends records to out until ctx is cancelled
+func (t *Tailer) Run(ctx context.Context, out chan<- Record) {
+	defer t.closeAll()
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		t.poll(ctx, out)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll discovers new files, reads new lines and handles rotation
+func (t *Tailer) poll(ctx context.Context, out chan<- Record) {
+	now := time.Now()
+	matched := t.expand()
+
+	for path := range matched {
+		if _, ok := t.files[path]; !ok {
+			if err := t.open(path); err != nil {
+				log.Printf("warning: failed to open %s: %v", path, err)
+			}
+		}
+	}
+
+	for path, f := range t.files {
+		if err := t.read(ctx, f, out); err != nil {
+			log.Printf("warning: failed to read %s: %v", path, err)
+		}
+		t.checkRotation(ctx, f, out)
+		t.emit(ctx, out, f.lines.flush(now, t.multilineTimeout))
+	}
+
+	// Keep reading rotated files for a while, then close them
+	remaining := t.draining[:0]
+	for _, f := range t.draining {
+		if err := t.read(ctx, f, out); err != nil {
+			log.Printf("warning: failed to read rotated %s: %v", f.path, err)
+		}
+		if now.Sub(f.rotatedAt) < rotateWait {
+			remaining = append(remaining, f)
+			continue
+		}
+		t.emit(ctx, out, f.lines.flush(now, 0))
+		_ = f.file.Close()
+	}
+	t.draining = remaining
+}
+
+// expand returns every regular file matching the glob patterns
+func (t *Tailer) expand() map[string]struct{} {
+	matched := make(map[string]struct{})
+	for _, pattern := range t.patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("warning: invalid glob %q: %v", pattern, err)
+			continue
+		}
+		for _, p := range paths {
+			if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+				matched[p] = struct{}{}
+			}
+		}
+	}
+	return matched
+}
+
+// open starts following path, resuming from the checkpoint when it refers to the same file
+func (t *Tailer) open(path string) error {
+	file, err := os.Open(path) // #nosec G304 -- paths come from configured globs
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f := &tailedFile{
+		path:  path,
+		file:  file,
+		inode: inodeOf(info),
+		lines: newMultiline(t.continuation),
+	}
+
+	if st, ok := t.checkpoint.Get(path); ok && st.Inode == f.inode && st.Offset <= info.Size() {
+		if _, err := file.Seek(st.Offset, io.SeekStart); err != nil {
+			_ = file.Close()
+			return err
+		}
+		f.offset = st.Offset
+	} else {
+		t.checkpoint.Track(path, f.inode, 0)
+	}
+
+	f.reader = bufio.NewReaderSize(file, 64*1024)
+	t.files[path] = f
+	log.Printf("Tailing %s from offset %d", path, f.offset)
+	return nil
+}
+
+// read consumes all complete lines currently available in f
+func (t *Tailer) read(ctx context.Context, f *tailedFile, out chan<- Record) error {
+	for {
+		chunk, err := f.reader.ReadBytes('\n')
+		if len(chunk) > 0 {
+			f.partial = append(f.partial, chunk...)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line := f.partial
+		f.partial = nil
+		f.offset += int64(len(line))
+
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+
+		rec := Record{
+			Path:   f.path,
+			Inode:  f.inode,
+			Offset: f.offset,
+			Line:   string(line),
+			Time:   time.Now(),
+		}
+		if !t.emit(ctx, out, f.lines.add(rec)) {
+			return ctx.Err()
+		}
+	}
+}
+
+// checkRotation detects truncation and replacement of the file at f.path
+func (t *Tailer) checkRotation(ctx context.Context, f *tailedFile, out chan<- Record) {
+	info, err := os.Stat(f.path)
+	if err != nil || inodeOf(info) != f.inode {
+		// Renamed or deleted: drain the old handle and pick up the new file next poll
+		f.rotatedAt = time.Now()
+		t.draining = append(t.draining, f)
+		delete(t.files, f.path)
+		if err != nil {
+			t.checkpoint.Forget(f.path)
+		}
+		return
+	}
+
+	if info.Size() < f.offset {
+		// Truncated in place (copytruncate): start over from the beginning
+		log.Printf("%s was truncated, reading from the start", f.path)
+		t.emit(ctx, out, f.lines.flush(time.Now(), 0))
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			log.Printf("warning: failed to rewind %s: %v", f.path, err)
+			return
+		}
+		f.reader.Reset(f.file)
+		f.partial = nil
+		f.offset = 0
+		t.checkpoint.Track(f.path, f.inode, 0)
+	}
+}
+
+// emit sends rec to out, returning false if ctx was cancelled first
+func (t *Tailer) emit(ctx context.Context, out chan<- Record, rec *Record) bool {
+	if rec == nil {
+		return true
+	}
+	select {
+	case out <- *rec:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (t *Tailer) closeAll() {
+	for _, f := range t.files {
+		_ = f.file.Close()
+	}
+	for _, f := range t.draining {
+		_ = f.file.Close()
+	}
+}
@@ -3,15 +3,22 @@ package analytics
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
 	"github.com/yunjin08/logscale/models"
 )
 
+// ErrMetricsNotFound is returned when a service has no metrics the caller may read
+var ErrMetricsNotFound = errors.New("metrics not found")
+
 // Service handles metrics aggregation and storage
 type Service struct {
 	db *pgxpool.Pool
@@ -26,38 +33,44 @@ func NewService(db *pgxpool.Pool) *Service {
 
 // UpdateServiceMetrics updates or creates service metrics based on a log event
 func (a *Service) UpdateServiceMetrics(ctx context.Context, event models.LogEvent) error {
+	defer metrics.ObserveDBQuery("update_service_metrics", time.Now())
+
 	tx, err := a.db.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
-		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-			log.Printf("DEBUG: Failed to rollback transaction: %v", rollbackErr)
+		// Rolling back a committed transaction is a no-op
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			slog.WarnContext(ctx, "failed to roll back service metrics update", logging.Err(rollbackErr))
 		}
 	}()
 
 	// Try to get existing metrics
 	var metrics models.ServiceMetrics
 	err = tx.QueryRow(ctx, `
-		SELECT id, service, total_logs, error_count, warning_count, info_count, debug_count, 
-		       error_rate, last_log_time, created_at, updated_at
+		SELECT id, tenant_id, service, total_logs, fatal_count, error_count, warning_count, info_count, debug_count,
+		       trace_count, error_rate, last_log_time, created_at, updated_at
 		FROM service_metrics 
-		WHERE service = $1
-	`, event.Service).Scan(
-		&metrics.ID, &metrics.Service, &metrics.TotalLogs, &metrics.ErrorCount,
-		&metrics.WarningCount, &metrics.InfoCount, &metrics.DebugCount,
+		WHERE tenant_id = $1 AND service = $2
+	`, event.TenantID, event.Service).Scan(
+		&metrics.ID, &metrics.TenantID, &metrics.Service, &metrics.TotalLogs, &metrics.FatalCount, &metrics.ErrorCount,
+		&metrics.WarningCount, &metrics.InfoCount, &metrics.DebugCount, &metrics.TraceCount,
 		&metrics.ErrorRate, &metrics.LastLogTime, &metrics.CreatedAt, &metrics.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows || (err != nil && (err.Error() == "no rows in result set" || strings.Contains(err.Error(), "no rows in result set"))) {
 		// Create new metrics record
 		metrics = models.ServiceMetrics{
+			TenantID:     event.TenantID,
 			Service:      event.Service,
 			TotalLogs:    0,
+			FatalCount:   0,
 			ErrorCount:   0,
 			WarningCount: 0,
 			InfoCount:    0,
 			DebugCount:   0,
+			TraceCount:   0,
 			ErrorRate:    0.0,
 			LastLogTime:  event.Timestamp,
 			CreatedAt:    time.Now(),
@@ -72,38 +85,44 @@ func (a *Service) UpdateServiceMetrics(ctx context.Context, event models.LogEven
 	metrics.LastLogTime = event.Timestamp
 	metrics.UpdatedAt = time.Now()
 
-	switch event.Level {
-	case "error":
+	// Events published before level normalization may still carry aliases
+	level, _ := models.NormalizeLevel(event.Level)
+	switch level {
+	case models.LevelFatal:
+		metrics.FatalCount++
+	case models.LevelError:
 		metrics.ErrorCount++
-	case "warn":
+	case models.LevelWarn:
 		metrics.WarningCount++
-	case "info":
+	case models.LevelInfo:
 		metrics.InfoCount++
-	case "debug":
+	case models.LevelDebug:
 		metrics.DebugCount++
+	case models.LevelTrace:
+		metrics.TraceCount++
 	}
 
 	if metrics.TotalLogs > 0 {
-		metrics.ErrorRate = float64(metrics.ErrorCount) / float64(metrics.TotalLogs)
+		metrics.ErrorRate = float64(metrics.ErrorCount+metrics.FatalCount) / float64(metrics.TotalLogs)
 	}
 
 	// Insert or update metrics
 	if metrics.ID == 0 {
 		err = tx.QueryRow(ctx, `
-			INSERT INTO service_metrics (service, total_logs, error_count, warning_count, info_count, debug_count, error_rate, last_log_time, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			INSERT INTO service_metrics (tenant_id, service, total_logs, fatal_count, error_count, warning_count, info_count, debug_count, trace_count, error_rate, last_log_time, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 			RETURNING id
-		`, metrics.Service, metrics.TotalLogs, metrics.ErrorCount, metrics.WarningCount,
-			metrics.InfoCount, metrics.DebugCount, metrics.ErrorRate, metrics.LastLogTime,
+		`, metrics.TenantID, metrics.Service, metrics.TotalLogs, metrics.FatalCount, metrics.ErrorCount, metrics.WarningCount,
+			metrics.InfoCount, metrics.DebugCount, metrics.TraceCount, metrics.ErrorRate, metrics.LastLogTime,
 			metrics.CreatedAt, metrics.UpdatedAt).Scan(&metrics.ID)
 	} else {
 		_, err = tx.Exec(ctx, `
 			UPDATE service_metrics 
-			SET total_logs = $1, error_count = $2, warning_count = $3, info_count = $4, 
-			    debug_count = $5, error_rate = $6, last_log_time = $7, updated_at = $8
-			WHERE id = $9
-		`, metrics.TotalLogs, metrics.ErrorCount, metrics.WarningCount, metrics.InfoCount,
-			metrics.DebugCount, metrics.ErrorRate, metrics.LastLogTime, metrics.UpdatedAt, metrics.ID)
+			SET total_logs = $1, fatal_count = $2, error_count = $3, warning_count = $4, info_count = $5,
+			    debug_count = $6, trace_count = $7, error_rate = $8, last_log_time = $9, updated_at = $10
+			WHERE id = $11
+		`, metrics.TotalLogs, metrics.FatalCount, metrics.ErrorCount, metrics.WarningCount, metrics.InfoCount,
+			metrics.DebugCount, metrics.TraceCount, metrics.ErrorRate, metrics.LastLogTime, metrics.UpdatedAt, metrics.ID)
 	}
 
 	if err != nil {
@@ -114,27 +133,36 @@ func (a *Service) UpdateServiceMetrics(ctx context.Context, event models.LogEven
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	log.Printf("Updated metrics for service %s: total=%d, errors=%d, error_rate=%.4f",
-		metrics.Service, metrics.TotalLogs, metrics.ErrorCount, metrics.ErrorRate)
+	slog.DebugContext(ctx, "updated service metrics",
+		logging.KeyTenant, metrics.TenantID,
+		logging.KeyService, metrics.Service,
+		"total_logs", metrics.TotalLogs,
+		"error_count", metrics.ErrorCount,
+		"error_rate", metrics.ErrorRate)
 	return nil
 }
 
-// GetServiceMetrics retrieves metrics for a specific service
-func (a *Service) GetServiceMetrics(ctx context.Context, service string) (*models.ServiceMetrics, error) {
+// GetServiceMetrics retrieves a tenant's metrics for a specific service.
+// Services the policy does not allow are reported as not found.
+func (a *Service) GetServiceMetrics(ctx context.Context, tenantID string, policy *models.AccessPolicy, service string) (*models.ServiceMetrics, error) {
+	if !policy.AllowsService(service) {
+		return nil, ErrMetricsNotFound
+	}
+
 	var metrics models.ServiceMetrics
 	err := a.db.QueryRow(ctx, `
-		SELECT id, service, total_logs, error_count, warning_count, info_count, debug_count, 
-		       error_rate, last_log_time, created_at, updated_at
+		SELECT id, tenant_id, service, total_logs, fatal_count, error_count, warning_count, info_count, debug_count,
+		       trace_count, error_rate, last_log_time, created_at, updated_at
 		FROM service_metrics 
-		WHERE service = $1
-	`, service).Scan(
-		&metrics.ID, &metrics.Service, &metrics.TotalLogs, &metrics.ErrorCount,
-		&metrics.WarningCount, &metrics.InfoCount, &metrics.DebugCount,
+		WHERE tenant_id = $1 AND service = $2
+	`, tenantID, service).Scan(
+		&metrics.ID, &metrics.TenantID, &metrics.Service, &metrics.TotalLogs, &metrics.FatalCount, &metrics.ErrorCount,
+		&metrics.WarningCount, &metrics.InfoCount, &metrics.DebugCount, &metrics.TraceCount,
 		&metrics.ErrorRate, &metrics.LastLogTime, &metrics.CreatedAt, &metrics.UpdatedAt,
 	)
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("no metrics found for service: %s", service)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w for service: %s", ErrMetricsNotFound, service)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query metrics: %w", err)
@@ -143,25 +171,26 @@ func (a *Service) GetServiceMetrics(ctx context.Context, service string) (*model
 	return &metrics, nil
 }
 
-// GetAllServiceMetrics retrieves metrics for all services
-func (a *Service) GetAllServiceMetrics(ctx context.Context) ([]models.ServiceMetrics, error) {
+// GetAllServiceMetrics retrieves a tenant's metrics for all services the policy allows
+func (a *Service) GetAllServiceMetrics(ctx context.Context, tenantID string, policy *models.AccessPolicy) ([]models.ServiceMetrics, error) {
 	rows, err := a.db.Query(ctx, `
-		SELECT id, service, total_logs, error_count, warning_count, info_count, debug_count, 
-		       error_rate, last_log_time, created_at, updated_at
+		SELECT id, tenant_id, service, total_logs, fatal_count, error_count, warning_count, info_count, debug_count,
+		       trace_count, error_rate, last_log_time, created_at, updated_at
 		FROM service_metrics 
+		WHERE tenant_id = $1 AND ($2::text[] IS NULL OR service LIKE ANY($2))
 		ORDER BY service
-	`)
+	`, tenantID, policy.ServiceLikePatterns())
 	if err != nil {
 		return nil, fmt.Errorf("failed to query metrics: %w", err)
 	}
 	defer rows.Close()
 
-	var metrics []models.ServiceMetrics
+	metrics := []models.ServiceMetrics{}
 	for rows.Next() {
 		var m models.ServiceMetrics
 		err := rows.Scan(
-			&m.ID, &m.Service, &m.TotalLogs, &m.ErrorCount,
-			&m.WarningCount, &m.InfoCount, &m.DebugCount,
+			&m.ID, &m.TenantID, &m.Service, &m.TotalLogs, &m.FatalCount, &m.ErrorCount,
+			&m.WarningCount, &m.InfoCount, &m.DebugCount, &m.TraceCount,
 			&m.ErrorRate, &m.LastLogTime, &m.CreatedAt, &m.UpdatedAt,
 		)
 		if err != nil {
@@ -169,6 +198,9 @@ func (a *Service) GetAllServiceMetrics(ctx context.Context) ([]models.ServiceMet
 		}
 		metrics = append(metrics, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating metrics: %w", err)
+	}
 
 	return metrics, nil
 }
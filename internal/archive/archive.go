@@ -0,0 +1,322 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/partition"
+)
+
+// Stores
+const (
+	StoreLocal = "local"
+	StoreS3    = "s3"
+)
+
+// FormatNDJSONGzip is one JSON log per line, gzip compressed
+const FormatNDJSONGzip = "ndjson.gz"
+
+// Config selects where archives are written
+type Config struct {
+	// Enabled archives logs before retention removes them
+	Enabled bool `yaml:"enabled"`
+	// Store is local or s3
+	Store string `yaml:"store"`
+	// Prefix starts every object key
+	Prefix string `yaml:"prefix"`
+	// LocalDir is the directory of the local store
+	LocalDir string   `yaml:"local_dir"`
+	S3       S3Config `yaml:"s3"`
+}
+
+// Validate checks the settings of the selected store
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Store {
+	case StoreLocal:
+		if c.LocalDir == "" {
+			return fmt.Errorf("local_dir is required for the local store")
+		}
+	case StoreS3:
+		if c.S3.Endpoint == "" || c.S3.Bucket == "" {
+			return fmt.Errorf("s3.endpoint and s3.bucket are required for the s3 store")
+		}
+	default:
+		return fmt.Errorf("unknown store %q (want local or s3)", c.Store)
+	}
+	return nil
+}
+
+// NewStore creates the configured store
+func NewStore(c Config) (BlobStore, error) {
+	switch c.Store {
+	case StoreLocal:
+		return NewLocalStore(c.LocalDir)
+	case StoreS3:
+		return NewS3Store(c.S3, nil)
+	default:
+		return nil, fmt.Errorf("unknown store %q", c.Store)
+	}
+}
+
+// Record is one archived log. Archives hold one record per line.
+type Record struct {
+	ID        int64           `json:"id"`
+	TenantID  string          `json:"tenant_id"`
+	Service   string          `json:"service"`
+	Level     string          `json:"level"`
+	Message   string          `json:"message"`
+	Timestamp time.Time       `json:"timestamp"`
+	Meta      json.RawMessage `json:"meta,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Manifest records one archive file, as stored in archive_manifests
+type Manifest struct {
+	ID       int64  `json:"id"`
+	Store    string `json:"store"`
+	Location string `json:"location"`
+	Key      string `json:"key"`
+	Format   string `json:"format"`
+	// Source is the partition archived, or retention for logs archived
+	// as retention deleted them
+	Source string `json:"source"`
+	// RangeStart and RangeEnd bound the timestamps of the archived logs
+	RangeStart time.Time `json:"range_start"`
+	RangeEnd   time.Time `json:"range_end"`
+	Logs       int64     `json:"logs"`
+	// StoredBytes is the size of the logs in the database, ArchiveBytes
+	// the size of the compressed file
+	StoredBytes  int64     `json:"stored_bytes"`
+	ArchiveBytes int64     `json:"archive_bytes"`
+	SHA256       string    `json:"sha256"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SourceRetention is the source of logs archived by retention deletes
+const SourceRetention = "retention"
+
+// Querier runs queries, such as *pgxpool.Pool or pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// recordColumns are selected, in order, by every export query, followed by
+// the stored size of the row
+const recordColumns = `id, tenant_id, service, level, message, timestamp, meta, created_at`
+
+// Archiver exports logs to a BlobStore and records them in archive_manifests
+type Archiver struct {
+	db     Querier
+	store  BlobStore
+	prefix string
+}
+
+// NewArchiver creates an archiver writing objects under prefix
+func NewArchiver(db Querier, store BlobStore, prefix string) *Archiver {
+	return &Archiver{db: db, store: store, prefix: prefix}
+}
+
+// ArchivePartition exports the logs of a daily partition, such as
+// logs_p20260118, reading the partition in tx. Lock the partition in tx
+// first so that no logs are written to it between archiving and dropping it.
+// Archiving a partition again replaces its archive.
+func (a *Archiver) ArchivePartition(ctx context.Context, tx pgx.Tx, name string) (Manifest, error) {
+	day, ok := partition.ParseName(name)
+	if !ok {
+		return Manifest{}, fmt.Errorf("%s is not a daily partition", name)
+	}
+	table := pgx.Identifier{name}.Sanitize()
+	query := `SELECT ` + recordColumns + `, pg_column_size(p.*)
+		FROM ` + table + ` p
+		ORDER BY timestamp, id`
+	return a.exportRange(ctx, tx, day, day.AddDate(0, 0, 1), name, name, query)
+}
+
+// ArchiveRange exports the logs timestamped from from up to, but excluding, to
+func (a *Archiver) ArchiveRange(ctx context.Context, from, to time.Time) (Manifest, error) {
+	name := fmt.Sprintf("logs-%s-%s", from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"))
+	return a.archiveRange(ctx, from, to, name, "range")
+}
+
+func (a *Archiver) archiveRange(ctx context.Context, from, to time.Time, name, source string) (Manifest, error) {
+	query := `SELECT ` + recordColumns + `, pg_column_size(logs.*)
+		FROM logs
+		WHERE timestamp >= $1 AND timestamp < $2
+		ORDER BY timestamp, id`
+	return a.exportRange(ctx, a.db, from, to, name, source, query, from, to)
+}
+
+// exportRange exports the logs of query, which covers from up to to, and
+// records them in a manifest through q
+func (a *Archiver) exportRange(ctx context.Context, q Querier, from, to time.Time, name, source, query string, args ...interface{}) (Manifest, error) {
+	m, err := a.export(ctx, q, func(time.Time) string { return a.key(from, name) }, source, query, args...)
+	if err != nil || m.Logs == 0 {
+		return m, err
+	}
+	// The range asked for, rather than the logs found, so that reruns
+	// replace the same manifest
+	m.RangeStart, m.RangeEnd = from, to
+	return m, a.saveManifest(ctx, q, &m)
+}
+
+// ArchiveDeleted runs a DELETE, in tx, returning the record columns
+// followed by pg_column_size of the row, and archives the deleted logs
+// before tx commits. If archiving fails, roll tx back to keep the logs.
+func (a *Archiver) ArchiveDeleted(ctx context.Context, tx pgx.Tx, deleteSQL string, args ...interface{}) (Manifest, error) {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	key := func(first time.Time) string {
+		return a.key(first, fmt.Sprintf("deleted-%s-%s", first.UTC().Format("20060102T150405Z"), hex.EncodeToString(suffix)))
+	}
+
+	m, err := a.export(ctx, tx, key, SourceRetention, deleteSQL, args...)
+	if err != nil || m.Logs == 0 {
+		return m, err
+	}
+	return m, a.saveManifest(ctx, tx, &m)
+}
+
+// key returns the object key of an archive of logs from day
+func (a *Archiver) key(day time.Time, name string) string {
+	return path.Join(a.prefix, day.UTC().Format("2006/01/02"), name+"."+FormatNDJSONGzip)
+}
+
+// export writes the logs returned by query to a compressed file and uploads
+// it under key(first log timestamp). Nothing is uploaded when the query
+// returns no logs.
+func (a *Archiver) export(ctx context.Context, q Querier, key func(first time.Time) string, source, query string, args ...interface{}) (Manifest, error) {
+	defer metrics.ObserveDBQuery("archive_export", time.Now())
+
+	tmp, err := os.CreateTemp("", "logscale-archive-*."+FormatNDJSONGzip)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	m := Manifest{
+		Store:    a.store.Kind(),
+		Location: a.store.Location(),
+		Format:   FormatNDJSONGzip,
+		Source:   source,
+	}
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(tmp, hash))
+	enc := json.NewEncoder(gz)
+
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to query logs to archive: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Record
+		var size int64
+		if err := rows.Scan(&r.ID, &r.TenantID, &r.Service, &r.Level, &r.Message, &r.Timestamp, &r.Meta, &r.CreatedAt, &size); err != nil {
+			return Manifest{}, fmt.Errorf("failed to scan log to archive: %w", err)
+		}
+		if err := enc.Encode(r); err != nil {
+			return Manifest{}, fmt.Errorf("failed to write archive file: %w", err)
+		}
+		if m.Logs == 0 || r.Timestamp.Before(m.RangeStart) {
+			m.RangeStart = r.Timestamp
+		}
+		if r.Timestamp.After(m.RangeEnd) {
+			m.RangeEnd = r.Timestamp
+		}
+		m.Logs++
+		m.StoredBytes += size
+	}
+	if err := rows.Err(); err != nil {
+		return Manifest{}, fmt.Errorf("failed to read logs to archive: %w", err)
+	}
+	if m.Logs == 0 {
+		return m, nil
+	}
+	if err := gz.Close(); err != nil {
+		return Manifest{}, fmt.Errorf("failed to write archive file: %w", err)
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to size archive file: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return Manifest{}, fmt.Errorf("failed to rewind archive file: %w", err)
+	}
+
+	m.Key = key(m.RangeStart)
+	m.ArchiveBytes = size
+	m.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if err := a.store.Put(ctx, m.Key, tmp); err != nil {
+		return Manifest{}, fmt.Errorf("failed to upload %s: %w", m.Key, err)
+	}
+
+	metrics.ArchivedLogs.Add(float64(m.Logs))
+	metrics.ArchivedBytes.Add(float64(m.ArchiveBytes))
+	return m, nil
+}
+
+// saveManifest records m, replacing the manifest of the same object
+func (a *Archiver) saveManifest(ctx context.Context, q Querier, m *Manifest) error {
+	err := q.QueryRow(ctx, `
+		INSERT INTO archive_manifests (store, location, object_key, format, source,
+			range_start, range_end, log_count, stored_bytes, archive_bytes, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (store, location, object_key) DO UPDATE SET
+			format = EXCLUDED.format,
+			source = EXCLUDED.source,
+			range_start = EXCLUDED.range_start,
+			range_end = EXCLUDED.range_end,
+			log_count = EXCLUDED.log_count,
+			stored_bytes = EXCLUDED.stored_bytes,
+			archive_bytes = EXCLUDED.archive_bytes,
+			sha256 = EXCLUDED.sha256,
+			created_at = NOW()
+		RETURNING id, created_at
+	`, m.Store, m.Location, m.Key, m.Format, m.Source,
+		m.RangeStart, m.RangeEnd, m.Logs, m.StoredBytes, m.ArchiveBytes, m.SHA256,
+	).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record archive %s: %w", m.Key, err)
+	}
+	return nil
+}
+
+// Read decodes the records of an archive file
+func Read(r io.Reader) ([]Record, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gz.Close()
+
+	var records []Record
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var r Record
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to decode archive: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
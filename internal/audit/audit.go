@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/models"
+	"github.com/yunjin08/logscale/pkg/pagination"
+)
+
+// Store persists the audit chain; helpers.AuditHelper implements it
+type Store interface {
+	AppendAuditEvent(ctx context.Context, event models.AuditEvent) (*models.AuditEvent, error)
+	QueryAuditEvents(ctx context.Context, query models.AuditQuery, pagination pagination.Pagination) ([]models.AuditEvent, int64, error)
+	ForEachAuditEvent(ctx context.Context, fn func(models.AuditEvent) bool) error
+}
+
+// queueSize bounds the events waiting to be appended to the chain
+const queueSize = 1024
+
+// Service records and reads the audit trail
+type Service struct {
+	store Store
+
+	// mu guards closed, and is held for reading while sending to queue
+	mu     sync.RWMutex
+	closed bool
+	queue  chan models.AuditEvent
+	done   chan struct{}
+}
+
+// NewService creates an audit service backed by store and starts the
+// writer appending queued events. Close stops it.
+func NewService(store Store) *Service {
+	s := &Service{
+		store: store,
+		queue: make(chan models.AuditEvent, queueSize),
+		done:  make(chan struct{}),
+	}
+	go s.write()
+	return s
+}
+
+// Enqueue queues an event for the writer, so that requests do not wait for
+// the chain lock. It only blocks while the queue is full. Events arriving
+// after Close are logged and dropped.
+func (s *Service) Enqueue(ctx context.Context, event models.AuditEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		slog.WarnContext(ctx, "audit trail closed, event dropped", "action", event.Action, "resource", event.Resource)
+		return
+	}
+	s.queue <- event
+}
+
+// write appends queued events to the chain one at a time until the queue
+// is closed
+func (s *Service) write() {
+	defer close(s.done)
+	for event := range s.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+		s.Record(ctx, event)
+		cancel()
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are
+// written or ctx is done
+func (s *Service) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Record appends an event to the audit chain and waits for it. Failures are
+// logged; the audited request has already been served.
+func (s *Service) Record(ctx context.Context, event models.AuditEvent) {
+	if _, err := s.store.AppendAuditEvent(ctx, event); err != nil {
+		slog.WarnContext(ctx, "failed to record audit event", "action", event.Action, "resource", event.Resource, logging.Err(err))
+	}
+}
+
+// Query returns audit events matching query, newest first
+func (s *Service) Query(ctx context.Context, query models.AuditQuery, p pagination.Pagination) ([]models.AuditEvent, int64, error) {
+	return s.store.QueryAuditEvents(ctx, query, p)
+}
+
+// Verify walks the whole chain and reports the first event whose hash or
+// link to its predecessor does not match
+func (s *Service) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true, LastHash: models.GenesisHash}
+	err := s.store.ForEachAuditEvent(ctx, func(event models.AuditEvent) bool {
+		if event.PrevHash != result.LastHash || event.ComputeHash() != event.Hash {
+			id := event.ID
+			result.Valid = false
+			result.BrokenAt = &id
+			return false
+		}
+		result.Checked++
+		result.LastHash = event.Hash
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/models"
+)
+
+// detailsKey is the gin context key holding details added by handlers
+const detailsKey = "audit_details"
+
+// maxBodySize is the largest request body copied into an event
+const maxBodySize = 64 << 10
+
+// recordTimeout bounds writing one queued event
+const recordTimeout = 5 * time.Second
+
+// AddDetail attaches a detail, such as a result count, to the request's audit event
+func AddDetail(c *gin.Context, key string, value interface{}) {
+	details, _ := c.Get(detailsKey)
+	m, ok := details.(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+		c.Set(detailsKey, m)
+	}
+	m[key] = value
+}
+
+// Queries records every request to the routes it guards
+func (s *Service) Queries() gin.HandlerFunc {
+	return s.middleware(false)
+}
+
+// Mutations records the requests that can change state, skipping reads
+func (s *Service) Mutations() gin.HandlerFunc {
+	return s.middleware(true)
+}
+
+// Coalesced records requests like Queries, but at most one per tenant, key
+// and route every interval, for endpoints polled by scrapers. Each recorded
+// event counts the requests left out since the previous one in its
+// "coalesced" detail.
+func (s *Service) Coalesced(interval time.Duration) gin.HandlerFunc {
+	type window struct {
+		start   time.Time
+		skipped int
+	}
+	var mu sync.Mutex
+	windows := make(map[string]*window)
+	record := s.middleware(false)
+
+	return func(c *gin.Context) {
+		key := auth.TenantFromContext(c) + " " + c.FullPath()
+		if p := auth.PrincipalFromContext(c); p != nil {
+			key += " " + strconv.FormatInt(p.KeyID, 10) + " " + p.Name
+		}
+
+		now := time.Now()
+		mu.Lock()
+		w := windows[key]
+		if w != nil && now.Sub(w.start) < interval {
+			w.skipped++
+			mu.Unlock()
+			c.Next()
+			return
+		}
+		skipped := 0
+		if w != nil {
+			skipped = w.skipped
+		}
+		windows[key] = &window{start: now}
+		mu.Unlock()
+
+		if skipped > 0 {
+			AddDetail(c, "coalesced", skipped)
+		}
+		record(c)
+	}
+}
+
+func (s *Service) middleware(mutationsOnly bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if mutationsOnly && (method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions) {
+			c.Next()
+			return
+		}
+
+		body := captureBody(c)
+		c.Next()
+
+		details := map[string]interface{}{}
+		if extra, ok := c.Get(detailsKey); ok {
+			for k, v := range extra.(map[string]interface{}) {
+				details[k] = v
+			}
+		}
+		if query := c.Request.URL.Query(); len(query) > 0 {
+			params := make(map[string]interface{}, len(query))
+			for k, v := range query {
+				if len(v) == 1 {
+					params[k] = v[0]
+				} else {
+					params[k] = v
+				}
+			}
+			details["query"] = params
+		}
+		if body != nil {
+			details["request"] = body
+		}
+		details["client_ip"] = c.ClientIP()
+		data, _ := json.Marshal(details)
+
+		event := models.AuditEvent{
+			TenantID:   auth.TenantFromContext(c),
+			OccurredAt: time.Now(),
+			Actor:      "anonymous",
+			Action:     method + " " + c.FullPath(),
+			Resource:   c.Request.URL.Path,
+			Status:     c.Writer.Status(),
+			Details:    data,
+		}
+		if p := auth.PrincipalFromContext(c); p != nil {
+			event.Actor = p.Name
+			if p.KeyID != 0 {
+				keyID := p.KeyID
+				event.ActorKeyID = &keyID
+			}
+		}
+		s.Enqueue(c.Request.Context(), event)
+	}
+}
+
+// captureBody returns a JSON request body for the event, leaving the body
+// readable for the handler. Bodies that are not small JSON are left out.
+func captureBody(c *gin.Context) json.RawMessage {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodySize+1))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(data), c.Request.Body), c.Request.Body}
+	if err != nil || len(data) > maxBodySize || !json.Valid(data) {
+		return nil
+	}
+	return data
+}
+
+// readCloser replays a consumed prefix while closing the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,412 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/models"
+)
+
+// touchInterval limits how often last_used_at is written for a key
+const touchInterval = time.Minute
+
+var (
+	// ErrInvalidKey is returned for malformed, unknown, expired or revoked keys
+	ErrInvalidKey = errors.New("invalid api key")
+	// ErrInvalidScope is returned when creating a key with an unknown scope
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidTenant is returned when creating a key with a malformed tenant id
+	ErrInvalidTenant = errors.New("invalid tenant")
+	// ErrInvalidRole is returned for malformed roles and unknown role ids
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrInvalidCertSubject is returned when creating a key with an empty certificate subject
+	ErrInvalidCertSubject = errors.New("invalid certificate subject")
+)
+
+// KeyStore persists API keys; *helpers.APIKeyHelper satisfies it. An
+// empty tenantID matches keys of every tenant.
+type KeyStore interface {
+	CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, string, error)
+	GetAPIKeyByCertSubject(ctx context.Context, subjects []string) (*models.APIKey, error)
+	GetAPIKey(ctx context.Context, tenantID string, id int64) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, tenantID string) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, tenantID string, id int64) (*models.APIKey, error)
+	RotateAPIKey(ctx context.Context, tenantID string, oldID int64, key models.APIKey, keyHash string, oldExpiresAt time.Time) (*models.APIKey, error)
+	SetAPIKeyRole(ctx context.Context, tenantID string, id int64, roleID *int64) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+}
+
+// RoleStore persists roles; *helpers.RoleHelper satisfies it
+type RoleStore interface {
+	CreateRole(ctx context.Context, role models.Role) (*models.Role, error)
+	UpdateRole(ctx context.Context, tenantID string, role models.Role) (*models.Role, error)
+	GetRole(ctx context.Context, tenantID string, id int64) (*models.Role, error)
+	ListRoles(ctx context.Context, tenantID string) ([]models.Role, error)
+	DeleteRole(ctx context.Context, tenantID string, id int64) error
+}
+
+// Service authenticates API keys and manages their lifecycle
+type Service struct {
+	keys           KeyStore
+	roles          RoleStore
+	bootstrapToken string
+	enabled        bool
+}
+
+// NewService creates a new auth service. bootstrapToken, when set, is
+// accepted as an admin key so the first keys can be created. When enabled
+// is false every request is treated as an admin.
+func NewService(keys KeyStore, roles RoleStore, bootstrapToken string, enabled bool) *Service {
+	return &Service{
+		keys:           keys,
+		roles:          roles,
+		bootstrapToken: bootstrapToken,
+		enabled:        enabled,
+	}
+}
+
+// Authenticate resolves a bearer token to a principal
+func (s *Service) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if s.bootstrapToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.bootstrapToken)) == 1 {
+		return &Principal{TenantID: models.DefaultTenant, Name: "bootstrap", Scopes: []Scope{ScopeAdmin}}, nil
+	}
+
+	prefix, secret, ok := ParseToken(token)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	key, keyHash, err := s.keys.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, helpers.ErrNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !secretMatches(secret, keyHash) {
+		return nil, ErrInvalidKey
+	}
+	return s.principalForKey(ctx, key)
+}
+
+// AuthenticateCertificate resolves a verified TLS client certificate to the
+// principal of the key mapped to its subject DN or, failing that, its common name
+func (s *Service) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*Principal, error) {
+	subjects := []string{cert.Subject.String()}
+	if cn := cert.Subject.CommonName; cn != "" {
+		subjects = append(subjects, cn)
+	}
+
+	key, err := s.keys.GetAPIKeyByCertSubject(ctx, subjects)
+	if errors.Is(err, helpers.ErrNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.principalForKey(ctx, key)
+}
+
+// principalForKey checks that an identified key is usable and builds its principal
+func (s *Service) principalForKey(ctx context.Context, key *models.APIKey) (*Principal, error) {
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return nil, ErrInvalidKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+		go func(id int64) {
+			touchCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := s.keys.TouchAPIKey(touchCtx, id); err != nil {
+				slog.Warn("failed to record API key use", "key_id", id, logging.Err(err))
+			}
+		}(key.ID)
+	}
+
+	scopes, err := ParseScopes(key.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("api key %d: %w", key.ID, err)
+	}
+
+	principal := &Principal{KeyID: key.ID, TenantID: key.TenantID, Name: key.Name, Scopes: scopes}
+	if key.RoleID != nil {
+		role, err := s.roles.GetRole(ctx, key.TenantID, *key.RoleID)
+		if err != nil {
+			return nil, fmt.Errorf("api key %d: %w", key.ID, err)
+		}
+		principal.Policy = role.Policy()
+	}
+	return principal, nil
+}
+
+// CreateKey issues a new key and returns it with its one-time token
+func (s *Service) CreateKey(ctx context.Context, req models.CreateAPIKeyRequest) (*models.APIKeyResponse, error) {
+	if _, err := ParseScopes(req.Scopes); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScope, err)
+	}
+	if req.TenantID == "" {
+		req.TenantID = models.DefaultTenant
+	}
+	if err := models.ValidateTenantID(req.TenantID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTenant, err)
+	}
+	if err := s.checkRole(ctx, req.TenantID, req.RoleID); err != nil {
+		return nil, err
+	}
+	if req.CertSubject != nil && strings.TrimSpace(*req.CertSubject) == "" {
+		return nil, fmt.Errorf("%w: cert_subject must not be empty", ErrInvalidCertSubject)
+	}
+
+	token, prefix, keyHash, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.keys.CreateAPIKey(ctx, models.APIKey{
+		TenantID:    req.TenantID,
+		Name:        req.Name,
+		Prefix:      prefix,
+		Scopes:      req.Scopes,
+		ExpiresAt:   req.ExpiresAt,
+		RoleID:      req.RoleID,
+		CertSubject: req.CertSubject,
+	}, keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIKeyResponse{Key: *key, Token: token}, nil
+}
+
+// RotateKey issues a replacement for key id of tenantID with the same name,
+// scopes and expiry. The old key keeps working for the grace period.
+func (s *Service) RotateKey(ctx context.Context, tenantID string, id int64, grace time.Duration) (*models.APIKeyResponse, error) {
+	old, err := s.keys.GetAPIKey(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	token, prefix, keyHash, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.keys.RotateAPIKey(ctx, tenantID, id, models.APIKey{
+		TenantID:    old.TenantID,
+		Name:        old.Name,
+		Prefix:      prefix,
+		Scopes:      old.Scopes,
+		ExpiresAt:   old.ExpiresAt,
+		RoleID:      old.RoleID,
+		CertSubject: old.CertSubject,
+	}, keyHash, time.Now().Add(grace))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIKeyResponse{Key: *key, Token: token}, nil
+}
+
+// SetKeyRole assigns a role to key id of tenantID, or removes its role when
+// roleID is nil. The role must belong to the key's tenant.
+func (s *Service) SetKeyRole(ctx context.Context, tenantID string, id int64, roleID *int64) (*models.APIKey, error) {
+	key, err := s.keys.GetAPIKey(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRole(ctx, key.TenantID, roleID); err != nil {
+		return nil, err
+	}
+	return s.keys.SetAPIKeyRole(ctx, key.TenantID, id, roleID)
+}
+
+// CreateRole stores a new role in the tenant of the request
+func (s *Service) CreateRole(ctx context.Context, req models.RoleRequest) (*models.Role, error) {
+	if req.TenantID == "" {
+		req.TenantID = models.DefaultTenant
+	}
+	if err := models.ValidateTenantID(req.TenantID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTenant, err)
+	}
+	role, err := roleFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.roles.CreateRole(ctx, role)
+}
+
+// UpdateRole replaces role id of tenantID. The role keeps its tenant. Keys
+// using it pick up the change on their next request.
+func (s *Service) UpdateRole(ctx context.Context, tenantID string, id int64, req models.RoleRequest) (*models.Role, error) {
+	role, err := roleFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	role.ID = id
+	return s.roles.UpdateRole(ctx, tenantID, role)
+}
+
+// ListRoles returns the roles of tenantID
+func (s *Service) ListRoles(ctx context.Context, tenantID string) ([]models.Role, error) {
+	return s.roles.ListRoles(ctx, tenantID)
+}
+
+// DeleteRole removes a role of tenantID that no key uses
+func (s *Service) DeleteRole(ctx context.Context, tenantID string, id int64) error {
+	return s.roles.DeleteRole(ctx, tenantID, id)
+}
+
+// checkRole verifies that an optional role id exists in tenantID
+func (s *Service) checkRole(ctx context.Context, tenantID string, roleID *int64) error {
+	if roleID == nil {
+		return nil
+	}
+	_, err := s.roles.GetRole(ctx, tenantID, *roleID)
+	if errors.Is(err, helpers.ErrNotFound) {
+		return fmt.Errorf("%w: role %d does not exist in tenant %q", ErrInvalidRole, *roleID, tenantID)
+	}
+	return err
+}
+
+func roleFromRequest(req models.RoleRequest) (models.Role, error) {
+	if err := models.ValidateServicePatterns(req.ServicePatterns); err != nil {
+		return models.Role{}, fmt.Errorf("%w: %v", ErrInvalidRole, err)
+	}
+	mode := req.MetaMode
+	if mode == "" {
+		mode = models.MetaModeRedact
+	}
+	return models.Role{
+		TenantID:        req.TenantID,
+		Name:            req.Name,
+		ServicePatterns: req.ServicePatterns,
+		MetaFields:      req.MetaFields,
+		MetaMode:        mode,
+	}, nil
+}
+
+// RevokeKey revokes key id of tenantID immediately
+func (s *Service) RevokeKey(ctx context.Context, tenantID string, id int64) (*models.APIKey, error) {
+	return s.keys.RevokeAPIKey(ctx, tenantID, id)
+}
+
+// ListKeys returns the keys of tenantID without secrets
+func (s *Service) ListKeys(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+	return s.keys.ListAPIKeys(ctx, tenantID)
+}
+
+// Middleware authenticates the `Authorization: Bearer <token>` header and
+// stores the principal in the context. Requests without a valid key get 401.
+func (s *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.identify(c) {
+			c.Next()
+		}
+	}
+}
+
+// ScrapeAuth guards /metrics. Requests bearing token, when set, are let
+// through without a principal; anything else needs an admin key.
+func (s *Service) ScrapeAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if bearer, ok := bearerToken(c.GetHeader("Authorization")); ok && token != "" &&
+			subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+			c.Next()
+			return
+		}
+		if !s.identify(c) {
+			return
+		}
+		if !PrincipalFromContext(c).HasScope(ScopeAdmin) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key lacks the %s scope", ScopeAdmin)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// identify authenticates the request and stores its principal. It aborts
+// the request and returns false when that fails.
+func (s *Service) identify(c *gin.Context) bool {
+	if !s.enabled {
+		SetPrincipal(c, &Principal{TenantID: models.DefaultTenant, Name: "anonymous", Scopes: []Scope{ScopeAdmin}})
+		return true
+	}
+
+	// A bearer token wins over a client certificate
+	token, ok := bearerToken(c.GetHeader("Authorization"))
+	cert := verifiedClientCert(c.Request)
+	if !ok && cert == nil {
+		c.Header("WWW-Authenticate", `Bearer realm="logscale"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+		return false
+	}
+
+	var principal *Principal
+	var err error
+	if ok {
+		principal, err = s.Authenticate(c.Request.Context(), token)
+	} else {
+		principal, err = s.AuthenticateCertificate(c.Request.Context(), cert)
+	}
+	if errors.Is(err, ErrInvalidKey) && !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Client certificate is not mapped to an active API key"})
+		return false
+	}
+	if errors.Is(err, ErrInvalidKey) {
+		c.Header("WWW-Authenticate", `Bearer realm="logscale", error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+		return false
+	}
+
+	SetPrincipal(c, principal)
+	return true
+}
+
+// RequireScope rejects requests whose principal lacks scope with 403
+func RequireScope(scope Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFromContext(c)
+		if principal == nil || !principal.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key lacks the %s scope", scope)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// verifiedClientCert returns the client certificate of a request whose
+// chain the server verified, or nil
+func verifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// bearerToken extracts the token from an Authorization header
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunjin08/logscale/models"
+)
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	// ScopeLogsWrite allows ingesting logs
+	ScopeLogsWrite Scope = "logs:write"
+	// ScopeLogsRead allows querying logs
+	ScopeLogsRead Scope = "logs:read"
+	// ScopeAuditRead allows reading the audit trail of the key's tenant
+	ScopeAuditRead Scope = "audit:read"
+	// ScopeAdmin allows managing keys and settings, and implies every other scope
+	ScopeAdmin Scope = "admin"
+)
+
+// principalKey is the gin context key holding the authenticated Principal
+const principalKey = "principal"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// KeyID is the API key id, or 0 for the bootstrap key and anonymous access
+	KeyID    int64   `json:"key_id"`
+	TenantID string  `json:"tenant_id"`
+	Name     string  `json:"name"`
+	Scopes   []Scope `json:"scopes"`
+	// Policy restricts what the principal can read; nil means unrestricted
+	Policy *models.AccessPolicy `json:"-"`
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ManagesAllTenants reports whether the principal administers every
+// tenant, as the bootstrap key and admin keys of the default tenant do
+func (p *Principal) ManagesAllTenants() bool {
+	return p.TenantID == models.DefaultTenant && p.HasScope(ScopeAdmin)
+}
+
+// IsBootstrap reports whether the principal is the bootstrap key, or
+// anonymous access with auth disabled, rather than a stored API key
+func (p *Principal) IsBootstrap() bool {
+	return p.KeyID == 0 && p.HasScope(ScopeAdmin)
+}
+
+// ParseScopes validates scope names
+func ParseScopes(names []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		switch s := Scope(name); s {
+		case ScopeLogsWrite, ScopeLogsRead, ScopeAuditRead, ScopeAdmin:
+			scopes = append(scopes, s)
+		default:
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+	}
+	return scopes, nil
+}
+
+// SetPrincipal stores the authenticated principal in the context
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// PrincipalFromContext returns the principal set by the authentication middleware
+func PrincipalFromContext(c *gin.Context) *Principal {
+	if v, exists := c.Get(principalKey); exists {
+		if p, ok := v.(*Principal); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// PolicyFromContext returns the read policy of the authenticated principal
+func PolicyFromContext(c *gin.Context) *models.AccessPolicy {
+	if p := PrincipalFromContext(c); p != nil {
+		return p.Policy
+	}
+	return nil
+}
+
+// TenantFromContext returns the tenant of the authenticated principal
+func TenantFromContext(c *gin.Context) string {
+	if p := PrincipalFromContext(c); p != nil && p.TenantID != "" {
+		return p.TenantID
+	}
+	return models.DefaultTenant
+}
+
+// ManagedTenant returns the tenant whose keys and settings the principal
+// may manage, or an empty string when it manages every tenant
+func ManagedTenant(c *gin.Context) string {
+	if p := PrincipalFromContext(c); p != nil && p.ManagesAllTenants() {
+		return ""
+	}
+	return TenantFromContext(c)
+}
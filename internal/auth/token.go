@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// tokenPrefix marks LogScale API keys so they are easy to spot in leaks
+const tokenPrefix = "lsk"
+
+// GenerateToken creates a new API key token of the form lsk_<prefix>_<secret>.
+// The prefix identifies the key in the database; only the secret's hash is stored.
+func GenerateToken() (token, prefix, secretHash string, err error) {
+	prefixBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	secret := hex.EncodeToString(secretBytes)
+	return tokenPrefix + "_" + prefix + "_" + secret, prefix, HashSecret(secret), nil
+}
+
+// ParseToken splits a token into its prefix and secret
+func ParseToken(token string) (prefix, secret string, ok bool) {
+	parts := strings.Split(token, "_")
+	if len(parts) != 3 || parts[0] != tokenPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// HashSecret returns the hex SHA-256 digest stored for a secret. Secrets are
+// 256 bits of randomness, so a fast hash is sufficient.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// secretMatches compares a secret against a stored hash in constant time
+func secretMatches(secret, secretHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(secretHash)) == 1
+}
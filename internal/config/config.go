@@ -0,0 +1,395 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/yunjin08/logscale/internal/archive"
+	"github.com/yunjin08/logscale/internal/ingest"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/pipeline"
+	"github.com/yunjin08/logscale/internal/ratelimit"
+	"github.com/yunjin08/logscale/internal/redact"
+	"github.com/yunjin08/logscale/internal/retention"
+	"github.com/yunjin08/logscale/internal/tlsconfig"
+	"github.com/yunjin08/logscale/internal/tracing"
+	"github.com/yunjin08/logscale/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the API and the worker. Values come from
+// the YAML file, then environment variables, then command line flags.
+type Config struct {
+	Database   DatabaseConfig      `yaml:"database"`
+	Redis      RedisConfig         `yaml:"redis"`
+	Stream     models.StreamConfig `yaml:"stream"`
+	Worker     WorkerConfig        `yaml:"worker"`
+	Server     ServerConfig        `yaml:"server"`
+	Auth       AuthConfig          `yaml:"auth"`
+	Ingest     IngestConfig        `yaml:"ingest"`
+	Inputs     InputsConfig        `yaml:"inputs"`
+	RateLimits ratelimit.Config    `yaml:"rate_limits"`
+	Pipelines  []pipeline.Config   `yaml:"pipelines"`
+	Redaction  redact.Config       `yaml:"redaction"`
+	Prometheus PrometheusConfig    `yaml:"prometheus"`
+	Health     HealthConfig        `yaml:"health"`
+	Partitions PartitionsConfig    `yaml:"partitions"`
+	Query      QueryConfig         `yaml:"query"`
+	Retention  retention.Config    `yaml:"retention"`
+	Archive    archive.Config      `yaml:"archive"`
+	Tracing    tracing.Config      `yaml:"tracing"`
+	Logging    logging.Config      `yaml:"logging"`
+
+	// File is the config file the values were read from
+	File string `yaml:"-"`
+}
+
+// DatabaseConfig configures the PostgreSQL connection pool
+type DatabaseConfig struct {
+	URL string `yaml:"url"`
+	// MaxConns and MinConns size the pool; zero keeps the pgx defaults
+	MaxConns        int           `yaml:"max_conns"`
+	MinConns        int           `yaml:"min_conns"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time"`
+}
+
+// RedisConfig configures the Redis clients. An empty URL disables Redis in the API.
+type RedisConfig struct {
+	URL string `yaml:"url"`
+	// PoolSize is the connections per client; zero keeps the go-redis default
+	PoolSize int `yaml:"pool_size"`
+}
+
+// WorkerConfig configures the worker's HTTP listener, which serves metrics
+// and health checks. An empty HTTPAddr disables it.
+type WorkerConfig struct {
+	HTTPAddr string `yaml:"http_addr"`
+}
+
+// ServerConfig configures the API's HTTP server
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	MaxBodyBytes    int64         `yaml:"max_body_bytes"`
+	TLS             TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig enables HTTPS when CertFile and KeyFile are set
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientAuth is none, request or require; it defaults to request when
+	// ClientCAFile is set
+	ClientAuth string `yaml:"client_auth"`
+}
+
+// Enabled reports whether a certificate is configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// AuthConfig configures API key authentication
+type AuthConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	AdminAPIKey string `yaml:"admin_api_key"`
+	// MetricsToken lets scrapers read /metrics as a bearer token; without
+	// it /metrics needs an admin key
+	MetricsToken string `yaml:"metrics_token"`
+}
+
+// IngestConfig configures the shared ingestion pipeline
+type IngestConfig struct {
+	// UnknownLevels is tag or reject
+	UnknownLevels string `yaml:"unknown_levels"`
+}
+
+// InputsConfig configures the unauthenticated log inputs
+type InputsConfig struct {
+	FluentForward InputConfig `yaml:"fluent_forward"`
+	GELF          InputConfig `yaml:"gelf_udp"`
+}
+
+// InputConfig enables an input when Addr is set. Its logs go to Tenant.
+type InputConfig struct {
+	Addr   string `yaml:"addr"`
+	Tenant string `yaml:"tenant"`
+}
+
+// PrometheusConfig configures the Prometheus export of service metrics
+type PrometheusConfig struct {
+	// MaxServices is the number of services, by log volume, exported with
+	// their own series; the rest are summed into one
+	MaxServices int `yaml:"max_services"`
+}
+
+// HealthConfig configures the /livez and /readyz checks
+type HealthConfig struct {
+	// Timeout bounds all the checks of one request
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxStreamLag is the number of undelivered stream entries above which
+	// the worker counts as falling behind
+	MaxStreamLag int64 `yaml:"max_stream_lag"`
+	// MaxPublishBacklog is the number of stored logs waiting to be published
+	// above which the API counts as falling behind
+	MaxPublishBacklog int64 `yaml:"max_publish_backlog"`
+	// WorkerStallTimeout is how long the worker's loop may go without
+	// progress before it is reported as not live
+	WorkerStallTimeout time.Duration `yaml:"worker_stall_timeout"`
+}
+
+// PartitionsConfig configures the worker's creation of the daily
+// partitions of the logs table
+type PartitionsConfig struct {
+	// DaysAhead is the number of days after today whose partitions are
+	// created in advance
+	DaysAhead int `yaml:"days_ahead"`
+	// CheckInterval is how often missing partitions are looked for
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+// QueryConfig configures log queries
+type QueryConfig struct {
+	// DefaultWindow is the time range searched back from end_time, or now,
+	// when a query sets no start_time
+	DefaultWindow time.Duration `yaml:"default_window"`
+}
+
+// Default returns the configuration used for anything left unset
+func Default() *Config {
+	return &Config{
+		Stream: models.StreamConfig{
+			StreamName:    "logscale:logs",
+			ConsumerGroup: "logscale-workers",
+			ConsumerName:  "worker-1",
+			BatchSize:     10,
+			BlockTimeout:  time.Second,
+			RetryDelay:    5 * time.Second,
+		},
+		Worker: WorkerConfig{HTTPAddr: ":9091"},
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    60 * time.Second,
+			IdleTimeout:     120 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			MaxBodyBytes:    10 << 20,
+		},
+		Auth:       AuthConfig{Enabled: true},
+		Prometheus: PrometheusConfig{MaxServices: 500},
+		Health: HealthConfig{
+			Timeout:            2 * time.Second,
+			MaxStreamLag:       10000,
+			MaxPublishBacklog:  10000,
+			WorkerStallTimeout: 2 * time.Minute,
+		},
+		Partitions: PartitionsConfig{DaysAhead: 7, CheckInterval: time.Hour},
+		Query:      QueryConfig{DefaultWindow: 24 * time.Hour},
+		Retention:  retention.Config{Interval: time.Hour, BatchSize: 10000},
+		Archive:    archive.Config{Store: archive.StoreLocal, Prefix: "logs", LocalDir: "archive"},
+		Tracing:    tracing.Config{Exporter: tracing.ExporterNone, SampleRatio: 1},
+		Logging:    logging.Config{Level: "info", Format: logging.FormatJSON},
+		Ingest:     IngestConfig{UnknownLevels: string(ingest.LevelPolicyTag)},
+		Inputs: InputsConfig{
+			FluentForward: InputConfig{Tenant: models.DefaultTenant},
+			GELF:          InputConfig{Tenant: models.DefaultTenant},
+		},
+	}
+}
+
+// Validate checks the configuration and fills in values derived from
+// others, such as rate limit bursts
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Database.URL != "", "database.url is required")
+	check(c.Database.MaxConns >= 0 && c.Database.MinConns >= 0, "database pool sizes must not be negative")
+	check(c.Database.MaxConns == 0 || c.Database.MinConns <= c.Database.MaxConns,
+		"database.min_conns must not exceed database.max_conns")
+	check(c.Database.MaxConnLifetime >= 0 && c.Database.MaxConnIdleTime >= 0, "database connection lifetimes must not be negative")
+	check(c.Redis.PoolSize >= 0, "redis.pool_size must not be negative")
+
+	check(c.Stream.StreamName != "", "stream.name is required")
+	check(c.Stream.ConsumerGroup != "", "stream.consumer_group is required")
+	check(c.Stream.ConsumerName != "", "stream.consumer_name is required")
+	check(c.Stream.BatchSize > 0, "stream.batch_size must be positive")
+	check(c.Stream.BlockTimeout > 0, "stream.block_timeout must be positive")
+	check(c.Stream.RetryDelay > 0, "stream.retry_delay must be positive")
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.MaxBodyBytes > 0, "server.max_body_bytes must be positive")
+
+	tls := &c.Server.TLS
+	check(!tls.Enabled() || (tls.CertFile != "" && tls.KeyFile != ""),
+		"server.tls.cert_file and server.tls.key_file must be set together")
+	if tls.ClientAuth == "" && tls.ClientCAFile != "" {
+		tls.ClientAuth = "request"
+	}
+	if _, err := tlsconfig.ParseClientAuth(tls.ClientAuth); err != nil {
+		errs = append(errs, fmt.Errorf("server.tls.client_auth: %w", err))
+	}
+
+	check(c.Prometheus.MaxServices > 0, "prometheus.max_services must be positive")
+	check(c.Health.Timeout > 0, "health.timeout must be positive")
+	check(c.Health.MaxStreamLag >= 0 && c.Health.MaxPublishBacklog >= 0, "health backlog limits must not be negative")
+	check(c.Health.WorkerStallTimeout > c.Stream.BlockTimeout+c.Stream.RetryDelay,
+		"health.worker_stall_timeout must exceed stream.block_timeout plus stream.retry_delay")
+
+	check(c.Partitions.DaysAhead > 0, "partitions.days_ahead must be positive")
+	check(c.Partitions.CheckInterval > 0, "partitions.check_interval must be positive")
+	check(c.Query.DefaultWindow > 0, "query.default_window must be positive")
+
+	if err := c.Retention.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("retention: %w", err))
+	}
+	if err := c.Archive.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("archive: %w", err))
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("tracing: %w", err))
+	}
+	if err := c.Logging.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("logging: %w", err))
+	}
+
+	if _, err := ingest.ParseLevelPolicy(c.Ingest.UnknownLevels); err != nil {
+		errs = append(errs, fmt.Errorf("ingest.unknown_levels: %w", err))
+	}
+
+	for _, in := range []struct {
+		name  string
+		input *InputConfig
+	}{
+		{"inputs.fluent_forward", &c.Inputs.FluentForward},
+		{"inputs.gelf_udp", &c.Inputs.GELF},
+	} {
+		if in.input.Tenant == "" {
+			in.input.Tenant = models.DefaultTenant
+		}
+		if err := models.ValidateTenantID(in.input.Tenant); err != nil {
+			errs = append(errs, fmt.Errorf("%s.tenant: %w", in.name, err))
+		}
+	}
+
+	for _, l := range []struct {
+		name  string
+		limit *ratelimit.Limit
+	}{
+		{"rate_limits.per_key", &c.RateLimits.PerKey},
+		{"rate_limits.per_service", &c.RateLimits.PerService},
+	} {
+		check(l.limit.Rate >= 0 && l.limit.Burst >= 0, "%s must not be negative", l.name)
+		// The burst defaults to one second of logs
+		if l.limit.Rate > 0 && l.limit.Burst == 0 {
+			l.limit.Burst = int(math.Ceil(l.limit.Rate))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// PoolConfig returns the pgx pool configuration
+func (c DatabaseConfig) PoolConfig() (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	if c.MaxConns > 0 {
+		poolConfig.MaxConns = int32(c.MaxConns)
+	}
+	if c.MinConns > 0 {
+		poolConfig.MinConns = int32(c.MinConns)
+	}
+	if c.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = c.MaxConnLifetime
+	}
+	if c.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = c.MaxConnIdleTime
+	}
+	// Spans are only exported once tracing.Setup installs a provider
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+	return poolConfig, nil
+}
+
+// Options returns the options of a new Redis client
+func (c RedisConfig) Options() (*redis.Options, error) {
+	opts, err := redis.ParseURL(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+	}
+	if c.PoolSize > 0 {
+		opts.PoolSize = c.PoolSize
+	}
+	return opts, nil
+}
+
+// maskedValue replaces secrets in Masked
+const maskedValue = "[REDACTED]"
+
+// Masked returns a copy of c with secrets replaced, for display
+func (c *Config) Masked() *Config {
+	masked := *c
+	masked.Database.URL = maskURL(c.Database.URL)
+	masked.Redis.URL = maskURL(c.Redis.URL)
+	masked.Auth.AdminAPIKey = maskSecret(c.Auth.AdminAPIKey)
+	masked.Auth.MetricsToken = maskSecret(c.Auth.MetricsToken)
+	masked.Redaction.HashKey = maskSecret(c.Redaction.HashKey)
+	masked.Archive.S3.SecretAccessKey = maskSecret(c.Archive.S3.SecretAccessKey)
+	return &masked
+}
+
+// Document returns c as nested maps keyed like the config file
+func (c *Config) Document() (map[string]interface{}, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return doc, nil
+}
+
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return maskedValue
+}
+
+// maskURL hides the password of a URL, including a password query
+// parameter. Strings that are not URLs, such as key=value connection
+// strings, are hidden entirely.
+func maskURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return maskedValue
+	}
+	if query := u.Query(); query.Has("password") {
+		query.Set("password", "xxxxx")
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the config file used when neither -config nor CONFIG_FILE is set
+const DefaultFile = "config/config.yaml"
+
+// envVars maps flag names to the environment variables that set them
+var envVars = map[string]string{
+	"config":                        "CONFIG_FILE",
+	"database.url":                  "DATABASE_URL",
+	"database.max_conns":            "DATABASE_MAX_CONNS",
+	"database.min_conns":            "DATABASE_MIN_CONNS",
+	"database.max_conn_lifetime":    "DATABASE_MAX_CONN_LIFETIME",
+	"database.max_conn_idle_time":   "DATABASE_MAX_CONN_IDLE_TIME",
+	"redis.url":                     "REDIS_URL",
+	"redis.pool_size":               "REDIS_POOL_SIZE",
+	"stream.name":                   "STREAM_NAME",
+	"stream.consumer_group":         "STREAM_CONSUMER_GROUP",
+	"stream.consumer_name":          "STREAM_CONSUMER_NAME",
+	"stream.batch_size":             "STREAM_BATCH_SIZE",
+	"stream.block_timeout":          "STREAM_BLOCK_TIMEOUT",
+	"stream.retry_delay":            "STREAM_RETRY_DELAY",
+	"worker.http_addr":              "WORKER_HTTP_ADDR",
+	"server.addr":                   "HTTP_ADDR",
+	"server.read_timeout":           "HTTP_READ_TIMEOUT",
+	"server.write_timeout":          "HTTP_WRITE_TIMEOUT",
+	"server.idle_timeout":           "HTTP_IDLE_TIMEOUT",
+	"server.shutdown_timeout":       "SHUTDOWN_TIMEOUT",
+	"server.max_body_bytes":         "HTTP_MAX_BODY_BYTES",
+	"server.tls.cert_file":          "TLS_CERT_FILE",
+	"server.tls.key_file":           "TLS_KEY_FILE",
+	"server.tls.client_ca_file":     "TLS_CLIENT_CA_FILE",
+	"server.tls.client_auth":        "TLS_CLIENT_AUTH",
+	"auth.enabled":                  "AUTH_ENABLED",
+	"auth.admin_api_key":            "ADMIN_API_KEY",
+	"auth.metrics_token":            "METRICS_TOKEN",
+	"ingest.unknown_levels":         "UNKNOWN_LEVEL_POLICY",
+	"inputs.fluent_forward.addr":    "FLUENT_FORWARD_ADDR",
+	"inputs.fluent_forward.tenant":  "FLUENT_FORWARD_TENANT",
+	"inputs.gelf_udp.addr":          "GELF_UDP_ADDR",
+	"inputs.gelf_udp.tenant":        "GELF_UDP_TENANT",
+	"rate_limits.per_key.rate":      "RATE_LIMIT_KEY_RATE",
+	"rate_limits.per_key.burst":     "RATE_LIMIT_KEY_BURST",
+	"rate_limits.per_service.rate":  "RATE_LIMIT_SERVICE_RATE",
+	"rate_limits.per_service.burst": "RATE_LIMIT_SERVICE_BURST",
+	"redaction.enabled":             "REDACTION_ENABLED",
+	"redaction.hash_key":            "REDACTION_HASH_KEY",
+	"prometheus.max_services":       "PROMETHEUS_MAX_SERVICES",
+	"health.timeout":                "HEALTH_CHECK_TIMEOUT",
+	"health.max_stream_lag":         "HEALTH_MAX_STREAM_LAG",
+	"health.max_publish_backlog":    "HEALTH_MAX_PUBLISH_BACKLOG",
+	"health.worker_stall_timeout":   "HEALTH_WORKER_STALL_TIMEOUT",
+	"partitions.days_ahead":         "PARTITION_DAYS_AHEAD",
+	"partitions.check_interval":     "PARTITION_CHECK_INTERVAL",
+	"query.default_window":          "QUERY_DEFAULT_WINDOW",
+	"retention.enabled":             "RETENTION_ENABLED",
+	"retention.interval":            "RETENTION_INTERVAL",
+	"retention.batch_size":          "RETENTION_BATCH_SIZE",
+	"retention.default_ttl":         "RETENTION_DEFAULT_TTL",
+	"archive.enabled":               "ARCHIVE_ENABLED",
+	"archive.store":                 "ARCHIVE_STORE",
+	"archive.prefix":                "ARCHIVE_PREFIX",
+	"archive.local_dir":             "ARCHIVE_LOCAL_DIR",
+	"archive.s3.endpoint":           "ARCHIVE_S3_ENDPOINT",
+	"archive.s3.region":             "ARCHIVE_S3_REGION",
+	"archive.s3.bucket":             "ARCHIVE_S3_BUCKET",
+	"archive.s3.access_key_id":      "ARCHIVE_S3_ACCESS_KEY_ID",
+	"archive.s3.secret_access_key":  "ARCHIVE_S3_SECRET_ACCESS_KEY",
+	"tracing.exporter":              "TRACING_EXPORTER",
+	"tracing.endpoint":              "TRACING_ENDPOINT",
+	"tracing.insecure":              "TRACING_INSECURE",
+	"tracing.sample_ratio":          "TRACING_SAMPLE_RATIO",
+	"logging.level":                 "LOG_LEVEL",
+	"logging.format":                "LOG_FORMAT",
+}
+
+// Load reads the config file named by -config, CONFIG_FILE or DefaultFile,
+// overlays environment variables and then the flags in args, and validates
+// the result. It returns flag.ErrHelp when args ask for usage.
+func Load(name string, args []string) (*Config, error) {
+	// The first pass only finds the config file
+	path := DefaultFile
+	probe := newFlagSet(name, Default(), &path)
+	probe.SetOutput(io.Discard)
+	if err := applyEnv(probe); err != nil {
+		return nil, err
+	}
+	if err := probe.Parse(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+		return nil, err
+	}
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := newFlagSet(name, cfg, &path)
+	if err := applyEnv(fs); err != nil {
+		return nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// LoadFile reads the YAML file at path over the defaults. A missing file
+// leaves the defaults; unknown keys are rejected.
+func LoadFile(path string) (*Config, error) {
+	cfg := Default()
+	cfg.File = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyEnv sets every flag whose environment variable is set
+func applyEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		key, ok := envVars[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		if v := os.Getenv(key); v != "" {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("invalid %s %q: %w", key, v, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// newFlagSet binds a flag named after each YAML key to cfg. Flags default
+// to the values already in cfg.
+func newFlagSet(name string, cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(path, "config", *path, "path of the YAML config file")
+
+	fs.StringVar(&cfg.Database.URL, "database.url", cfg.Database.URL, "PostgreSQL connection URL")
+	fs.IntVar(&cfg.Database.MaxConns, "database.max_conns", cfg.Database.MaxConns, "maximum database connections (0 uses the pgx default)")
+	fs.IntVar(&cfg.Database.MinConns, "database.min_conns", cfg.Database.MinConns, "database connections kept open")
+	fs.DurationVar(&cfg.Database.MaxConnLifetime, "database.max_conn_lifetime", cfg.Database.MaxConnLifetime, "maximum age of a database connection")
+	fs.DurationVar(&cfg.Database.MaxConnIdleTime, "database.max_conn_idle_time", cfg.Database.MaxConnIdleTime, "maximum idle time of a database connection")
+
+	fs.StringVar(&cfg.Redis.URL, "redis.url", cfg.Redis.URL, "Redis URL (optional for the API)")
+	fs.IntVar(&cfg.Redis.PoolSize, "redis.pool_size", cfg.Redis.PoolSize, "connections per Redis client (0 uses the go-redis default)")
+
+	fs.StringVar(&cfg.Stream.StreamName, "stream.name", cfg.Stream.StreamName, "Redis stream carrying log events")
+	fs.StringVar(&cfg.Stream.ConsumerGroup, "stream.consumer_group", cfg.Stream.ConsumerGroup, "worker consumer group")
+	fs.StringVar(&cfg.Stream.ConsumerName, "stream.consumer_name", cfg.Stream.ConsumerName, "worker consumer name, unique per worker")
+	fs.IntVar(&cfg.Stream.BatchSize, "stream.batch_size", cfg.Stream.BatchSize, "events read per stream request")
+	fs.DurationVar(&cfg.Stream.BlockTimeout, "stream.block_timeout", cfg.Stream.BlockTimeout, "how long a stream read waits for events")
+	fs.DurationVar(&cfg.Stream.RetryDelay, "stream.retry_delay", cfg.Stream.RetryDelay, "pause after a failed stream read")
+
+	fs.StringVar(&cfg.Worker.HTTPAddr, "worker.http_addr", cfg.Worker.HTTPAddr, "worker metrics listen address (empty disables)")
+
+	fs.StringVar(&cfg.Server.Addr, "server.addr", cfg.Server.Addr, "HTTP listen address")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "server.read_timeout", cfg.Server.ReadTimeout, "time allowed to read a request, headers included")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "server.write_timeout", cfg.Server.WriteTimeout, "time allowed to write a response")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "server.idle_timeout", cfg.Server.IdleTimeout, "keep-alive idle timeout")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "server.shutdown_timeout", cfg.Server.ShutdownTimeout, "time to drain requests and stream publishes on shutdown")
+	fs.Int64Var(&cfg.Server.MaxBodyBytes, "server.max_body_bytes", cfg.Server.MaxBodyBytes, "maximum request body size")
+	fs.StringVar(&cfg.Server.TLS.CertFile, "server.tls.cert_file", cfg.Server.TLS.CertFile, "TLS certificate file; enables HTTPS")
+	fs.StringVar(&cfg.Server.TLS.KeyFile, "server.tls.key_file", cfg.Server.TLS.KeyFile, "TLS private key file")
+	fs.StringVar(&cfg.Server.TLS.ClientCAFile, "server.tls.client_ca_file", cfg.Server.TLS.ClientCAFile, "CA verifying client certificates")
+	fs.StringVar(&cfg.Server.TLS.ClientAuth, "server.tls.client_auth", cfg.Server.TLS.ClientAuth, "client certificates: none, request or require")
+
+	fs.BoolVar(&cfg.Auth.Enabled, "auth.enabled", cfg.Auth.Enabled, "require API keys (disable for local development only)")
+	fs.StringVar(&cfg.Auth.AdminAPIKey, "auth.admin_api_key", cfg.Auth.AdminAPIKey, "bootstrap admin key used to create the first API keys")
+	fs.StringVar(&cfg.Auth.MetricsToken, "auth.metrics_token", cfg.Auth.MetricsToken, "bearer token for scraping /metrics without an API key")
+
+	fs.StringVar(&cfg.Ingest.UnknownLevels, "ingest.unknown_levels", cfg.Ingest.UnknownLevels, "logs with unrecognised levels: tag or reject")
+
+	fs.StringVar(&cfg.Inputs.FluentForward.Addr, "inputs.fluent_forward.addr", cfg.Inputs.FluentForward.Addr, "Fluent Forward listen address (empty disables)")
+	fs.StringVar(&cfg.Inputs.FluentForward.Tenant, "inputs.fluent_forward.tenant", cfg.Inputs.FluentForward.Tenant, "tenant of Fluent Forward logs")
+	fs.StringVar(&cfg.Inputs.GELF.Addr, "inputs.gelf_udp.addr", cfg.Inputs.GELF.Addr, "GELF UDP listen address (empty disables)")
+	fs.StringVar(&cfg.Inputs.GELF.Tenant, "inputs.gelf_udp.tenant", cfg.Inputs.GELF.Tenant, "tenant of GELF UDP logs")
+
+	fs.Float64Var(&cfg.RateLimits.PerKey.Rate, "rate_limits.per_key.rate", cfg.RateLimits.PerKey.Rate, "logs/second per API key (0 disables)")
+	fs.IntVar(&cfg.RateLimits.PerKey.Burst, "rate_limits.per_key.burst", cfg.RateLimits.PerKey.Burst, "burst per API key (defaults to one second of logs)")
+	fs.Float64Var(&cfg.RateLimits.PerService.Rate, "rate_limits.per_service.rate", cfg.RateLimits.PerService.Rate, "logs/second per tenant and service (0 disables)")
+	fs.IntVar(&cfg.RateLimits.PerService.Burst, "rate_limits.per_service.burst", cfg.RateLimits.PerService.Burst, "burst per tenant and service (defaults to one second of logs)")
+
+	fs.BoolVar(&cfg.Redaction.Enabled, "redaction.enabled", cfg.Redaction.Enabled, "redact sensitive values at ingest")
+	fs.StringVar(&cfg.Redaction.HashKey, "redaction.hash_key", cfg.Redaction.HashKey, "key of the hash redaction action")
+
+	fs.IntVar(&cfg.Prometheus.MaxServices, "prometheus.max_services", cfg.Prometheus.MaxServices, "services exported with their own Prometheus series")
+
+	fs.DurationVar(&cfg.Health.Timeout, "health.timeout", cfg.Health.Timeout, "time allowed for the checks of one health request")
+	fs.Int64Var(&cfg.Health.MaxStreamLag, "health.max_stream_lag", cfg.Health.MaxStreamLag, "undelivered stream entries before readiness degrades")
+	fs.Int64Var(&cfg.Health.MaxPublishBacklog, "health.max_publish_backlog", cfg.Health.MaxPublishBacklog, "logs waiting to be published before readiness degrades")
+	fs.DurationVar(&cfg.Health.WorkerStallTimeout, "health.worker_stall_timeout", cfg.Health.WorkerStallTimeout, "time without worker progress before liveness fails")
+
+	fs.IntVar(&cfg.Partitions.DaysAhead, "partitions.days_ahead", cfg.Partitions.DaysAhead, "days after today whose log partitions are created in advance")
+	fs.DurationVar(&cfg.Partitions.CheckInterval, "partitions.check_interval", cfg.Partitions.CheckInterval, "how often missing log partitions are created")
+	fs.DurationVar(&cfg.Query.DefaultWindow, "query.default_window", cfg.Query.DefaultWindow, "time range searched when a log query sets no start_time")
+
+	fs.BoolVar(&cfg.Retention.Enabled, "retention.enabled", cfg.Retention.Enabled, "remove logs past their retention TTL")
+	fs.DurationVar(&cfg.Retention.Interval, "retention.interval", cfg.Retention.Interval, "how often retention rules are enforced")
+	fs.IntVar(&cfg.Retention.BatchSize, "retention.batch_size", cfg.Retention.BatchSize, "logs removed by one retention DELETE")
+	fs.DurationVar(&cfg.Retention.DefaultTTL, "retention.default_ttl", cfg.Retention.DefaultTTL, "TTL of logs no retention rule matches (0 keeps them)")
+
+	fs.BoolVar(&cfg.Archive.Enabled, "archive.enabled", cfg.Archive.Enabled, "archive logs before retention removes them")
+	fs.StringVar(&cfg.Archive.Store, "archive.store", cfg.Archive.Store, "archive store: local or s3")
+	fs.StringVar(&cfg.Archive.Prefix, "archive.prefix", cfg.Archive.Prefix, "prefix of archive object keys")
+	fs.StringVar(&cfg.Archive.LocalDir, "archive.local_dir", cfg.Archive.LocalDir, "directory of the local archive store")
+	fs.StringVar(&cfg.Archive.S3.Endpoint, "archive.s3.endpoint", cfg.Archive.S3.Endpoint, "S3-compatible API base URL")
+	fs.StringVar(&cfg.Archive.S3.Region, "archive.s3.region", cfg.Archive.S3.Region, "S3 region")
+	fs.StringVar(&cfg.Archive.S3.Bucket, "archive.s3.bucket", cfg.Archive.S3.Bucket, "S3 bucket of archives")
+	fs.StringVar(&cfg.Archive.S3.AccessKeyID, "archive.s3.access_key_id", cfg.Archive.S3.AccessKeyID, "S3 access key ID")
+	fs.StringVar(&cfg.Archive.S3.SecretAccessKey, "archive.s3.secret_access_key", cfg.Archive.S3.SecretAccessKey, "S3 secret access key")
+
+	fs.StringVar(&cfg.Tracing.Exporter, "tracing.exporter", cfg.Tracing.Exporter, "trace exporter: none, otlp or stdout")
+	fs.StringVar(&cfg.Tracing.Endpoint, "tracing.endpoint", cfg.Tracing.Endpoint, "OTLP/HTTP collector host:port")
+	fs.BoolVar(&cfg.Tracing.Insecure, "tracing.insecure", cfg.Tracing.Insecure, "send OTLP over plain HTTP")
+	fs.Float64Var(&cfg.Tracing.SampleRatio, "tracing.sample_ratio", cfg.Tracing.SampleRatio, "share of new traces recorded")
+
+	fs.StringVar(&cfg.Logging.Level, "logging.level", cfg.Logging.Level, "level of LogScale's own logs: debug, info, warn or error")
+	fs.StringVar(&cfg.Logging.Format, "logging.format", cfg.Logging.Format, "format of LogScale's own logs: json or text")
+
+	return fs
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/yunjin08/logscale/internal/logging"
+)
+
+// ReloadFunc checks the next config and returns a function that applies it.
+// It must not change anything itself, so that an invalid section leaves
+// every component on the old config.
+type ReloadFunc func(next *Config) (apply func(), err error)
+
+// Reloader re-reads the config on SIGHUP or when the config file changes.
+// Only rate limits, pipelines, redaction, retention rules and the log level
+// are applied; changes to other settings are reported as needing a restart.
+type Reloader struct {
+	name string
+	args []string
+
+	mu       sync.Mutex
+	handlers []ReloadFunc
+	modTime  time.Time
+
+	current  atomic.Pointer[Config]
+	loadedAt atomic.Pointer[time.Time]
+}
+
+// NewReloader creates a reloader for cfg, which Load returned for name and args
+func NewReloader(name string, args []string, cfg *Config) *Reloader {
+	r := &Reloader{name: name, args: args, modTime: fileModTime(cfg.File)}
+	now := time.Now().UTC()
+	r.current.Store(cfg)
+	r.loadedAt.Store(&now)
+	return r
+}
+
+// OnReload registers fn to check and apply reloaded configs
+func (r *Reloader) OnReload(fn ReloadFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, fn)
+}
+
+// Current returns the config in effect
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// LoadedAt returns when the config in effect was loaded
+func (r *Reloader) LoadedAt() time.Time {
+	return *r.loadedAt.Load()
+}
+
+// Reload loads the config again and applies its reloadable sections if
+// every handler accepts them
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.current.Load()
+	r.modTime = fileModTime(current.File)
+
+	loaded, err := Load(r.name, r.args)
+	if err != nil {
+		return err
+	}
+
+	next := *current
+	next.RateLimits = loaded.RateLimits
+	next.Pipelines = loaded.Pipelines
+	next.Redaction = loaded.Redaction
+	next.Retention = loaded.Retention
+	next.Retention.Interval = current.Retention.Interval
+	next.Logging.Level = loaded.Logging.Level
+
+	applies := make([]func(), 0, len(r.handlers))
+	for _, fn := range r.handlers {
+		apply, err := fn(&next)
+		if err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+		applies = append(applies, apply)
+	}
+	for _, apply := range applies {
+		apply()
+	}
+
+	if sections := restartSections(current, loaded); len(sections) > 0 {
+		slog.Warn("config changes need a restart", "sections", sections)
+	}
+
+	now := time.Now().UTC()
+	r.current.Store(&next)
+	r.loadedAt.Store(&now)
+	return nil
+}
+
+// Watch reloads the config on SIGHUP and when the config file's
+// modification time changes, checked every interval, until ctx is done.
+// A failed reload keeps the config in effect.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reloadAndLog("SIGHUP")
+		case <-ticker.C:
+			if r.changed() {
+				r.reloadAndLog("config file change")
+			}
+		}
+	}
+}
+
+func (r *Reloader) reloadAndLog(reason string) {
+	if err := r.Reload(); err != nil {
+		slog.Error("failed to reload config, keeping the current config", "reason", reason, logging.Err(err))
+		return
+	}
+	slog.Info("reloaded config", "reason", reason)
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !fileModTime(r.current.Load().File).Equal(r.modTime)
+}
+
+// fileModTime returns the modification time of path, or zero when it is missing
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// restartSections names the sections that differ between a and b and are
+// only read at startup
+func restartSections(a, b *Config) []string {
+	var sections []string
+	for _, s := range []struct {
+		name string
+		a, b interface{}
+	}{
+		{"database", a.Database, b.Database},
+		{"redis", a.Redis, b.Redis},
+		{"stream", a.Stream, b.Stream},
+		{"worker", a.Worker, b.Worker},
+		{"server", a.Server, b.Server},
+		{"auth", a.Auth, b.Auth},
+		{"ingest", a.Ingest, b.Ingest},
+		{"inputs", a.Inputs, b.Inputs},
+		{"prometheus", a.Prometheus, b.Prometheus},
+		{"health", a.Health, b.Health},
+		{"partitions", a.Partitions, b.Partitions},
+		{"query", a.Query, b.Query},
+		{"retention.interval", a.Retention.Interval, b.Retention.Interval},
+		{"archive", a.Archive, b.Archive},
+		{"tracing", a.Tracing, b.Tracing},
+		{"logging.format", a.Logging.Format, b.Logging.Format},
+	} {
+		if !reflect.DeepEqual(s.a, s.b) {
+			sections = append(sections, s.name)
+		}
+	}
+	return sections
+}
@@ -0,0 +1,110 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// SchemaVersion is the latest migration in db/migrations. Bump it with
+// every new migration so instances refuse traffic until it is applied.
+const SchemaVersion = 11
+
+// Pinger is a connection pool that can be pinged, such as *pgxpool.Pool
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Querier runs single-row queries, such as *pgxpool.Pool
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Postgres checks that the database answers
+func Postgres(db Pinger) CheckFunc {
+	return func(ctx context.Context) error {
+		if err := db.Ping(ctx); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+		return nil
+	}
+}
+
+// Schema checks that migrations up to want were applied cleanly. Newer
+// schemas pass so that old instances keep serving during a rollout.
+func Schema(db Querier, want int64) CheckFunc {
+	return func(ctx context.Context) error {
+		var version int64
+		var dirty bool
+		err := db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("no migrations applied (want version %d)", want)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read schema version: %w", err)
+		}
+		if dirty {
+			return fmt.Errorf("migration %d failed and left the schema dirty", version)
+		}
+		if version < want {
+			return fmt.Errorf("schema version %d is behind %d, run the migrations", version, want)
+		}
+		return nil
+	}
+}
+
+// Redis checks that Redis answers
+func Redis(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) error {
+		if err := client.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("failed to ping Redis: %w", err)
+		}
+		return nil
+	}
+}
+
+// StreamLag checks that the consumer group exists and has at most maxLag
+// entries waiting to be delivered, which catches stopped or stuck workers
+func StreamLag(client *redis.Client, stream, group string, maxLag int64) CheckFunc {
+	return func(ctx context.Context) error {
+		groups, err := client.XInfoGroups(ctx, stream).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read consumer groups of stream %s: %w", stream, err)
+		}
+		for _, g := range groups {
+			if g.Name != group {
+				continue
+			}
+			// Redis reports -1 when the lag cannot be determined
+			if g.Lag > maxLag {
+				return fmt.Errorf("consumer group %s is %d entries behind (max %d)", group, g.Lag, maxLag)
+			}
+			return nil
+		}
+		return fmt.Errorf("consumer group %s does not exist, no worker has started", group)
+	}
+}
+
+// Backlog checks that at most max items are waiting, as reported by pending
+func Backlog(what string, pending func() int64, max int64) CheckFunc {
+	return func(ctx context.Context) error {
+		if n := pending(); n > max {
+			return fmt.Errorf("%d %s pending (max %d)", n, what, max)
+		}
+		return nil
+	}
+}
+
+// Heartbeat checks that a loop reported progress, through last, within maxAge
+func Heartbeat(what string, last func() time.Time, maxAge time.Duration) CheckFunc {
+	return func(ctx context.Context) error {
+		if age := time.Since(last()); age > maxAge {
+			return fmt.Errorf("%s made no progress for %s (max %s)", what, age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/models"
+)
+
+// Statuses of checks and of the overall response
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// CheckFunc reports a dependency's problem as an error
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Checker runs a set of checks concurrently. A failed critical check makes
+// the result unhealthy; other failures only degrade it.
+type Checker struct {
+	timeout time.Duration
+	checks  []check
+}
+
+// NewChecker creates a checker that gives each check timeout to finish
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Critical adds a check that must pass for the result to be healthy
+func (c *Checker) Critical(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, critical: true, fn: fn})
+}
+
+// Informational adds a check whose failure only degrades the result
+func (c *Checker) Informational(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Run runs every check and reports each one's status and latency
+func (c *Checker) Run(ctx context.Context) models.HealthResponse {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]models.HealthCheck, len(c.checks))
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := ch.fn(ctx)
+			result := models.HealthCheck{
+				Name:      ch.name,
+				Status:    StatusHealthy,
+				Critical:  ch.critical,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = StatusUnhealthy
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	status := StatusHealthy
+	for _, r := range results {
+		if r.Status == StatusHealthy {
+			continue
+		}
+		if r.Critical {
+			status = StatusUnhealthy
+			break
+		}
+		status = StatusDegraded
+	}
+
+	return models.HealthResponse{
+		Status:    status,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Checks:    results,
+	}
+}
+
+// StatusCode is the HTTP status of a health response: 503 when unhealthy
+func StatusCode(resp models.HealthResponse) int {
+	if resp.Status == StatusUnhealthy {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// Handler serves the checker's result as JSON, for binaries without gin
+func Handler(c *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := c.Run(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(StatusCode(resp))
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.WarnContext(r.Context(), "failed to write health response", logging.Err(err))
+		}
+	})
+}
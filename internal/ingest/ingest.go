@@ -0,0 +1,274 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/pipeline"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/internal/redact"
+	"github.com/yunjin08/logscale/internal/stream"
+	"github.com/yunjin08/logscale/models"
+)
+
+// ErrInvalidLog is returned when a log request fails validation
+var ErrInvalidLog = errors.New("invalid log")
+
+// Service is the shared ingestion pipeline used by every log input.
+// It persists incoming logs and publishes them to the Redis Stream.
+type Service struct {
+	helper    *helpers.LogHelper
+	streamSvc *stream.RedisStreamService
+	options   Options
+
+	// pending tracks stream publishes that have not finished yet, and
+	// backlog the logs they still have to publish. draining, guarded by mu,
+	// stops new publishes once Drain has started waiting for them.
+	mu       sync.Mutex
+	draining bool
+	pending  sync.WaitGroup
+	backlog  atomic.Int64
+}
+
+// Options configures how logs are checked before they are stored
+type Options struct {
+	// UnknownLevels decides what happens to logs with an unrecognised level
+	UnknownLevels LevelPolicy
+	// Quotas tracks daily usage and enforces quotas; nil disables both
+	Quotas *quota.Service
+	// Pipeline transforms logs before anything else; nil disables it
+	Pipeline *pipeline.Runner
+	// Redactor removes sensitive values before logs are stored; nil disables redaction
+	Redactor *redact.Redactor
+}
+
+// NewService creates a new ingestion service. streamSvc may be nil when
+// Redis is not configured.
+func NewService(helper *helpers.LogHelper, streamSvc *stream.RedisStreamService, options Options) *Service {
+	if options.UnknownLevels == "" {
+		options.UnknownLevels = LevelPolicyTag
+	}
+	return &Service{
+		helper:    helper,
+		streamSvc: streamSvc,
+		options:   options,
+	}
+}
+
+// IngestOne persists a single log for a tenant and publishes it to the
+// stream. It returns a nil log when the pipeline dropped the log or a quota
+// sampled it out.
+func (s *Service) IngestOne(ctx context.Context, tenantID string, req models.LogRequest) (*models.Log, error) {
+	keep, err := s.prepare(tenantID, &req)
+	if err != nil {
+		return nil, err
+	}
+	if !keep {
+		countOutcome(outcomeDropped, 1)
+		return nil, nil
+	}
+
+	kept, err := s.applyQuotas(ctx, tenantID, []models.LogRequest{req})
+	if err != nil {
+		return nil, err
+	}
+	if len(kept) == 0 {
+		countOutcome(outcomeSampled, 1)
+		return nil, nil
+	}
+
+	log, err := s.helper.CreateSingleLog(ctx, tenantID, req)
+	if err != nil {
+		return nil, err
+	}
+	countOutcome(outcomeStored, 1)
+
+	s.publish(ctx, []models.Log{*log})
+	s.recordUsage(ctx, tenantID, kept)
+	return log, nil
+}
+
+// Ingest persists a batch of logs for a tenant in one transaction and publishes them to the stream
+func (s *Service) Ingest(ctx context.Context, tenantID string, reqs []models.LogRequest) ([]models.Log, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("%w: no logs provided", ErrInvalidLog)
+	}
+	prepared := make([]models.LogRequest, 0, len(reqs))
+	for i, req := range reqs {
+		keep, err := s.prepare(tenantID, &req)
+		if err != nil {
+			return nil, fmt.Errorf("log %d: %w", i, err)
+		}
+		if keep {
+			prepared = append(prepared, req)
+		}
+	}
+	countOutcome(outcomeDropped, len(reqs)-len(prepared))
+	if len(prepared) == 0 {
+		return []models.Log{}, nil
+	}
+	reqs, err := s.applyQuotas(ctx, tenantID, prepared)
+	if err != nil {
+		return nil, err
+	}
+	countOutcome(outcomeSampled, len(prepared)-len(reqs))
+	if len(reqs) == 0 {
+		return []models.Log{}, nil
+	}
+
+	logs, err := s.helper.CreateBatchLogs(ctx, tenantID, reqs)
+	if err != nil {
+		return nil, err
+	}
+	countOutcome(outcomeStored, len(logs))
+
+	s.publish(ctx, logs)
+	s.recordUsage(ctx, tenantID, reqs)
+	return logs, nil
+}
+
+// Ingest outcomes counted in metrics.IngestedLogs
+const (
+	outcomeStored  = "stored"
+	outcomeDropped = "dropped"
+	outcomeSampled = "sampled"
+)
+
+func countOutcome(outcome string, n int) {
+	if n > 0 {
+		metrics.IngestedLogs.WithLabelValues(outcome).Add(float64(n))
+	}
+}
+
+// applyQuotas returns the logs a tenant's quotas let through
+func (s *Service) applyQuotas(ctx context.Context, tenantID string, reqs []models.LogRequest) ([]models.LogRequest, error) {
+	if s.options.Quotas == nil {
+		return reqs, nil
+	}
+	return s.options.Quotas.Apply(ctx, tenantID, reqs)
+}
+
+// recordUsage adds stored logs to the daily usage and stores the internal
+// logs announcing crossed quota thresholds. Those do not count as usage.
+func (s *Service) recordUsage(ctx context.Context, tenantID string, stored []models.LogRequest) {
+	if s.options.Quotas == nil {
+		return
+	}
+
+	events := s.options.Quotas.Record(ctx, tenantID, stored)
+	if len(events) == 0 {
+		return
+	}
+
+	logs, err := s.helper.CreateBatchLogs(ctx, tenantID, events)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to store quota events", logging.KeyTenant, tenantID, logging.Err(err))
+		return
+	}
+	s.publish(ctx, logs)
+}
+
+// prepare runs the pipeline, then validates the request, normalizes its
+// level and redacts it. It returns false when the pipeline dropped the log.
+func (s *Service) prepare(tenantID string, req *models.LogRequest) (bool, error) {
+	if s.options.Pipeline != nil {
+		keep, err := s.options.Pipeline.Process(req)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidLog, err)
+		}
+		if !keep {
+			return false, nil
+		}
+	}
+	if err := Validate(*req); err != nil {
+		return false, err
+	}
+	if err := s.options.UnknownLevels.normalizeLevel(req); err != nil {
+		return false, err
+	}
+	if s.options.Redactor != nil {
+		if err := s.options.Redactor.Apply(tenantID, req); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidLog, err)
+		}
+	}
+	return true, nil
+}
+
+// Validate checks the fields that the HTTP binding enforces, so that
+// non-HTTP inputs are held to the same rules
+func Validate(req models.LogRequest) error {
+	if req.Service == "" {
+		return fmt.Errorf("%w: service is required", ErrInvalidLog)
+	}
+	if req.Level == "" {
+		return fmt.Errorf("%w: level is required", ErrInvalidLog)
+	}
+	if req.Message == "" {
+		return fmt.Errorf("%w: message is required", ErrInvalidLog)
+	}
+	return nil
+}
+
+// publish sends logs to the Redis Stream asynchronously. Failures are
+// logged and never fail the ingest, matching the API's original behaviour.
+// Publishes keep ctx's trace but outlive the request. Logs stored after
+// Drain has started are not published.
+func (s *Service) publish(ctx context.Context, logs []models.Log) {
+	if s.streamSvc == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.draining {
+		s.mu.Unlock()
+		slog.WarnContext(ctx, "stream publishes drained, logs not published", "count", len(logs))
+		return
+	}
+	s.pending.Add(1)
+	s.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	s.backlog.Add(int64(len(logs)))
+	go func() {
+		defer s.pending.Done()
+		for _, entry := range logs {
+			if err := s.streamSvc.PublishLogEvent(ctx, entry); err != nil {
+				slog.WarnContext(ctx, "failed to publish log", logging.KeyLogID, entry.ID, logging.KeyTenant, entry.TenantID, logging.Err(err))
+			}
+			s.backlog.Add(-1)
+		}
+	}()
+}
+
+// Backlog returns the number of stored logs waiting to be published
+func (s *Service) Backlog() int64 {
+	return s.backlog.Load()
+}
+
+// Drain refuses new stream publishes and waits for pending ones to finish,
+// or for ctx to end
+func (s *Service) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("stream publishes still pending: %w", ctx.Err())
+	}
+}
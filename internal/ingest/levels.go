@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// LevelPolicy decides what happens to logs whose level is not recognised
+type LevelPolicy string
+
+const (
+	// LevelPolicyReject fails the ingest with a validation error
+	LevelPolicyReject LevelPolicy = "reject"
+	// LevelPolicyTag stores the log as info and keeps the original level in meta.original_level
+	LevelPolicyTag LevelPolicy = "tag"
+)
+
+// originalLevelKey is the meta field holding an unrecognised level under LevelPolicyTag
+const originalLevelKey = "original_level"
+
+// ParseLevelPolicy parses a policy name, defaulting to LevelPolicyTag when empty
+func ParseLevelPolicy(s string) (LevelPolicy, error) {
+	switch LevelPolicy(s) {
+	case "":
+		return LevelPolicyTag, nil
+	case LevelPolicyReject, LevelPolicyTag:
+		return LevelPolicy(s), nil
+	default:
+		return "", fmt.Errorf("unknown level policy %q (want %q or %q)", s, LevelPolicyReject, LevelPolicyTag)
+	}
+}
+
+// normalizeLevel rewrites req.Level to its canonical form, applying the
+// policy when the level is not recognised
+func (p LevelPolicy) normalizeLevel(req *models.LogRequest) error {
+	if level, ok := models.NormalizeLevel(req.Level); ok {
+		req.Level = level
+		return nil
+	}
+
+	if p == LevelPolicyReject {
+		return fmt.Errorf("%w: unknown level %q", ErrInvalidLog, req.Level)
+	}
+
+	meta := map[string]interface{}{}
+	if len(req.Meta) > 0 && string(req.Meta) != "null" {
+		if err := json.Unmarshal(req.Meta, &meta); err != nil {
+			return fmt.Errorf("%w: meta must be a JSON object", ErrInvalidLog)
+		}
+	}
+	meta[originalLevelKey] = req.Level
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode meta: %w", err)
+	}
+	req.Meta = data
+	req.Level = models.LevelInfo
+	return nil
+}
@@ -0,0 +1,90 @@
+package fluentforward
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// eventTimeExtType is the msgpack extension type Fluentd uses for EventTime
+const eventTimeExtType = 0
+
+func init() {
+	msgpack.RegisterExt(eventTimeExtType, (*EventTime)(nil))
+}
+
+// EventTime is the nanosecond-precision timestamp of the Forward protocol,
+// encoded as ext type 0 with 32-bit seconds followed by 32-bit nanoseconds
+type EventTime struct {
+	time.Time
+}
+
+// MarshalMsgpack encodes the EventTime extension payload
+func (t *EventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()))       // #nosec G115 -- protocol field is 32-bit
+	binary.BigEndian.PutUint32(b[4:8], uint32(t.Nanosecond())) // #nosec G115 -- always < 1e9
+	return b, nil
+}
+
+// UnmarshalMsgpack decodes the EventTime extension payload
+func (t *EventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("invalid EventTime length: %d", len(b))
+	}
+	sec := binary.BigEndian.Uint32(b[0:4])
+	nsec := binary.BigEndian.Uint32(b[4:8])
+	t.Time = time.Unix(int64(sec), int64(nsec)).UTC()
+	return nil
+}
+
+// parseTime converts the time element of an entry into a time.Time.
+// Fluentd sends either an EventTime or integer seconds; some clients send floats.
+func parseTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case *EventTime:
+		return t.Time, nil
+	case EventTime:
+		return t.Time, nil
+	case float64:
+		sec := int64(t)
+		return time.Unix(sec, int64((t-float64(sec))*1e9)).UTC(), nil
+	case float32:
+		return parseTime(float64(t))
+	default:
+		if sec, ok := toInt64(v); ok {
+			return time.Unix(sec, 0).UTC(), nil
+		}
+		return time.Time{}, fmt.Errorf("unsupported time type %T", v)
+	}
+}
+
+// toInt64 converts any msgpack integer representation to int64
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true // #nosec G115 -- values come from timestamps and sizes
+	case uint:
+		return int64(n), true // #nosec G115 -- values come from timestamps and sizes
+	default:
+		return 0, false
+	}
+}
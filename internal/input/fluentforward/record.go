@@ -0,0 +1,104 @@
+package fluentforward
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// Record keys checked, in order, for each LogRequest field
+var (
+	serviceKeys = []string{"service", "service_name", "app"}
+	levelKeys   = []string{"level", "severity", "log_level"}
+	messageKeys = []string{"message", "log", "msg", "MESSAGE"}
+)
+
+// defaultLevel is used when a record carries no level
+const defaultLevel = models.LevelInfo
+
+// toLogRequest converts a Fluentd record into a LogRequest. The tag is used
+// as the service when the record has none, and every field that isn't mapped
+// onto a LogRequest field is kept in Meta along with the tag.
+func toLogRequest(tag string, ts time.Time, record map[string]interface{}) (models.LogRequest, error) {
+	fields := make(map[string]interface{}, len(record)+1)
+	for k, v := range record {
+		fields[k] = normalizeValue(v)
+	}
+
+	service := takeString(fields, serviceKeys)
+	if service == "" {
+		service = tag
+	}
+
+	level := takeString(fields, levelKeys)
+	if level == "" {
+		level = defaultLevel
+	}
+
+	message := takeString(fields, messageKeys)
+	if message == "" {
+		// Keep structured records that have no message field searchable
+		raw, err := json.Marshal(fields)
+		if err != nil {
+			return models.LogRequest{}, fmt.Errorf("failed to encode record: %w", err)
+		}
+		message = string(raw)
+	}
+
+	fields["fluent_tag"] = tag
+	meta, err := json.Marshal(fields)
+	if err != nil {
+		return models.LogRequest{}, fmt.Errorf("failed to encode meta: %w", err)
+	}
+
+	return models.LogRequest{
+		Service:   service,
+		Level:     level,
+		Message:   message,
+		Timestamp: &ts,
+		Meta:      meta,
+	}, nil
+}
+
+// takeString removes and returns the first non-empty string value found under keys
+func takeString(fields map[string]interface{}, keys []string) string {
+	for _, k := range keys {
+		if s, ok := fields[k].(string); ok && s != "" {
+			delete(fields, k)
+			return s
+		}
+	}
+	return ""
+}
+
+// normalizeValue converts msgpack-decoded values into types encoding/json can marshal
+func normalizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = normalizeValue(item)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[fmt.Sprint(normalizeValue(k))] = normalizeValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	case *EventTime:
+		return val.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,372 @@
+package fluentforward
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/yunjin08/logscale/internal/ingest"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/models"
+)
+
+// The listener takes no credentials, so every message is bounded before it
+// is held in memory
+const (
+	// maxMessageSize caps a Forward message as read from the connection, and
+	// the entries of a compressed chunk once decompressed
+	maxMessageSize = 16 << 20
+	// maxEntries caps the entries of one chunk
+	maxEntries = 100000
+)
+
+// errMessageTooLarge is returned when a message exceeds maxMessageSize
+var errMessageTooLarge = fmt.Errorf("message exceeds %d bytes", maxMessageSize)
+
+// Ingester persists converted logs; *ingest.Service satisfies it
+type Ingester interface {
+	Ingest(ctx context.Context, tenantID string, reqs []models.LogRequest) ([]models.Log, error)
+}
+
+// Server accepts Fluent Forward protocol connections (msgpack over TCP)
+// from Fluentd and Fluent Bit `forward` outputs.
+type Server struct {
+	addr        string
+	tenantID    string
+	ingester    Ingester
+	idleTimeout time.Duration
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// NewServer creates a new Forward protocol server listening on addr. The
+// protocol carries no credentials, so every log is stored for tenantID.
+func NewServer(addr, tenantID string, ingester Ingester) *Server {
+	return &Server{
+		addr:        addr,
+		tenantID:    tenantID,
+		ingester:    ingester,
+		idleTimeout: 5 * time.Minute,
+		conns:       make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the configured address and serves until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is cancelled. Open connections
+// are closed and drained before it returns.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+		s.closeConns()
+	}()
+
+	slog.Info("Fluent Forward input listening", "addr", ln.Addr().String())
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.wg.Wait()
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		s.trackConn(conn, true)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.trackConn(conn, false)
+			s.handleConn(ctx, conn)
+		}()
+	}
+}
+
+// handleConn decodes Forward messages from a connection until it is closed
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	r := &limitedReader{r: bufio.NewReader(conn)}
+	dec := msgpack.NewDecoder(r)
+	enc := msgpack.NewEncoder(conn)
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			return
+		}
+
+		r.n = maxMessageSize
+		var msg []interface{}
+		if err := dec.Decode(&msg); err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				slog.Warn("fluent forward: closing connection", "remote_addr", conn.RemoteAddr().String(), logging.Err(err))
+			}
+			return
+		}
+
+		reqs, chunk, err := decodeMessage(msg)
+		if err != nil {
+			slog.Warn("fluent forward: invalid message", "remote_addr", conn.RemoteAddr().String(), logging.Err(err))
+			return
+		}
+
+		if len(reqs) > 0 {
+			if _, err := s.ingester.Ingest(ctx, s.tenantID, reqs); err != nil {
+				if !rejected(err) {
+					// Without an ack the client retries the chunk on a new connection
+					slog.Error("fluent forward: failed to ingest records", logging.KeyTenant, s.tenantID, logging.KeyCount, len(reqs), logging.Err(err))
+					return
+				}
+				// Retrying would fail the same way and hold up the chunks
+				// buffered behind this one, so it is acknowledged and dropped
+				slog.Warn("fluent forward: dropping rejected records", logging.KeyTenant, s.tenantID, logging.KeyCount, len(reqs), "chunk", chunk, logging.Err(err))
+				metrics.RejectedInputLogs.WithLabelValues("fluent_forward").Add(float64(len(reqs)))
+			}
+		}
+
+		if chunk != "" {
+			if err := enc.Encode(map[string]string{"ack": chunk}); err != nil {
+				slog.Warn("fluent forward: failed to ack chunk", "chunk", chunk, logging.Err(err))
+				return
+			}
+		}
+	}
+}
+
+// rejected reports whether ingestion failed for good, as opposed to a
+// transient store error
+func rejected(err error) bool {
+	var exceeded *quota.ExceededError
+	return errors.Is(err, ingest.ErrInvalidLog) || errors.As(err, &exceeded)
+}
+
+// decodeMessage converts any of the four Forward protocol modes into log
+// requests and returns the chunk id to acknowledge, if one was requested.
+//
+//	Message:                 [tag, time, record, option?]
+//	Forward:                 [tag, [[time, record], ...], option?]
+//	PackedForward:           [tag, bin(entries), option?]
+//	CompressedPackedForward: [tag, bin(gzip(entries)), {"compressed": "gzip"}]
+func decodeMessage(msg []interface{}) ([]models.LogRequest, string, error) {
+	if len(msg) < 2 {
+		return nil, "", fmt.Errorf("message has %d elements", len(msg))
+	}
+
+	tag, ok := asString(msg[0])
+	if !ok || tag == "" {
+		return nil, "", fmt.Errorf("invalid tag")
+	}
+
+	var (
+		entries [][]interface{}
+		option  map[string]interface{}
+		err     error
+	)
+
+	switch payload := msg[1].(type) {
+	case []interface{}:
+		// Forward mode
+		if len(payload) > maxEntries {
+			return nil, "", fmt.Errorf("chunk has more than %d entries", maxEntries)
+		}
+		entries = make([][]interface{}, 0, len(payload))
+		for _, e := range payload {
+			entry, ok := e.([]interface{})
+			if !ok {
+				return nil, "", fmt.Errorf("invalid entry type %T", e)
+			}
+			entries = append(entries, entry)
+		}
+		option = optionAt(msg, 2)
+	case []byte, string:
+		// PackedForward and CompressedPackedForward modes
+		option = optionAt(msg, 2)
+		raw, _ := asBytes(payload)
+		entries, err = decodePackedEntries(raw, option)
+		if err != nil {
+			return nil, "", err
+		}
+	default:
+		// Message mode
+		if len(msg) < 3 {
+			return nil, "", fmt.Errorf("message mode requires a record")
+		}
+		entries = [][]interface{}{{msg[1], msg[2]}}
+		option = optionAt(msg, 3)
+	}
+
+	reqs := make([]models.LogRequest, 0, len(entries))
+	for _, entry := range entries {
+		req, err := entryToLogRequest(tag, entry)
+		if err != nil {
+			return nil, "", err
+		}
+		reqs = append(reqs, req)
+	}
+
+	chunk, _ := asString(option["chunk"])
+	return reqs, chunk, nil
+}
+
+// decodePackedEntries decodes a concatenated stream of [time, record] entries
+func decodePackedEntries(raw []byte, option map[string]interface{}) ([][]interface{}, error) {
+	var r io.Reader = bytes.NewReader(raw)
+
+	if compressed, _ := asString(option["compressed"]); compressed != "" {
+		if compressed != "gzip" {
+			return nil, fmt.Errorf("unsupported compression %q", compressed)
+		}
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip entries: %w", err)
+		}
+		defer gz.Close()
+		r = &limitedReader{r: bufio.NewReader(gz), n: maxMessageSize}
+	}
+
+	dec := msgpack.NewDecoder(r)
+	var entries [][]interface{}
+	for {
+		var entry []interface{}
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("failed to decode packed entry: %w", err)
+		}
+		if len(entries) == maxEntries {
+			return nil, fmt.Errorf("chunk has more than %d entries", maxEntries)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// limitedReader reads up to n more bytes from r and then fails with
+// errMessageTooLarge. It implements io.ByteScanner so that the msgpack
+// decoder reads from it without buffering ahead.
+type limitedReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, errMessageTooLarge
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func (l *limitedReader) ReadByte() (byte, error) {
+	if l.n <= 0 {
+		return 0, errMessageTooLarge
+	}
+	b, err := l.r.ReadByte()
+	if err == nil {
+		l.n--
+	}
+	return b, err
+}
+
+func (l *limitedReader) UnreadByte() error {
+	if err := l.r.UnreadByte(); err != nil {
+		return err
+	}
+	l.n++
+	return nil
+}
+
+// entryToLogRequest converts a single [time, record] entry
+func entryToLogRequest(tag string, entry []interface{}) (models.LogRequest, error) {
+	if len(entry) < 2 {
+		return models.LogRequest{}, fmt.Errorf("entry has %d elements", len(entry))
+	}
+
+	ts, err := parseTime(entry[0])
+	if err != nil {
+		return models.LogRequest{}, err
+	}
+
+	record, ok := normalizeValue(entry[1]).(map[string]interface{})
+	if !ok {
+		return models.LogRequest{}, fmt.Errorf("invalid record type %T", entry[1])
+	}
+
+	return toLogRequest(tag, ts, record)
+}
+
+// optionAt returns the option map at index i, or an empty map if absent
+func optionAt(msg []interface{}, i int) map[string]interface{} {
+	if len(msg) > i {
+		if opt, ok := normalizeValue(msg[i]).(map[string]interface{}); ok {
+			return opt
+		}
+	}
+	return map[string]interface{}{}
+}
+
+func asString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	default:
+		return "", false
+	}
+}
+
+func asBytes(v interface{}) ([]byte, bool) {
+	switch b := v.(type) {
+	case []byte:
+		return b, true
+	case string:
+		return []byte(b), true
+	default:
+		return nil, false
+	}
+}
+
+func (s *Server) trackConn(conn net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,133 @@
+package gelf
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// chunkHeaderSize is magic (2) + message id (8) + sequence number (1) + count (1)
+	chunkHeaderSize = 12
+	// maxChunks is the largest sequence count allowed by the GELF spec
+	maxChunks = 128
+	// chunkTimeout is how long the spec allows for all chunks to arrive
+	chunkTimeout = 5 * time.Second
+	// maxPendingMessages and maxPendingBytes bound the incomplete messages
+	// held at once, so that chunks of never completed messages cannot take
+	// unbounded memory until they expire
+	maxPendingMessages = 4096
+	maxPendingBytes    = 64 << 20
+)
+
+// isChunked reports whether a datagram carries the GELF chunk magic bytes
+func isChunked(datagram []byte) bool {
+	return len(datagram) >= 2 && datagram[0] == 0x1e && datagram[1] == 0x0f
+}
+
+// chunkSet holds the chunks received so far for one message id
+type chunkSet struct {
+	chunks   [][]byte
+	received int
+	size     int
+	first    time.Time
+}
+
+// assembler reassembles chunked GELF datagrams
+type assembler struct {
+	mu      sync.Mutex
+	pending map[string]*chunkSet
+	// size is the bytes held by all pending chunk sets
+	size int
+}
+
+func newAssembler() *assembler {
+	return &assembler{pending: make(map[string]*chunkSet)}
+}
+
+// add stores a chunk and returns the complete payload once every chunk of
+// the message has arrived, or nil while chunks are still outstanding
+func (a *assembler) add(datagram []byte, now time.Time) ([]byte, error) {
+	if len(datagram) < chunkHeaderSize {
+		return nil, fmt.Errorf("chunk shorter than header")
+	}
+
+	id := string(datagram[2:10])
+	seq := int(datagram[10])
+	count := int(datagram[11])
+	if count == 0 || count > maxChunks || seq >= count {
+		return nil, fmt.Errorf("invalid chunk %d of %d", seq, count)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	set, ok := a.pending[id]
+	if !ok {
+		// New messages are dropped while full; pending ones may complete
+		if len(a.pending) >= maxPendingMessages {
+			return nil, fmt.Errorf("too many chunked messages pending")
+		}
+		set = &chunkSet{chunks: make([][]byte, count), first: now}
+		a.pending[id] = set
+	}
+	if len(set.chunks) != count {
+		a.remove(id)
+		return nil, fmt.Errorf("chunk count changed for message")
+	}
+	if set.chunks[seq] != nil {
+		return nil, nil // duplicate
+	}
+
+	size := len(datagram) - chunkHeaderSize
+	if a.size+size > maxPendingBytes {
+		if set.received == 0 {
+			delete(a.pending, id)
+		}
+		return nil, fmt.Errorf("chunked messages pending exceed %d bytes", maxPendingBytes)
+	}
+	data := make([]byte, size)
+	copy(data, datagram[chunkHeaderSize:])
+	set.chunks[seq] = data
+	set.received++
+	set.size += size
+	a.size += size
+
+	if set.size > maxMessageSize {
+		a.remove(id)
+		return nil, fmt.Errorf("chunked message exceeds %d bytes", maxMessageSize)
+	}
+	if set.received < count {
+		return nil, nil
+	}
+
+	a.remove(id)
+	payload := make([]byte, 0, set.size)
+	for _, c := range set.chunks {
+		payload = append(payload, c...)
+	}
+	return payload, nil
+}
+
+// remove forgets the chunks of message id; callers hold a.mu
+func (a *assembler) remove(id string) {
+	if set, ok := a.pending[id]; ok {
+		a.size -= set.size
+		delete(a.pending, id)
+	}
+}
+
+// expire drops incomplete messages whose first chunk is older than the timeout
+func (a *assembler) expire(now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	dropped := 0
+	for id, set := range a.pending {
+		if now.Sub(set.first) > chunkTimeout {
+			a.remove(id)
+			dropped++
+		}
+	}
+	return dropped
+}
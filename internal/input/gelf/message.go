@@ -0,0 +1,163 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// maxMessageSize caps the decompressed size of a single GELF message
+const maxMessageSize = 8 << 20
+
+// syslogLevels maps GELF's syslog severity numbers onto LogScale levels
+var syslogLevels = map[int]string{
+	0: models.LevelFatal, // emergency
+	1: models.LevelFatal, // alert
+	2: models.LevelFatal, // critical
+	3: models.LevelError,
+	4: models.LevelWarn,
+	5: models.LevelInfo, // notice
+	6: models.LevelInfo,
+	7: models.LevelDebug,
+}
+
+// Parse decodes a (possibly compressed) GELF payload into a LogRequest
+func Parse(payload []byte) (models.LogRequest, error) {
+	raw, err := Decompress(payload)
+	if err != nil {
+		return models.LogRequest{}, err
+	}
+
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return models.LogRequest{}, fmt.Errorf("invalid GELF JSON: %w", err)
+	}
+
+	return toLogRequest(fields)
+}
+
+// Decompress detects gzip or zlib compression by magic bytes and inflates
+// the payload. Uncompressed payloads are returned unchanged.
+func Decompress(payload []byte) ([]byte, error) {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+
+	switch {
+	case len(payload) >= 2 && payload[0] == 0x1f && payload[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(payload))
+	case len(payload) >= 2 && payload[0]&0x0f == 0x08 && (uint16(payload[0])<<8|uint16(payload[1]))%31 == 0:
+		r, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		return payload, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compressed GELF payload: %w", err)
+	}
+	defer r.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress GELF payload: %w", err)
+	}
+	if len(raw) > maxMessageSize {
+		return nil, fmt.Errorf("GELF message exceeds %d bytes", maxMessageSize)
+	}
+	return raw, nil
+}
+
+// toLogRequest maps GELF fields onto a LogRequest. The service comes from
+// the `_service` additional field when present and the host otherwise;
+// additional fields are stored in Meta without their underscore prefix.
+func toLogRequest(fields map[string]interface{}) (models.LogRequest, error) {
+	host, _ := fields["host"].(string)
+	shortMessage, _ := fields["short_message"].(string)
+	if shortMessage == "" {
+		return models.LogRequest{}, fmt.Errorf("short_message is required")
+	}
+
+	meta := map[string]interface{}{}
+	if host != "" {
+		meta["host"] = host
+	}
+	if full, ok := fields["full_message"].(string); ok && full != "" {
+		meta["full_message"] = full
+	}
+	for k, v := range fields {
+		// _id is reserved by the GELF spec
+		if strings.HasPrefix(k, "_") && k != "_id" {
+			meta[strings.TrimPrefix(k, "_")] = v
+		}
+	}
+
+	service, _ := meta["service"].(string)
+	if service != "" {
+		delete(meta, "service")
+	} else {
+		service = host
+	}
+	if service == "" {
+		return models.LogRequest{}, fmt.Errorf("host or _service is required")
+	}
+
+	level, err := parseLevel(fields["level"])
+	if err != nil {
+		return models.LogRequest{}, err
+	}
+
+	req := models.LogRequest{
+		Service: service,
+		Level:   level,
+		Message: shortMessage,
+	}
+
+	if ts, ok := fields["timestamp"].(json.Number); ok {
+		seconds, err := ts.Float64()
+		if err != nil {
+			return models.LogRequest{}, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		sec, frac := math.Modf(seconds)
+		t := time.Unix(int64(sec), int64(frac*1e9)).UTC()
+		req.Timestamp = &t
+	}
+
+	if req.Meta, err = json.Marshal(meta); err != nil {
+		return models.LogRequest{}, fmt.Errorf("failed to encode meta: %w", err)
+	}
+
+	return req, nil
+}
+
+// parseLevel converts a syslog severity number into a LogScale level.
+// Messages without a level are treated as info.
+func parseLevel(v interface{}) (string, error) {
+	if v == nil {
+		return models.LevelInfo, nil
+	}
+
+	n, ok := v.(json.Number)
+	if !ok {
+		return "", fmt.Errorf("invalid level %v", v)
+	}
+	severity, err := n.Int64()
+	if err != nil {
+		return "", fmt.Errorf("invalid level %v", v)
+	}
+
+	level, ok := syslogLevels[int(severity)]
+	if !ok {
+		return "", fmt.Errorf("invalid level %d", severity)
+	}
+	return level, nil
+}
@@ -0,0 +1,116 @@
+package gelf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/models"
+)
+
+// maxDatagramSize is the largest UDP payload we read
+const maxDatagramSize = 65535
+
+// Ingester persists converted logs; *ingest.Service satisfies it
+type Ingester interface {
+	IngestOne(ctx context.Context, tenantID string, req models.LogRequest) (*models.Log, error)
+}
+
+// Server receives GELF messages over UDP, including chunked and
+// gzip/zlib-compressed datagrams.
+type Server struct {
+	addr      string
+	tenantID  string
+	ingester  Ingester
+	assembler *assembler
+}
+
+// NewServer creates a new GELF UDP server listening on addr. UDP carries
+// no credentials, so every log is stored for tenantID.
+func NewServer(addr, tenantID string, ingester Ingester) *Server {
+	return &Server{
+		addr:      addr,
+		tenantID:  tenantID,
+		ingester:  ingester,
+		assembler: newAssembler(),
+	}
+}
+
+// ListenAndServe listens on the configured address and serves until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	return s.Serve(ctx, conn)
+}
+
+// Serve reads datagrams from conn until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	go s.expireChunks(ctx)
+
+	slog.Info("GELF UDP input listening", "addr", conn.LocalAddr().String())
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to read datagram: %w", err)
+		}
+
+		payload := buf[:n]
+		if isChunked(payload) {
+			payload, err = s.assembler.add(payload, time.Now())
+			if err != nil {
+				slog.Warn("gelf: dropping chunk", "remote_addr", addr.String(), logging.Err(err))
+				continue
+			}
+			if payload == nil {
+				continue // waiting for more chunks
+			}
+		}
+
+		if err := s.handle(ctx, payload); err != nil {
+			slog.Warn("gelf: dropping message", "remote_addr", addr.String(), logging.Err(err))
+		}
+	}
+}
+
+// handle parses and ingests a complete GELF payload
+func (s *Server) handle(ctx context.Context, payload []byte) error {
+	req, err := Parse(payload)
+	if err != nil {
+		return err
+	}
+	_, err = s.ingester.IngestOne(ctx, s.tenantID, req)
+	return err
+}
+
+// expireChunks periodically drops incomplete chunked messages
+func (s *Server) expireChunks(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if dropped := s.assembler.expire(now); dropped > 0 {
+				slog.Warn("gelf: dropped incomplete chunked messages", logging.KeyCount, dropped)
+			}
+		}
+	}
+}
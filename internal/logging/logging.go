@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Field names shared by every package, so that one query finds an event
+// across the API and the worker
+const (
+	KeyError     = "error"
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+	KeyTenant    = "tenant_id"
+	KeyService   = "service"
+	KeyLogID     = "log_id"
+	// KeyLogLevel is the level of an ingested log, as opposed to the level
+	// of LogScale's own record
+	KeyLogLevel  = "log_level"
+	KeyStream    = "stream"
+	KeyGroup     = "group"
+	KeyConsumer  = "consumer"
+	KeyMessageID = "message_id"
+	KeyCount     = "count"
+)
+
+// Config configures LogScale's own logs
+type Config struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Format is json or text
+	Format string `yaml:"format"`
+}
+
+// Validate checks the level and format
+func (c Config) Validate() error {
+	if _, err := ParseLevel(c.Level); err != nil {
+		return err
+	}
+	switch c.Format {
+	case "", FormatJSON, FormatText:
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (want json or text)", c.Format)
+	}
+}
+
+// ParseLevel parses debug, info, warn or error. Empty means info.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown level %q (want debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// level is the level of the logger installed by Setup
+var level slog.LevelVar
+
+// Setup makes a logger writing to w the default, for slog and for the log
+// package. Every record carries app, and the request and trace IDs of the
+// context it was logged with.
+func Setup(w io.Writer, app string, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := SetLevel(cfg.Level); err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: &level}
+	var handler slog.Handler
+	if cfg.Format == FormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}).With("app", app))
+	// Records of the log package are written by the handler, which adds
+	// its own timestamp
+	log.SetFlags(0)
+	return nil
+}
+
+// SetLevel changes the level of the logger installed by Setup
+func SetLevel(s string) error {
+	l, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// Err is the attribute of an error
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying a request ID for the records logged with it
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, or an empty string
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request and trace IDs of a record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()), slog.String(KeySpanID, sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds LogScale's own metrics, served by Handler
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+var (
+	// HTTPRequests counts API requests by route and status
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes API request latency by route
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "logscale_http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration observes log table queries by operation
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "logscale_db_query_duration_seconds",
+		Help:    "Latency of log inserts and queries, by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// IngestedLogs counts logs reaching the ingestion pipeline by outcome
+	IngestedLogs = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_ingest_logs_total",
+		Help: "Logs accepted by the ingestion pipeline, by outcome (stored, dropped by a pipeline, sampled out by a quota).",
+	}, []string{"outcome"})
+
+	// RejectedInputLogs counts logs that push inputs acknowledged without
+	// storing them, because ingestion rejected them for good
+	RejectedInputLogs = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_input_rejected_logs_total",
+		Help: "Logs acknowledged but not stored because ingestion rejected them (invalid log, quota exceeded), by input.",
+	}, []string{"input"})
+
+	// StreamPublishes counts log events published to the Redis stream
+	StreamPublishes = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_stream_publishes_total",
+		Help: "Log events published to the Redis stream, by result.",
+	}, []string{"result"})
+
+	// WorkerEvents counts stream events handled by the worker
+	WorkerEvents = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_worker_events_total",
+		Help: "Stream events handled by the worker, by result.",
+	}, []string{"result"})
+
+	// WorkerEventDuration observes how long the worker takes per event
+	WorkerEventDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Name:    "logscale_worker_event_duration_seconds",
+		Help:    "Time spent processing one stream event.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// ProcessorErrors counts ingest pipeline processor failures
+	ProcessorErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_pipeline_processor_errors_total",
+		Help: "Ingest pipeline processor failures, by pipeline and processor.",
+	}, []string{"pipeline", "processor"})
+
+	// RetentionRows counts logs removed by retention rules
+	RetentionRows = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_retention_deleted_logs_total",
+		Help: "Logs removed by retention rules, by method (drop_partition or delete).",
+	}, []string{"method"})
+
+	// RetentionBytes counts the storage of logs removed by retention rules
+	RetentionBytes = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "logscale_retention_reclaimed_bytes_total",
+		Help: "Bytes of logs removed by retention rules, by method (drop_partition or delete).",
+	}, []string{"method"})
+
+	// ArchivedLogs counts logs written to archive files
+	ArchivedLogs = factory.NewCounter(prometheus.CounterOpts{
+		Name: "logscale_archive_logs_total",
+		Help: "Logs written to archive files.",
+	})
+
+	// ArchivedBytes counts the compressed size of archive files
+	ArchivedBytes = factory.NewCounter(prometheus.CounterOpts{
+		Name: "logscale_archive_bytes_total",
+		Help: "Compressed bytes of archive files uploaded.",
+	})
+)
+
+// Result labels
+const (
+	ResultOK     = "ok"
+	ResultFailed = "failed"
+)
+
+// ObserveDBQuery records the latency of a query started at start. Use it as
+// defer metrics.ObserveDBQuery("operation", time.Now()).
+func ObserveDBQuery(operation string, start time.Time) {
+	DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yunjin08/logscale/models"
+)
+
+// OtherServices labels the services folded together beyond the series limit
+const OtherServices = "__other__"
+
+var (
+	serviceLogsDesc = prometheus.NewDesc("logscale_service_logs_total",
+		"Logs stored per service and level.", []string{"service", "level"}, nil)
+	serviceErrorRateDesc = prometheus.NewDesc("logscale_service_error_rate",
+		"Share of a service's logs at error or fatal level.", []string{"service"}, nil)
+	serviceLastLogDesc = prometheus.NewDesc("logscale_service_last_log_timestamp_seconds",
+		"Time of a service's most recent log.", []string{"service"}, nil)
+	servicesDesc = prometheus.NewDesc("logscale_service_metrics_services",
+		"Services with metrics, including those folded into the other series.", nil, nil)
+	servicesFoldedDesc = prometheus.NewDesc("logscale_service_metrics_folded_services",
+		"Services reported together as "+OtherServices+": those beyond the series limit and any named "+OtherServices+".", nil, nil)
+)
+
+// ServiceCollector exports service metrics. Only the maxServices services
+// with the most logs get their own series; the rest are summed into
+// OtherServices so the series count stays bounded. A service named
+// OtherServices is summed in with them.
+type ServiceCollector struct {
+	services    []models.ServiceMetrics
+	maxServices int
+}
+
+// NewServiceCollector creates a collector for services
+func NewServiceCollector(services []models.ServiceMetrics, maxServices int) *ServiceCollector {
+	return &ServiceCollector{services: services, maxServices: maxServices}
+}
+
+// Describe implements prometheus.Collector
+func (c *ServiceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serviceLogsDesc
+	ch <- serviceErrorRateDesc
+	ch <- serviceLastLogDesc
+	ch <- servicesDesc
+	ch <- servicesFoldedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *ServiceCollector) Collect(ch chan<- prometheus.Metric) {
+	services := append([]models.ServiceMetrics(nil), c.services...)
+	sort.SliceStable(services, func(i, j int) bool {
+		if services[i].TotalLogs != services[j].TotalLogs {
+			return services[i].TotalLogs > services[j].TotalLogs
+		}
+		return services[i].Service < services[j].Service
+	})
+
+	// A service actually named OtherServices joins the folded ones, so that
+	// its series never duplicates theirs
+	named := make([]models.ServiceMetrics, 0, len(services))
+	var rest []models.ServiceMetrics
+	for _, m := range services {
+		if m.Service == OtherServices {
+			rest = append(rest, m)
+		} else {
+			named = append(named, m)
+		}
+	}
+	if len(named) > c.maxServices {
+		rest = append(rest, named[c.maxServices:]...)
+		named = named[:c.maxServices]
+	}
+
+	services = named
+	if len(rest) > 0 {
+		other := models.ServiceMetrics{Service: OtherServices}
+		for _, m := range rest {
+			other.TotalLogs += m.TotalLogs
+			other.FatalCount += m.FatalCount
+			other.ErrorCount += m.ErrorCount
+			other.WarningCount += m.WarningCount
+			other.InfoCount += m.InfoCount
+			other.DebugCount += m.DebugCount
+			other.TraceCount += m.TraceCount
+			if m.LastLogTime.After(other.LastLogTime) {
+				other.LastLogTime = m.LastLogTime
+			}
+		}
+		if other.TotalLogs > 0 {
+			other.ErrorRate = float64(other.ErrorCount+other.FatalCount) / float64(other.TotalLogs)
+		}
+		services = append(services, other)
+	}
+	folded := len(rest)
+
+	ch <- prometheus.MustNewConstMetric(servicesDesc, prometheus.GaugeValue, float64(len(c.services)))
+	ch <- prometheus.MustNewConstMetric(servicesFoldedDesc, prometheus.GaugeValue, float64(folded))
+
+	for _, m := range services {
+		// Logs with unrecognised levels are only in the total
+		unknown := m.TotalLogs - m.FatalCount - m.ErrorCount - m.WarningCount - m.InfoCount - m.DebugCount - m.TraceCount
+		for _, l := range []struct {
+			level string
+			count int64
+		}{
+			{models.LevelFatal, m.FatalCount},
+			{models.LevelError, m.ErrorCount},
+			{models.LevelWarn, m.WarningCount},
+			{models.LevelInfo, m.InfoCount},
+			{models.LevelDebug, m.DebugCount},
+			{models.LevelTrace, m.TraceCount},
+		} {
+			ch <- prometheus.MustNewConstMetric(serviceLogsDesc, prometheus.CounterValue, float64(l.count), m.Service, l.level)
+		}
+		if unknown > 0 {
+			ch <- prometheus.MustNewConstMetric(serviceLogsDesc, prometheus.CounterValue, float64(unknown), m.Service, "unknown")
+		}
+		ch <- prometheus.MustNewConstMetric(serviceErrorRateDesc, prometheus.GaugeValue, m.ErrorRate, m.Service)
+		if !m.LastLogTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(serviceLastLogDesc, prometheus.GaugeValue, float64(m.LastLogTime.Unix()), m.Service)
+		}
+	}
+}
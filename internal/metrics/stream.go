@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/yunjin08/logscale/internal/logging"
+)
+
+// streamScrapeTimeout bounds the Redis calls made per scrape
+const streamScrapeTimeout = 2 * time.Second
+
+var (
+	streamLengthDesc = prometheus.NewDesc("logscale_stream_length",
+		"Entries in the Redis stream.", []string{"stream"}, nil)
+	groupLagDesc = prometheus.NewDesc("logscale_stream_group_lag",
+		"Stream entries not yet delivered to the consumer group.", []string{"stream", "group"}, nil)
+	groupPendingDesc = prometheus.NewDesc("logscale_stream_group_pending",
+		"Entries delivered to the consumer group but not acknowledged.", []string{"stream", "group"}, nil)
+	groupConsumersDesc = prometheus.NewDesc("logscale_stream_group_consumers",
+		"Consumers in the consumer group.", []string{"stream", "group"}, nil)
+)
+
+// StreamCollector reports the length of a Redis stream and the lag and
+// pending entries of its consumer groups, read from Redis on every scrape
+type StreamCollector struct {
+	client *redis.Client
+	stream string
+}
+
+// NewStreamCollector creates a collector for stream
+func NewStreamCollector(client *redis.Client, stream string) *StreamCollector {
+	return &StreamCollector{client: client, stream: stream}
+}
+
+// Describe implements prometheus.Collector
+func (c *StreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- streamLengthDesc
+	ch <- groupLagDesc
+	ch <- groupPendingDesc
+	ch <- groupConsumersDesc
+}
+
+// Collect implements prometheus.Collector. Redis errors leave the metrics
+// out of the scrape.
+func (c *StreamCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), streamScrapeTimeout)
+	defer cancel()
+
+	length, err := c.client.XLen(ctx, c.stream).Result()
+	if err != nil {
+		slog.Warn("failed to read stream length", logging.KeyStream, c.stream, logging.Err(err))
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(streamLengthDesc, prometheus.GaugeValue, float64(length), c.stream)
+
+	groups, err := c.client.XInfoGroups(ctx, c.stream).Result()
+	if err != nil {
+		slog.Warn("failed to read stream consumer groups", logging.KeyStream, c.stream, logging.Err(err))
+		return
+	}
+	for _, g := range groups {
+		// Redis reports -1 when the lag cannot be determined
+		if g.Lag >= 0 {
+			ch <- prometheus.MustNewConstMetric(groupLagDesc, prometheus.GaugeValue, float64(g.Lag), c.stream, g.Name)
+		}
+		ch <- prometheus.MustNewConstMetric(groupPendingDesc, prometheus.GaugeValue, float64(g.Pending), c.stream, g.Name)
+		ch <- prometheus.MustNewConstMetric(groupConsumersDesc, prometheus.GaugeValue, float64(g.Consumers), c.stream, g.Name)
+	}
+}
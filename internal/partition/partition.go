@@ -0,0 +1,243 @@
+package partition
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/internal/logging"
+)
+
+// Table is the partitioned table. It has one partition per UTC day of
+// timestamp, named like logs_p20260118, and a default partition for logs of
+// days without their own.
+const Table = "logs"
+
+// DefaultPartition holds logs of days without a partition
+const DefaultPartition = Table + "_default"
+
+// Policy is the row-level security policy isolating tenants, set on Table
+// and on each of its partitions by migration 000010
+const Policy = "tenant_isolation"
+
+// PolicyExpression is the USING expression of Policy
+const PolicyExpression = "tenant_id = current_setting('logscale.tenant_id', true)"
+
+// Day returns the start of the UTC day containing t
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Name returns the name of the partition of the day containing t
+func Name(t time.Time) string {
+	return Table + "_p" + Day(t).Format("20060102")
+}
+
+// ParseName returns the day of a partition name, and false for names that
+// are not daily partitions
+func ParseName(name string) (time.Time, bool) {
+	prefix := Table + "_p"
+	if len(name) != len(prefix)+8 || name[:len(prefix)] != prefix {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("20060102", name[len(prefix):])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// Days returns the days from the one containing now through ahead days later
+func Days(now time.Time, ahead int) []time.Time {
+	first := Day(now)
+	days := make([]time.Time, 0, ahead+1)
+	for i := 0; i <= ahead; i++ {
+		days = append(days, first.AddDate(0, 0, i))
+	}
+	return days
+}
+
+// Manager creates the partitions of upcoming days before logs arrive for them
+type Manager struct {
+	db    *pgxpool.Pool
+	ahead int
+	now   func() time.Time
+}
+
+// NewManager creates a Manager keeping partitions for today and the next
+// ahead days
+func NewManager(db *pgxpool.Pool, ahead int) *Manager {
+	return &Manager{db: db, ahead: ahead, now: time.Now}
+}
+
+// List returns the names of the daily partitions, oldest first
+func List(ctx context.Context, db *pgxpool.Pool) ([]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = $1::regclass
+		ORDER BY c.relname
+	`, Table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	daily := names[:0]
+	for _, name := range names {
+		if _, ok := ParseName(name); ok {
+			daily = append(daily, name)
+		}
+	}
+	return daily, nil
+}
+
+// Lock locks a daily partition in tx against writes until tx ends, while
+// still letting it be read
+func Lock(ctx context.Context, tx pgx.Tx, name string) error {
+	if _, ok := ParseName(name); !ok {
+		return fmt.Errorf("%s is not a daily partition", name)
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`LOCK TABLE %s IN SHARE MODE`, pgx.Identifier{name}.Sanitize())); err != nil {
+		return fmt.Errorf("failed to lock partition %s: %w", name, err)
+	}
+	return nil
+}
+
+// Drop removes a partition and its logs in tx, and returns the number of
+// logs and the bytes, indexes included, it held
+func Drop(ctx context.Context, tx pgx.Tx, name string) (rows, bytes int64, err error) {
+	if _, ok := ParseName(name); !ok {
+		return 0, 0, fmt.Errorf("%s is not a daily partition", name)
+	}
+	table := pgx.Identifier{name}.Sanitize()
+
+	err = tx.QueryRow(ctx, fmt.Sprintf(`SELECT (SELECT count(*) FROM %s), pg_total_relation_size($1)`, table), name).Scan(&rows, &bytes)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to measure partition %s: %w", name, err)
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`DROP TABLE %s`, table)); err != nil {
+		return 0, 0, fmt.Errorf("failed to drop partition %s: %w", name, err)
+	}
+	return rows, bytes, nil
+}
+
+// Ensure creates the missing partitions of today and the next days, and
+// returns the names of those it created
+func (m *Manager) Ensure(ctx context.Context) ([]string, error) {
+	existing, err := List(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		have[name] = true
+	}
+
+	var created []string
+	for _, day := range Days(m.now(), m.ahead) {
+		if have[Name(day)] {
+			continue
+		}
+		ok, err := m.create(ctx, day)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created = append(created, Name(day))
+		}
+	}
+	return created, nil
+}
+
+// create adds the partition of day unless another worker already has, and
+// reports whether it did. Logs of that day already in the default partition,
+// such as logs timestamped in the future, are moved into it, and the
+// partition gets the tenant isolation policy of Table.
+func (m *Manager) create(ctx context.Context, day time.Time) (bool, error) {
+	name := pgx.Identifier{Name(day)}.Sanitize()
+	from, to := day.Format(time.RFC3339), day.AddDate(0, 0, 1).Format(time.RFC3339)
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Workers take turns, so that only one creates each partition
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, Table+" partitions"); err != nil {
+		return false, fmt.Errorf("failed to lock partitions: %w", err)
+	}
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, Name(day)).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up partition %s: %w", Name(day), err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`, name, Table),
+		fmt.Sprintf(`WITH moved AS (
+			DELETE FROM %s WHERE timestamp >= '%s' AND timestamp < '%s' RETURNING *
+		) INSERT INTO %s SELECT * FROM moved`, DefaultPartition, from, to, name),
+		fmt.Sprintf(`ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`, Table, name, from, to),
+		// Tenant isolation holds for the partition queried directly too
+		fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY`, name),
+		fmt.Sprintf(`CREATE POLICY %s ON %s USING (%s)`, Policy, name, PolicyExpression),
+	}
+	for _, sql := range statements {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return false, fmt.Errorf("failed to create partition %s: %w", Name(day), err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit partition %s: %w", Name(day), err)
+	}
+	return true, nil
+}
+
+// Check reports an error when tomorrow's partition is missing, so that a
+// failing manager shows before logs pile up in the default partition
+func (m *Manager) Check(ctx context.Context) error {
+	name := Name(Day(m.now()).AddDate(0, 0, 1))
+	var exists bool
+	if err := m.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up partition %s: %w", name, err)
+	}
+	if !exists {
+		return fmt.Errorf("partition %s does not exist", name)
+	}
+	return nil
+}
+
+// Run ensures partitions now and then every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := m.Ensure(ctx)
+		for _, name := range created {
+			slog.InfoContext(ctx, "created log partition", "partition", name)
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to create log partitions", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
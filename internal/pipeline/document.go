@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// Field names address a log: "message", "level", "service", or
+// "meta.<path>" where the path is dot-separated
+const (
+	fieldMessage = "message"
+	fieldLevel   = "level"
+	fieldService = "service"
+	metaPrefix   = "meta."
+)
+
+// document is a log request being processed, with its meta decoded on first use
+type document struct {
+	req         *models.LogRequest
+	meta        map[string]interface{}
+	metaChanged bool
+}
+
+// validateField checks that field names a core field or a meta path
+func validateField(field string) error {
+	switch field {
+	case fieldMessage, fieldLevel, fieldService:
+		return nil
+	}
+	if !strings.HasPrefix(field, metaPrefix) || strings.TrimPrefix(field, metaPrefix) == "" {
+		return fmt.Errorf("invalid field %q (want message, level, service or meta.<path>)", field)
+	}
+	for _, part := range metaPath(field) {
+		if part == "" {
+			return fmt.Errorf("invalid field %q: empty path segment", field)
+		}
+	}
+	return nil
+}
+
+func isCoreField(field string) bool {
+	return field == fieldMessage || field == fieldLevel || field == fieldService
+}
+
+func metaPath(field string) []string {
+	return strings.Split(strings.TrimPrefix(field, metaPrefix), ".")
+}
+
+// loadMeta decodes the request's meta, which must be a JSON object
+func (d *document) loadMeta() error {
+	if d.meta != nil {
+		return nil
+	}
+	d.meta = map[string]interface{}{}
+	if len(d.req.Meta) == 0 || string(d.req.Meta) == "null" {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(d.req.Meta))
+	dec.UseNumber()
+	if err := dec.Decode(&d.meta); err != nil {
+		return fmt.Errorf("meta must be a JSON object")
+	}
+	return nil
+}
+
+// get returns the value of field and whether it is present
+func (d *document) get(field string) (interface{}, bool, error) {
+	switch field {
+	case fieldMessage:
+		return d.req.Message, d.req.Message != "", nil
+	case fieldLevel:
+		return d.req.Level, d.req.Level != "", nil
+	case fieldService:
+		return d.req.Service, d.req.Service != "", nil
+	}
+
+	if err := d.loadMeta(); err != nil {
+		return nil, false, err
+	}
+	var node interface{} = d.meta
+	for _, key := range metaPath(field) {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		if node, ok = m[key]; !ok {
+			return nil, false, nil
+		}
+	}
+	return node, true, nil
+}
+
+// set stores value at field, creating intermediate meta objects as needed.
+// Core fields take the value's string form.
+func (d *document) set(field string, value interface{}) error {
+	switch field {
+	case fieldMessage:
+		d.req.Message = stringValue(value)
+		return nil
+	case fieldLevel:
+		d.req.Level = stringValue(value)
+		return nil
+	case fieldService:
+		d.req.Service = stringValue(value)
+		return nil
+	}
+
+	if err := d.loadMeta(); err != nil {
+		return err
+	}
+	path := metaPath(field)
+	node := d.meta
+	for _, key := range path[:len(path)-1] {
+		child, ok := node[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			node[key] = child
+		}
+		node = child
+	}
+	node[path[len(path)-1]] = value
+	d.metaChanged = true
+	return nil
+}
+
+// remove deletes a meta field
+func (d *document) remove(field string) error {
+	if err := d.loadMeta(); err != nil {
+		return err
+	}
+	path := metaPath(field)
+	node := d.meta
+	for _, key := range path[:len(path)-1] {
+		child, ok := node[key].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	if _, ok := node[path[len(path)-1]]; ok {
+		delete(node, path[len(path)-1])
+		d.metaChanged = true
+	}
+	return nil
+}
+
+// finish writes changed meta back to the request
+func (d *document) finish() error {
+	if !d.metaChanged {
+		return nil
+	}
+	data, err := json.Marshal(d.meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode meta: %w", err)
+	}
+	d.req.Meta = data
+	return nil
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// grokPatterns are the named patterns available to grok processors. They
+// follow the Logstash definitions closely but use no capture groups.
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"NONNEGINT":         `\b[0-9]+\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"EMAILADDRESS":      `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"HTTPDATE":          `[0-9]{2}/\w{3}/[0-9]{4}:[0-9]{2}:[0-9]{2}:[0-9]{2} [+-][0-9]{4}`,
+	"TIMESTAMP_ISO8601": `[0-9]{4}-[0-9]{2}-[0-9]{2}[T ][0-9]{2}:[0-9]{2}(?::[0-9]{2}(?:[.,][0-9]+)?)?(?:Z|[+-][0-9]{2}:?[0-9]{2})?`,
+	"LOGLEVEL":          `(?i:alert|trace|debug|notice|info|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?)`,
+}
+
+// grokReference matches %{NAME}, %{NAME:field} and %{NAME:field:type}
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(int|float))?\}`)
+
+const (
+	// maxGrokDepth bounds pattern expansion, catching self-referencing patterns
+	maxGrokDepth = 16
+	// maxGrokLength bounds the expanded regular expression, catching patterns
+	// that reference others many times over
+	maxGrokLength = 64 << 10
+)
+
+// capture maps a regexp group to the field it fills
+type capture struct {
+	field     string
+	valueType string
+}
+
+type grokPattern struct {
+	re       *regexp.Regexp
+	captures map[string]capture
+}
+
+// compileGrok expands the references in pattern into a regular expression.
+// Captured names become meta fields unless they name a field explicitly.
+func compileGrok(pattern string, custom map[string]string) (*grokPattern, error) {
+	g := &grokPattern{captures: make(map[string]capture)}
+	expanded, err := g.expand(pattern, custom, 0)
+	if err != nil {
+		return nil, err
+	}
+	return g.compile(expanded)
+}
+
+// compileRegex compiles a regular expression whose named groups
+// (?P<name>...) become fields the same way grok captures do
+func compileRegex(pattern string) (*grokPattern, error) {
+	g := &grokPattern{captures: make(map[string]capture)}
+	return g.compile(pattern)
+}
+
+func (g *grokPattern) compile(expr string) (*grokPattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	for _, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if _, ok := g.captures[name]; ok {
+			continue
+		}
+		field := captureField(name)
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
+		g.captures[name] = capture{field: field}
+	}
+	if len(g.captures) == 0 {
+		return nil, fmt.Errorf("pattern captures no fields")
+	}
+	g.re = re
+	return g, nil
+}
+
+// captureField maps a capture name to a field: core field names are used
+// as-is and anything else goes into meta
+func captureField(name string) string {
+	if isCoreField(name) || strings.HasPrefix(name, metaPrefix) {
+		return name
+	}
+	return metaPrefix + name
+}
+
+func (g *grokPattern) expand(pattern string, custom map[string]string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", fmt.Errorf("grok patterns nest too deeply")
+	}
+
+	var expandErr error
+	length := len(pattern)
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(ref string) string {
+		if expandErr != nil {
+			return ""
+		}
+		m := grokReference.FindStringSubmatch(ref)
+		name, field, valueType := m[1], m[2], m[3]
+
+		definition, ok := custom[name]
+		if !ok {
+			definition, ok = grokPatterns[name]
+		}
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %q", name)
+			return ""
+		}
+		inner, err := g.expand(definition, custom, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+		if length += len(inner); length > maxGrokLength {
+			expandErr = fmt.Errorf("grok pattern expands to more than %d bytes", maxGrokLength)
+			return ""
+		}
+
+		if field == "" {
+			return "(?:" + inner + ")"
+		}
+		field = captureField(field)
+		if err := validateField(field); err != nil {
+			expandErr = err
+			return ""
+		}
+		group := "grok__" + strconv.Itoa(len(g.captures))
+		g.captures[group] = capture{field: field, valueType: valueType}
+		return "(?P<" + group + ">" + inner + ")"
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+// match returns the captured values of s by field, or nil when s does not match
+func (g *grokPattern) match(s string) map[string]interface{} {
+	m := g.re.FindStringSubmatchIndex(s)
+	if m == nil {
+		return nil
+	}
+
+	values := make(map[string]interface{})
+	for i, name := range g.re.SubexpNames() {
+		c, ok := g.captures[name]
+		if !ok || m[2*i] < 0 {
+			continue
+		}
+		values[c.field] = convert(s[m[2*i]:m[2*i+1]], c.valueType)
+	}
+	return values
+}
+
+// convert parses a captured value as valueType, keeping the string when it does not parse
+func convert(value, valueType string) interface{} {
+	switch valueType {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
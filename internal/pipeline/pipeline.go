@@ -0,0 +1,364 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/models"
+)
+
+// Config is one pipeline: an ordered list of processors applied to the
+// logs of matching services
+type Config struct {
+	Name string `yaml:"name" json:"name"`
+	// Services are globs such as "nginx-*"; empty matches every service
+	Services   []string          `yaml:"services" json:"services,omitempty"`
+	Processors []ProcessorConfig `yaml:"processors" json:"processors"`
+}
+
+// ProcessorConfig sets exactly one processor, optionally guarded by If
+type ProcessorConfig struct {
+	If     *Condition    `yaml:"if" json:"if,omitempty"`
+	Grok   *GrokConfig   `yaml:"grok" json:"grok,omitempty"`
+	Regex  *RegexConfig  `yaml:"regex" json:"regex,omitempty"`
+	JSON   *JSONConfig   `yaml:"json" json:"json,omitempty"`
+	Rename *RenameConfig `yaml:"rename" json:"rename,omitempty"`
+	Remove *RemoveConfig `yaml:"remove" json:"remove,omitempty"`
+	Drop   *DropConfig   `yaml:"drop" json:"drop,omitempty"`
+	Enrich *EnrichConfig `yaml:"enrich" json:"enrich,omitempty"`
+}
+
+// GrokConfig extracts fields with a grok pattern such as "%{IP:client} %{WORD:method}"
+type GrokConfig struct {
+	// Field to match, default message
+	Field   string `yaml:"field" json:"field,omitempty"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+	// Patterns defines extra named patterns usable as %{NAME}
+	Patterns map[string]string `yaml:"patterns" json:"patterns,omitempty"`
+}
+
+// RegexConfig extracts the named groups of a regular expression
+type RegexConfig struct {
+	Field   string `yaml:"field" json:"field,omitempty"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+// JSONConfig parses a field holding JSON
+type JSONConfig struct {
+	// Field to parse, default message
+	Field string `yaml:"field" json:"field,omitempty"`
+	// Target receives the parsed value, default meta (objects are merged)
+	Target string `yaml:"target" json:"target,omitempty"`
+}
+
+// RenameConfig moves a meta field to another field
+type RenameConfig struct {
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+}
+
+// RemoveConfig deletes meta fields
+type RemoveConfig struct {
+	Fields []string `yaml:"fields" json:"fields"`
+}
+
+// DropConfig drops the log; it is usually combined with If
+type DropConfig struct{}
+
+// EnrichConfig sets static values by field
+type EnrichConfig struct {
+	Fields map[string]interface{} `yaml:"fields" json:"fields"`
+	// Override replaces values the log already has
+	Override bool `yaml:"override" json:"override,omitempty"`
+}
+
+// step is a compiled processor with a label for dry runs
+type step struct {
+	label string
+	// pipelineName and position ("index:type") label processor metrics
+	pipelineName string
+	position     string
+	processor
+}
+
+// stepError is a processor failure
+type stepError struct {
+	step *step
+	err  error
+}
+
+func (e *stepError) Error() string {
+	return e.step.label + ": " + e.err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+type pipeline struct {
+	name     string
+	services []string
+	steps    []step
+}
+
+// Runner applies the configured pipelines to logs at ingest
+type Runner struct {
+	pipelines atomic.Pointer[[]pipeline]
+}
+
+// Result describes what the pipelines did to one log in a dry run
+type Result struct {
+	// Log is the processed log; it is omitted when the log was dropped
+	Log *models.LogRequest `json:"log,omitempty"`
+	// Pipelines lists the pipelines that matched the log
+	Pipelines []string `json:"pipelines"`
+	Dropped   bool     `json:"dropped"`
+	// DroppedBy names the processor that dropped the log, as pipeline/index:type
+	DroppedBy string `json:"dropped_by,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// New compiles pipeline configs
+func New(configs []Config) (*Runner, error) {
+	var pipelines []pipeline
+	for i, cfg := range configs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("pipeline-%d", i)
+		}
+		if err := models.ValidateServicePatterns(cfg.Services); err != nil {
+			return nil, fmt.Errorf("pipeline %s: %w", name, err)
+		}
+
+		p := pipeline{name: name, services: cfg.Services}
+		for j, pc := range cfg.Processors {
+			s, err := compileProcessor(pc)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline %s, processor %d: %w", name, j, err)
+			}
+			s.pipelineName = name
+			s.position = fmt.Sprintf("%d:%s", j, s.label)
+			s.label = name + "/" + s.position
+			p.steps = append(p.steps, s)
+		}
+		pipelines = append(pipelines, p)
+	}
+
+	r := &Runner{}
+	r.pipelines.Store(&pipelines)
+	return r, nil
+}
+
+// Replace switches r to the pipelines of next. Logs already being
+// processed finish with the old pipelines.
+func (r *Runner) Replace(next *Runner) {
+	r.pipelines.Store(next.pipelines.Load())
+}
+
+func compileProcessor(pc ProcessorConfig) (step, error) {
+	var (
+		s   step
+		err error
+		set int
+	)
+	if pc.Grok != nil {
+		set++
+		s.label = "grok"
+		s.processor, err = newExtract(pc.Grok.Field, func() (*grokPattern, error) {
+			return compileGrok(pc.Grok.Pattern, pc.Grok.Patterns)
+		})
+	}
+	if pc.Regex != nil {
+		set++
+		s.label = "regex"
+		s.processor, err = newExtract(pc.Regex.Field, func() (*grokPattern, error) {
+			return compileRegex(pc.Regex.Pattern)
+		})
+	}
+	if pc.JSON != nil {
+		set++
+		s.label = "json"
+		s.processor, err = newParseJSON(*pc.JSON)
+	}
+	if pc.Rename != nil {
+		set++
+		s.label = "rename"
+		s.processor, err = newRename(*pc.Rename)
+	}
+	if pc.Remove != nil {
+		set++
+		s.label = "remove"
+		s.processor, err = newRemove(*pc.Remove)
+	}
+	if pc.Drop != nil {
+		set++
+		s.label = "drop"
+		s.processor = drop{}
+	}
+	if pc.Enrich != nil {
+		set++
+		s.label = "enrich"
+		s.processor, err = newEnrich(*pc.Enrich)
+	}
+
+	switch {
+	case set == 0:
+		return step{}, fmt.Errorf("no processor set")
+	case set > 1:
+		return step{}, fmt.Errorf("more than one processor set; use one list entry per processor")
+	case err != nil:
+		return step{}, fmt.Errorf("%s: %w", s.label, err)
+	}
+
+	if pc.If != nil {
+		cond, err := compileCondition(*pc.If)
+		if err != nil {
+			return step{}, fmt.Errorf("%s: if: %w", s.label, err)
+		}
+		s.processor = &conditional{cond: cond, next: s.processor}
+	}
+	return s, nil
+}
+
+func newExtract(field string, compile func() (*grokPattern, error)) (processor, error) {
+	if field == "" {
+		field = fieldMessage
+	}
+	if err := validateField(field); err != nil {
+		return nil, err
+	}
+	pattern, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	return &extract{field: field, pattern: pattern}, nil
+}
+
+func newParseJSON(cfg JSONConfig) (processor, error) {
+	p := &parseJSON{field: cfg.Field, target: cfg.Target}
+	if p.field == "" {
+		p.field = fieldMessage
+	}
+	if p.target == "" {
+		p.target = "meta"
+	}
+	if err := validateField(p.field); err != nil {
+		return nil, err
+	}
+	if p.target != "meta" {
+		if err := validateField(p.target); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func newRename(cfg RenameConfig) (processor, error) {
+	if err := validateField(cfg.From); err != nil {
+		return nil, err
+	}
+	if err := validateField(cfg.To); err != nil {
+		return nil, err
+	}
+	if isCoreField(cfg.From) {
+		return nil, fmt.Errorf("cannot rename %s; only meta fields can be moved", cfg.From)
+	}
+	return &rename{from: cfg.From, to: cfg.To}, nil
+}
+
+func newRemove(cfg RemoveConfig) (processor, error) {
+	if len(cfg.Fields) == 0 {
+		return nil, fmt.Errorf("no fields")
+	}
+	for _, field := range cfg.Fields {
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
+		if isCoreField(field) {
+			return nil, fmt.Errorf("cannot remove %s; only meta fields can be removed", field)
+		}
+	}
+	return &remove{fields: cfg.Fields}, nil
+}
+
+func newEnrich(cfg EnrichConfig) (processor, error) {
+	if len(cfg.Fields) == 0 {
+		return nil, fmt.Errorf("no fields")
+	}
+	for field := range cfg.Fields {
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
+	}
+	return &enrich{fields: cfg.Fields, override: cfg.Override}, nil
+}
+
+// Process runs the pipelines matching req's service in order. It returns
+// false when a processor dropped the log.
+func (r *Runner) Process(req *models.LogRequest) (bool, error) {
+	result, err := r.run(req)
+	if err != nil {
+		var failed *stepError
+		if errors.As(err, &failed) {
+			metrics.ProcessorErrors.WithLabelValues(failed.step.pipelineName, failed.step.position).Inc()
+		}
+		return false, err
+	}
+	return !result.Dropped, nil
+}
+
+// DryRun processes copies of reqs and reports what happened to each
+func (r *Runner) DryRun(reqs []models.LogRequest) []Result {
+	results := make([]Result, len(reqs))
+	for i, req := range reqs {
+		req.Meta = append([]byte(nil), req.Meta...)
+		result, err := r.run(&req)
+		if err != nil {
+			result.Error = err.Error()
+		} else if !result.Dropped {
+			result.Log = &req
+		}
+		results[i] = result
+	}
+	return results
+}
+
+func (r *Runner) run(req *models.LogRequest) (Result, error) {
+	result := Result{Pipelines: []string{}}
+	d := &document{req: req}
+
+	for _, p := range *r.pipelines.Load() {
+		if !p.matches(req.Service) {
+			continue
+		}
+		result.Pipelines = append(result.Pipelines, p.name)
+
+		for i := range p.steps {
+			s := &p.steps[i]
+			keep, err := s.process(d)
+			if err != nil {
+				return result, &stepError{step: s, err: err}
+			}
+			if !keep {
+				result.Dropped = true
+				result.DroppedBy = s.label
+				return result, nil
+			}
+		}
+	}
+	return result, d.finish()
+}
+
+func (p *pipeline) matches(service string) bool {
+	if len(p.services) == 0 {
+		return true
+	}
+	for _, pattern := range p.services {
+		if models.MatchGlob(pattern, service) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// processor transforms a document in place. It returns false to drop the log.
+type processor interface {
+	process(d *document) (bool, error)
+}
+
+// Condition tests one field. Exactly one of Equals, In, Matches or Exists is set.
+type Condition struct {
+	Field   string        `yaml:"field" json:"field"`
+	Equals  interface{}   `yaml:"equals" json:"equals,omitempty"`
+	In      []interface{} `yaml:"in" json:"in,omitempty"`
+	Matches string        `yaml:"matches" json:"matches,omitempty"`
+	Exists  *bool         `yaml:"exists" json:"exists,omitempty"`
+}
+
+type condition struct {
+	field   string
+	values  []string
+	matches *regexp.Regexp
+	exists  *bool
+}
+
+func compileCondition(c Condition) (*condition, error) {
+	if err := validateField(c.Field); err != nil {
+		return nil, err
+	}
+
+	cond := &condition{field: c.Field, exists: c.Exists}
+	set := 0
+	if c.Equals != nil {
+		cond.values = []string{stringValue(c.Equals)}
+		set++
+	}
+	if len(c.In) > 0 {
+		for _, v := range c.In {
+			cond.values = append(cond.values, stringValue(v))
+		}
+		set++
+	}
+	if c.Matches != "" {
+		re, err := regexp.Compile(c.Matches)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matches: %w", err)
+		}
+		cond.matches = re
+		set++
+	}
+	if c.Exists != nil {
+		set++
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("condition on %s needs exactly one of equals, in, matches or exists", c.Field)
+	}
+	return cond, nil
+}
+
+func (c *condition) eval(d *document) (bool, error) {
+	value, present, err := d.get(c.field)
+	if err != nil {
+		return false, err
+	}
+	if c.exists != nil {
+		return present == *c.exists, nil
+	}
+	if !present {
+		return false, nil
+	}
+
+	s := stringValue(value)
+	if c.matches != nil {
+		return c.matches.MatchString(s), nil
+	}
+	for _, v := range c.values {
+		if s == v {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// conditional runs a processor only when its condition holds
+type conditional struct {
+	cond *condition
+	next processor
+}
+
+func (c *conditional) process(d *document) (bool, error) {
+	ok, err := c.cond.eval(d)
+	if err != nil || !ok {
+		return true, err
+	}
+	return c.next.process(d)
+}
+
+// extract copies the captures of a grok or regex pattern into fields
+type extract struct {
+	field   string
+	pattern *grokPattern
+}
+
+func (e *extract) process(d *document) (bool, error) {
+	value, present, err := d.get(e.field)
+	if err != nil || !present {
+		return true, err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return true, nil
+	}
+	for field, captured := range e.pattern.match(s) {
+		if err := d.set(field, captured); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// parseJSON decodes a field holding JSON into target. Objects parsed into
+// "meta" are merged into it key by key.
+type parseJSON struct {
+	field  string
+	target string
+}
+
+func (p *parseJSON) process(d *document) (bool, error) {
+	value, present, err := d.get(p.field)
+	if err != nil || !present {
+		return true, err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return true, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+	var parsed interface{}
+	if err := dec.Decode(&parsed); err != nil || dec.More() {
+		// Not JSON: leave the log as it is
+		return true, nil
+	}
+
+	if p.target == "meta" {
+		obj, ok := parsed.(map[string]interface{})
+		if !ok {
+			return true, nil
+		}
+		for key, v := range obj {
+			if err := d.set(metaPrefix+key, v); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	}
+	return true, d.set(p.target, parsed)
+}
+
+type rename struct {
+	from string
+	to   string
+}
+
+func (r *rename) process(d *document) (bool, error) {
+	value, present, err := d.get(r.from)
+	if err != nil || !present {
+		return true, err
+	}
+	if err := d.set(r.to, value); err != nil {
+		return true, err
+	}
+	return true, d.remove(r.from)
+}
+
+type remove struct {
+	fields []string
+}
+
+func (r *remove) process(d *document) (bool, error) {
+	for _, field := range r.fields {
+		if err := d.remove(field); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+type drop struct{}
+
+func (drop) process(*document) (bool, error) {
+	return false, nil
+}
+
+// enrich sets static values, leaving fields that already have one unless override is set
+type enrich struct {
+	fields   map[string]interface{}
+	override bool
+}
+
+func (e *enrich) process(d *document) (bool, error) {
+	for field, value := range e.fields {
+		if !e.override {
+			_, present, err := d.get(field)
+			if err != nil {
+				return true, err
+			}
+			if present {
+				continue
+			}
+		}
+		if err := d.set(field, value); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// InternalService is the service name of logs LogScale writes about itself
+const InternalService = "logscale"
+
+// quotaEvent builds the internal log announcing that a quota threshold was crossed
+func quotaEvent(q models.Quota, threshold, day string, lines, bytes int64) models.LogRequest {
+	scope := "tenant"
+	if q.Service != "" {
+		scope = "service " + q.Service
+	}
+
+	level := models.LevelWarn
+	var message string
+	if threshold == "hard" {
+		level = models.LevelError
+		message = fmt.Sprintf("Daily quota for %s reached, further logs will be %s", scope, actionVerb(q.HardAction))
+	} else {
+		message = fmt.Sprintf("Daily quota warning for %s", scope)
+	}
+
+	meta, _ := json.Marshal(map[string]interface{}{
+		"event":       "quota_threshold_crossed",
+		"quota_id":    q.ID,
+		"threshold":   threshold,
+		"service":     q.Service,
+		"day":         day,
+		"lines":       lines,
+		"bytes":       bytes,
+		"soft_lines":  q.SoftLines,
+		"soft_bytes":  q.SoftBytes,
+		"hard_lines":  q.HardLines,
+		"hard_bytes":  q.HardBytes,
+		"hard_action": q.HardAction,
+	})
+
+	return models.LogRequest{
+		Service: InternalService,
+		Level:   level,
+		Message: fmt.Sprintf("%s (%d lines, %d bytes today)", message, lines, bytes),
+		Meta:    meta,
+	}
+}
+
+func actionVerb(action string) string {
+	if action == models.QuotaActionSample {
+		return "sampled"
+	}
+	return "rejected"
+}
@@ -0,0 +1,360 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/models"
+)
+
+// refreshInterval is how long quotas are cached before being reloaded
+const refreshInterval = 30 * time.Second
+
+var (
+	// ErrQuotaExceeded is returned when a hard quota with the reject action is reached
+	ErrQuotaExceeded = errors.New("daily quota exceeded")
+	// ErrInvalidQuota is returned when setting a quota whose soft limit is above its hard limit
+	ErrInvalidQuota = errors.New("invalid quota")
+)
+
+// ExceededError describes the quota that rejected a request
+type ExceededError struct {
+	Quota models.Quota
+	// RetryAfter is the time until the quota resets at midnight UTC
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	if e.Quota.Service != "" {
+		return fmt.Sprintf("%v for service %s", ErrQuotaExceeded, e.Quota.Service)
+	}
+	return ErrQuotaExceeded.Error()
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) match
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Store persists quotas and usage; *helpers.QuotaHelper satisfies it
+type Store interface {
+	UpsertQuota(ctx context.Context, q models.Quota) (*models.Quota, error)
+	ListQuotas(ctx context.Context) ([]models.Quota, error)
+	DeleteQuota(ctx context.Context, tenantID string, id int64) error
+	GetDailyUsage(ctx context.Context, tenantID, service, day string) (lines, bytes int64, err error)
+	// ReserveUsage atomically adds every reservation to the usage of its
+	// quota on day, unless one would take its quota past a hard limit. Then
+	// nothing is added and that quota is returned.
+	ReserveUsage(ctx context.Context, tenantID, day string, reservations []models.QuotaReservation) (*models.Quota, error)
+	AddUsage(ctx context.Context, tenantID, service, day string, lines, bytes, dropped int64) error
+	QueryUsage(ctx context.Context, tenantID, service, from, to string) ([]models.Usage, error)
+}
+
+// Service tracks daily ingestion volume and enforces quotas. Quota lookups
+// fail open: if usage cannot be read, logs are accepted.
+type Service struct {
+	store Store
+	now   func() time.Time
+	keep  func() float64
+
+	mu       sync.Mutex
+	quotas   map[string][]models.Quota
+	loadedAt time.Time
+}
+
+// NewService creates a new quota service
+func NewService(store Store) *Service {
+	return &Service{
+		store: store,
+		now:   time.Now,
+		keep:  rand.Float64,
+	}
+}
+
+// Apply enforces hard quotas on logs about to be stored for a tenant. It
+// returns the logs to store, which may be fewer than reqs when a quota
+// samples, or an *ExceededError when a quota rejects. The logs returned
+// already count against rejecting quotas, even if storing them then fails.
+func (s *Service) Apply(ctx context.Context, tenantID string, reqs []models.LogRequest) ([]models.LogRequest, error) {
+	quotas := s.forTenant(ctx, tenantID)
+	if len(quotas) == 0 {
+		return reqs, nil
+	}
+
+	now := s.now().UTC()
+	day := now.Format(time.DateOnly)
+	services := countByService(reqs)
+
+	// Share of logs to keep, per service ("" applies to every service), for
+	// sampling quotas already over their hard limit
+	keepRates := make(map[string]float64)
+	for _, q := range quotas {
+		if q.HardAction != models.QuotaActionSample || (q.HardLines == 0 && q.HardBytes == 0) {
+			continue
+		}
+		if _, ok := services[q.Service]; q.Service != "" && !ok {
+			continue
+		}
+
+		lines, bytes, err := s.store.GetDailyUsage(ctx, tenantID, q.Service, day)
+		if err != nil {
+			slog.WarnContext(ctx, "quota check skipped", logging.KeyTenant, tenantID, logging.Err(err))
+			continue
+		}
+		if !exceeds(lines, bytes, q.HardLines, q.HardBytes) {
+			continue
+		}
+		if rate, ok := keepRates[q.Service]; !ok || q.SampleRate < rate {
+			keepRates[q.Service] = q.SampleRate
+		}
+	}
+	kept, dropped := s.sample(reqs, keepRates)
+
+	// Rejecting quotas are checked and counted in one step, so that
+	// concurrent requests cannot overshoot them together
+	var reservations []models.QuotaReservation
+	for _, q := range quotas {
+		if q.HardAction != models.QuotaActionReject || (q.HardLines == 0 && q.HardBytes == 0) {
+			continue
+		}
+		if lines, bytes := volume(kept, q.Service); lines > 0 {
+			reservations = append(reservations, models.QuotaReservation{Quota: q, Lines: lines, Bytes: bytes})
+		}
+	}
+	if len(reservations) > 0 {
+		over, err := s.store.ReserveUsage(ctx, tenantID, day, reservations)
+		if err != nil {
+			slog.WarnContext(ctx, "quota check skipped", logging.KeyTenant, tenantID, logging.Err(err))
+		} else if over != nil {
+			s.addDropped(ctx, tenantID, day, services)
+			return nil, &ExceededError{Quota: *over, RetryAfter: untilMidnight(now)}
+		}
+	}
+
+	s.addDropped(ctx, tenantID, day, dropped)
+	return kept, nil
+}
+
+// sample keeps the share of reqs their service's keep rate allows, and
+// returns the kept logs and the number dropped per service
+func (s *Service) sample(reqs []models.LogRequest, keepRates map[string]float64) ([]models.LogRequest, map[string]int64) {
+	if len(keepRates) == 0 {
+		return reqs, nil
+	}
+
+	kept := make([]models.LogRequest, 0, len(reqs))
+	dropped := make(map[string]int64)
+	for _, req := range reqs {
+		rate := 1.0
+		if r, ok := keepRates[""]; ok {
+			rate = r
+		}
+		if r, ok := keepRates[req.Service]; ok {
+			rate = min(rate, r)
+		}
+
+		if rate >= 1 || s.keep() < rate {
+			kept = append(kept, req)
+		} else {
+			dropped[req.Service]++
+		}
+	}
+	return kept, dropped
+}
+
+// Record adds stored logs to the tenant's daily usage and returns internal
+// logs announcing every soft or hard threshold the logs crossed
+func (s *Service) Record(ctx context.Context, tenantID string, stored []models.LogRequest) []models.LogRequest {
+	if len(stored) == 0 {
+		return nil
+	}
+
+	day := s.now().UTC().Format(time.DateOnly)
+	added := make(map[string][2]int64)
+	for _, req := range stored {
+		a := added[req.Service]
+		added[req.Service] = [2]int64{a[0] + 1, a[1] + Size(req)}
+	}
+
+	var total [2]int64
+	for service, a := range added {
+		if err := s.store.AddUsage(ctx, tenantID, service, day, a[0], a[1], 0); err != nil {
+			slog.WarnContext(ctx, "failed to record usage", logging.KeyTenant, tenantID, logging.KeyService, service, logging.Err(err))
+		}
+		total[0] += a[0]
+		total[1] += a[1]
+	}
+
+	var events []models.LogRequest
+	for _, q := range s.forTenant(ctx, tenantID) {
+		delta := total
+		if q.Service != "" {
+			var ok bool
+			if delta, ok = added[q.Service]; !ok {
+				continue
+			}
+		}
+
+		lines, bytes, err := s.store.GetDailyUsage(ctx, tenantID, q.Service, day)
+		if err != nil {
+			slog.WarnContext(ctx, "quota check skipped", logging.KeyTenant, tenantID, logging.Err(err))
+			continue
+		}
+		beforeLines, beforeBytes := lines-delta[0], bytes-delta[1]
+
+		threshold := ""
+		switch {
+		case crossed(beforeLines, lines, q.HardLines) || crossed(beforeBytes, bytes, q.HardBytes):
+			threshold = "hard"
+		case crossed(beforeLines, lines, q.SoftLines) || crossed(beforeBytes, bytes, q.SoftBytes):
+			threshold = "soft"
+		default:
+			continue
+		}
+
+		event := quotaEvent(q, threshold, day, lines, bytes)
+		slog.WarnContext(ctx, event.Message, logging.KeyTenant, tenantID, logging.KeyService, q.Service, "threshold", threshold)
+		events = append(events, event)
+	}
+	return events
+}
+
+// Usage returns a tenant's daily usage between two days (YYYY-MM-DD, inclusive)
+func (s *Service) Usage(ctx context.Context, tenantID, service, from, to string) ([]models.Usage, error) {
+	return s.store.QueryUsage(ctx, tenantID, service, from, to)
+}
+
+// TenantQuotas returns the quotas that apply to a tenant
+func (s *Service) TenantQuotas(ctx context.Context, tenantID string) []models.Quota {
+	quotas := s.forTenant(ctx, tenantID)
+	if quotas == nil {
+		return []models.Quota{}
+	}
+	return quotas
+}
+
+// SetQuota creates or replaces the quota for a tenant and service
+func (s *Service) SetQuota(ctx context.Context, q models.Quota) (*models.Quota, error) {
+	if q.HardLines > 0 && q.SoftLines > q.HardLines {
+		return nil, fmt.Errorf("%w: soft_lines must not exceed hard_lines", ErrInvalidQuota)
+	}
+	if q.HardBytes > 0 && q.SoftBytes > q.HardBytes {
+		return nil, fmt.Errorf("%w: soft_bytes must not exceed hard_bytes", ErrInvalidQuota)
+	}
+	saved, err := s.store.UpsertQuota(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return saved, nil
+}
+
+// ListQuotas returns the quotas of tenantID, or of every tenant when it is
+// empty
+func (s *Service) ListQuotas(ctx context.Context, tenantID string) ([]models.Quota, error) {
+	all, err := s.store.ListQuotas(ctx)
+	if err != nil || tenantID == "" {
+		return all, err
+	}
+	quotas := []models.Quota{}
+	for _, q := range all {
+		if q.TenantID == tenantID {
+			quotas = append(quotas, q)
+		}
+	}
+	return quotas, nil
+}
+
+// DeleteQuota removes quota id of tenantID, of any tenant when it is empty
+func (s *Service) DeleteQuota(ctx context.Context, tenantID string, id int64) error {
+	if err := s.store.DeleteQuota(ctx, tenantID, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// forTenant returns the cached quotas of a tenant, reloading them when stale
+func (s *Service) forTenant(ctx context.Context, tenantID string) []models.Quota {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.quotas == nil || s.now().Sub(s.loadedAt) > refreshInterval {
+		all, err := s.store.ListQuotas(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to load quotas", logging.Err(err))
+			return s.quotas[tenantID]
+		}
+
+		s.quotas = make(map[string][]models.Quota)
+		for _, q := range all {
+			s.quotas[q.TenantID] = append(s.quotas[q.TenantID], q)
+		}
+		s.loadedAt = s.now()
+	}
+	return s.quotas[tenantID]
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.quotas = nil
+	s.mu.Unlock()
+}
+
+// addDropped counts logs that were not stored because of a quota
+func (s *Service) addDropped(ctx context.Context, tenantID, day string, dropped map[string]int64) {
+	for service, n := range dropped {
+		if n == 0 {
+			continue
+		}
+		if err := s.store.AddUsage(ctx, tenantID, service, day, 0, 0, n); err != nil {
+			slog.WarnContext(ctx, "failed to record dropped logs", logging.KeyTenant, tenantID, logging.KeyService, service, logging.Err(err))
+		}
+	}
+}
+
+// Size is the number of bytes a log counts for against quotas
+func Size(req models.LogRequest) int64 {
+	return int64(len(req.Service) + len(req.Level) + len(req.Message) + len(req.Meta))
+}
+
+// volume returns the lines and bytes of the logs of service, or of every
+// log when service is empty
+func volume(reqs []models.LogRequest, service string) (lines, bytes int64) {
+	for _, req := range reqs {
+		if service == "" || req.Service == service {
+			lines++
+			bytes += Size(req)
+		}
+	}
+	return lines, bytes
+}
+
+func countByService(reqs []models.LogRequest) map[string]int64 {
+	services := make(map[string]int64)
+	for _, req := range reqs {
+		services[req.Service]++
+	}
+	return services
+}
+
+// exceeds reports whether usage reached either limit (0 means no limit)
+func exceeds(lines, bytes, maxLines, maxBytes int64) bool {
+	return (maxLines > 0 && lines >= maxLines) || (maxBytes > 0 && bytes >= maxBytes)
+}
+
+// crossed reports whether a value went from below limit to at or above it
+func crossed(before, after, limit int64) bool {
+	return limit > 0 && before < limit && after >= limit
+}
+
+func untilMidnight(now time.Time) time.Duration {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Sub(now)
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst.
+// One token is one log.
+type Limit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Enabled reports whether the limit is configured
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Take asks for Cost tokens from the bucket stored under Key
+type Take struct {
+	Key   string
+	Limit Limit
+	Cost  int
+}
+
+// Decision is the outcome of a rate limit check
+type Decision struct {
+	Allowed bool
+	// RetryAfter is how long to wait before the same request can succeed
+	RetryAfter time.Duration
+}
+
+// Backend stores token buckets. TakeAll must be atomic: either every
+// bucket has enough tokens and all are charged, or none is.
+//
+// A bucket only needs min(Cost, Burst) tokens to admit a request and may
+// go into debt for the rest, so batches larger than the burst are still
+// accepted and simply delay the requests that follow.
+type Backend interface {
+	TakeAll(ctx context.Context, takes []Take) (Decision, error)
+}
+
+// refill returns the tokens in a bucket after elapsed time
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * limit.Rate
+	}
+	return min(tokens, float64(limit.Burst))
+}
+
+// needed returns the tokens a bucket must hold to admit cost
+func needed(cost int, limit Limit) float64 {
+	return float64(min(cost, limit.Burst))
+}
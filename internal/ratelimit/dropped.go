@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yunjin08/logscale/models"
+)
+
+// droppedKey is the Redis hash of dropped log counts, keyed by tenant:service
+const droppedKey = "logscale:ratelimit:dropped"
+
+// droppedCounter counts rate-limited logs per tenant and service, in Redis
+// when available and in memory otherwise
+type droppedCounter struct {
+	client *redis.Client
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newDroppedCounter(client *redis.Client) *droppedCounter {
+	return &droppedCounter{
+		client: client,
+		counts: make(map[string]int64),
+	}
+}
+
+// add records n dropped logs. Tenant ids cannot contain ':', so the first
+// ':' separates tenant from service.
+func (d *droppedCounter) add(ctx context.Context, tenantID, service string, n int) {
+	field := tenantID + ":" + service
+	if d.client != nil {
+		if err := d.client.HIncrBy(ctx, droppedKey, field, int64(n)).Err(); err == nil {
+			return
+		}
+	}
+
+	d.mu.Lock()
+	d.counts[field] += int64(n)
+	d.mu.Unlock()
+}
+
+// list returns the dropped counts of tenantID, or of every tenant when it
+// is empty, from Redis and this process, sorted by tenant and service
+func (d *droppedCounter) list(ctx context.Context, tenantID string) ([]models.DroppedLogs, error) {
+	totals := make(map[string]int64)
+
+	if d.client != nil {
+		values, err := d.client.HGetAll(ctx, droppedKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dropped log counts: %w", err)
+		}
+		for field, value := range values {
+			var n int64
+			if _, err := fmt.Sscan(value, &n); err == nil {
+				totals[field] += n
+			}
+		}
+	}
+
+	d.mu.Lock()
+	for field, n := range d.counts {
+		totals[field] += n
+	}
+	d.mu.Unlock()
+
+	dropped := make([]models.DroppedLogs, 0, len(totals))
+	for field, n := range totals {
+		tenant, service, _ := strings.Cut(field, ":")
+		if tenantID != "" && tenant != tenantID {
+			continue
+		}
+		dropped = append(dropped, models.DroppedLogs{TenantID: tenant, Service: service, Count: n})
+	}
+	sort.Slice(dropped, func(i, j int) bool {
+		if dropped[i].TenantID != dropped[j].TenantID {
+			return dropped[i].TenantID < dropped[j].TenantID
+		}
+		return dropped[i].Service < dropped[j].Service
+	})
+	return dropped, nil
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets triggers a sweep of full buckets, which carry no state
+const maxIdleBuckets = 10000
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// MemoryBackend keeps buckets in process. Limits are per replica, so it is
+// used when Redis is unavailable.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryBackend creates an empty in-process backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// TakeAll charges every bucket if all of them have enough tokens
+func (m *MemoryBackend) TakeAll(_ context.Context, takes []Take) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if len(m.buckets) > maxIdleBuckets {
+		m.sweep(now)
+	}
+
+	var wait time.Duration
+	current := make([]float64, len(takes))
+	for i, t := range takes {
+		tokens := float64(t.Limit.Burst)
+		if b, ok := m.buckets[t.Key]; ok {
+			tokens = refill(b.tokens, now.Sub(b.last), t.Limit)
+		}
+		current[i] = tokens
+
+		if need := needed(t.Cost, t.Limit); tokens < need {
+			wait = max(wait, time.Duration((need-tokens)/t.Limit.Rate*float64(time.Second)))
+		}
+	}
+
+	allowed := wait == 0
+	for i, t := range takes {
+		tokens := current[i]
+		if allowed {
+			tokens -= float64(t.Cost)
+		}
+		m.buckets[t.Key] = &bucket{tokens: tokens, last: now, limit: t.Limit}
+	}
+
+	return Decision{Allowed: allowed, RetryAfter: wait}, nil
+}
+
+// sweep drops buckets that have refilled completely
+func (m *MemoryBackend) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if refill(b.tokens, now.Sub(b.last), b.limit) >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/models"
+)
+
+// fallbackLogInterval limits how often a failing Redis backend is reported
+const fallbackLogInterval = time.Minute
+
+// Config sets the ingestion limits. A zero Limit disables that check.
+type Config struct {
+	// PerKey limits the logs one API key can send
+	PerKey Limit `yaml:"per_key"`
+	// PerService limits the logs one service of a tenant can send
+	PerService Limit `yaml:"per_service"`
+}
+
+// Limiter applies ingestion rate limits. It uses Redis when available so
+// limits hold across API replicas, and falls back to in-process buckets
+// when Redis is not configured or fails.
+type Limiter struct {
+	config   atomic.Pointer[Config]
+	primary  Backend
+	fallback *MemoryBackend
+	dropped  *droppedCounter
+
+	lastFallbackLog atomic.Int64
+}
+
+// New creates a limiter. redis may be nil to only limit in process.
+func New(config Config, redis *RedisBackend) *Limiter {
+	l := &Limiter{fallback: NewMemoryBackend()}
+	l.config.Store(&config)
+	if redis != nil {
+		l.primary = redis
+		l.dropped = newDroppedCounter(redis.Client())
+	} else {
+		l.dropped = newDroppedCounter(nil)
+	}
+	return l
+}
+
+// Enabled reports whether any limit is configured
+func (l *Limiter) Enabled() bool {
+	config := l.config.Load()
+	return config.PerKey.Enabled() || config.PerService.Enabled()
+}
+
+// SetConfig replaces the limits. Buckets keep their tokens.
+func (l *Limiter) SetConfig(config Config) {
+	l.config.Store(&config)
+}
+
+// AllowLogs checks a request of logs against the API key and per-service
+// limits. Rejected logs are counted as dropped for their service.
+func (l *Limiter) AllowLogs(ctx context.Context, keyID, tenantID string, reqs []models.LogRequest) Decision {
+	perService := make(map[string]int)
+	var services []string
+	for _, req := range reqs {
+		if perService[req.Service] == 0 {
+			services = append(services, req.Service)
+		}
+		perService[req.Service]++
+	}
+
+	config := l.config.Load()
+	var takes []Take
+	if config.PerKey.Enabled() {
+		takes = append(takes, Take{Key: "key:" + keyID, Limit: config.PerKey, Cost: len(reqs)})
+	}
+	if config.PerService.Enabled() {
+		for _, service := range services {
+			takes = append(takes, Take{
+				Key:   "service:" + tenantID + ":" + service,
+				Limit: config.PerService,
+				Cost:  perService[service],
+			})
+		}
+	}
+	if len(takes) == 0 {
+		return Decision{Allowed: true}
+	}
+
+	decision := l.take(ctx, takes)
+	if !decision.Allowed {
+		for _, service := range services {
+			l.dropped.add(ctx, tenantID, service, perService[service])
+		}
+	}
+	return decision
+}
+
+// Dropped returns the number of rate-limited logs per service of tenantID,
+// or per tenant and service when tenantID is empty
+func (l *Limiter) Dropped(ctx context.Context, tenantID string) ([]models.DroppedLogs, error) {
+	return l.dropped.list(ctx, tenantID)
+}
+
+// take uses the primary backend, falling back to memory on errors.
+// Limiting never fails a request on its own.
+func (l *Limiter) take(ctx context.Context, takes []Take) Decision {
+	if l.primary != nil {
+		decision, err := l.primary.TakeAll(ctx, takes)
+		if err == nil {
+			return decision
+		}
+
+		now := time.Now().UnixNano()
+		if last := l.lastFallbackLog.Load(); now-last > int64(fallbackLogInterval) && l.lastFallbackLog.CompareAndSwap(last, now) {
+			slog.WarnContext(ctx, "rate limiting in process only", logging.Err(err))
+		}
+	}
+
+	decision, _ := l.fallback.TakeAll(ctx, takes)
+	return decision
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript charges several token buckets atomically. KEYS are the bucket
+// keys; ARGV holds a (rate, burst, cost) triple per key. Buckets are hashes
+// of {tokens, ts} and expire once they would have refilled completely.
+// It returns the milliseconds to wait, or 0 when the request is allowed.
+var takeScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local tokens = {}
+local wait = 0
+
+for i, key in ipairs(KEYS) do
+  local rate = tonumber(ARGV[i * 3 - 2])
+  local burst = tonumber(ARGV[i * 3 - 1])
+  local cost = tonumber(ARGV[i * 3])
+  local state = redis.call('HMGET', key, 'tokens', 'ts')
+  local current = tonumber(state[1]) or burst
+  local ts = tonumber(state[2]) or now
+  current = math.min(burst, current + math.max(0, now - ts) * rate)
+  tokens[i] = current
+
+  local need = math.min(cost, burst)
+  if current < need then
+    wait = math.max(wait, (need - current) / rate)
+  end
+end
+
+for i, key in ipairs(KEYS) do
+  local rate = tonumber(ARGV[i * 3 - 2])
+  local burst = tonumber(ARGV[i * 3 - 1])
+  local current = tokens[i]
+  if wait == 0 then
+    current = current - tonumber(ARGV[i * 3])
+  end
+  redis.call('HSET', key, 'tokens', tostring(current), 'ts', tostring(now))
+  redis.call('PEXPIRE', key, math.ceil((burst - current) / rate * 1000) + 1000)
+end
+
+return math.ceil(wait * 1000)
+`)
+
+// RedisBackend keeps buckets in Redis so limits are shared by every API replica
+type RedisBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisBackend connects to Redis at redisURL
+func NewRedisBackend(opts *redis.Options) (*RedisBackend, error) {
+	client := redis.NewClient(opts)
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &RedisBackend{client: client, prefix: "logscale:ratelimit:"}, nil
+}
+
+// TakeAll charges every bucket if all of them have enough tokens
+func (r *RedisBackend) TakeAll(ctx context.Context, takes []Take) (Decision, error) {
+	keys := make([]string, len(takes))
+	args := make([]interface{}, 0, len(takes)*3)
+	for i, t := range takes {
+		keys[i] = r.prefix + t.Key
+		args = append(args,
+			strconv.FormatFloat(t.Limit.Rate, 'f', -1, 64),
+			t.Limit.Burst,
+			t.Cost,
+		)
+	}
+
+	waitMs, err := takeScript.Run(ctx, r.client, keys, args...).Int64()
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+
+	return Decision{Allowed: waitMs == 0, RetryAfter: time.Duration(waitMs) * time.Millisecond}, nil
+}
+
+// Client returns the underlying Redis client
+func (r *RedisBackend) Client() *redis.Client {
+	return r.client
+}
+
+// Close closes the Redis connection
+func (r *RedisBackend) Close() error {
+	return r.client.Close()
+}
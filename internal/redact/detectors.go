@@ -0,0 +1,118 @@
+package redact
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Built-in detector names usable in rules
+const (
+	DetectorEmail        = "email"
+	DetectorIPv4         = "ipv4"
+	DetectorIPv6         = "ipv6"
+	DetectorCreditCard   = "credit_card"
+	DetectorJWT          = "jwt"
+	DetectorBearerToken  = "bearer_token"
+	DetectorAWSAccessKey = "aws_access_key"
+	DetectorAWSSecretKey = "aws_secret_key"
+)
+
+// detector finds sensitive values in a string. When the pattern has a
+// capture group only the first group is redacted, so context such as
+// "Bearer " survives. valid, when set, filters out false positives.
+type detector struct {
+	pattern *regexp.Regexp
+	valid   func(string) bool
+}
+
+var builtinDetectors = map[string]detector{
+	DetectorEmail: {
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	DetectorIPv4: {
+		pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\b`),
+	},
+	DetectorIPv6: {
+		// Candidates are whole words joined by colons, so that the letters
+		// around "::" in code such as "std::vector" keep it from parsing; the
+		// parser then rejects timestamps and other lookalikes
+		pattern: regexp.MustCompile(`(?i)[0-9a-z_]*(?::[0-9a-z_]*){2,7}(?:\.[0-9]{1,3}){0,3}`),
+		valid:   validIPv6,
+	},
+	DetectorCreditCard: {
+		pattern: regexp.MustCompile(`\b[0-9](?:[ -]?[0-9]){12,18}\b`),
+		valid:   validCardNumber,
+	},
+	DetectorJWT: {
+		pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+	DetectorBearerToken: {
+		pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`),
+	},
+	DetectorAWSAccessKey: {
+		pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`),
+	},
+	DetectorAWSSecretKey: {
+		// Secret keys are indistinguishable from other base64, so they are
+		// only detected next to a name that says what they are
+		pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`),
+	},
+}
+
+// DetectorNames returns the names of the built-in detectors
+func DetectorNames() []string {
+	return []string{
+		DetectorEmail, DetectorIPv4, DetectorIPv6, DetectorCreditCard,
+		DetectorJWT, DetectorBearerToken, DetectorAWSAccessKey, DetectorAWSSecretKey,
+	}
+}
+
+// validIPv6 accepts addresses with at least two non-empty groups, so that a
+// bare "::" or "::1" in text is left alone
+func validIPv6(s string) bool {
+	groups := 0
+	for _, g := range strings.Split(s, ":") {
+		if g != "" {
+			groups++
+		}
+	}
+	if groups < 2 {
+		return false
+	}
+	addr, err := netip.ParseAddr(s)
+	return err == nil && addr.Is6()
+}
+
+// validCardNumber checks the length and Luhn checksum of a card number
+// that may contain spaces or dashes
+func validCardNumber(s string) bool {
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i]-'0')
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	return luhn(digits)
+}
+
+// luhn reports whether digits pass the Luhn checksum
+func luhn(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,356 @@
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// Actions decide what replaces a detected value
+const (
+	// ActionMask replaces the value with [REDACTED:<rule>]
+	ActionMask = "mask"
+	// ActionHash replaces the value with a keyed hash, so equal values stay correlatable
+	ActionHash = "hash"
+	// ActionDrop removes the value: the matched text from the message, the whole field from meta
+	ActionDrop = "drop"
+)
+
+// hashLength is the number of hex characters kept from a value's hash
+const hashLength = 16
+
+// Config configures the redaction stage
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// HashKey keys the hashes of the hash action
+	HashKey string `yaml:"hash_key"`
+	// MetaPaths are dot-separated meta paths to scan, where "*" matches any
+	// key. Objects and arrays found at a path are scanned entirely.
+	MetaPaths []string `yaml:"meta_paths"`
+	// Rules run in order; without rules every built-in detector masks
+	Rules []RuleConfig `yaml:"rules"`
+}
+
+// RuleConfig is either a built-in detector or a custom pattern
+type RuleConfig struct {
+	// Name labels the redactions; it defaults to the detector name
+	Name     string `yaml:"name"`
+	Detector string `yaml:"detector"`
+	// Pattern is a regular expression; with a capture group only the
+	// first group is redacted
+	Pattern string `yaml:"pattern"`
+	Action  string `yaml:"action"`
+}
+
+type rule struct {
+	name   string
+	action string
+	detector
+}
+
+type countKey struct {
+	tenantID string
+	service  string
+	rule     string
+}
+
+// Redactor removes sensitive values from logs before they are stored
+type Redactor struct {
+	rules atomic.Pointer[ruleset]
+
+	mu     sync.Mutex
+	counts map[countKey]int64
+}
+
+// ruleset is a compiled Config
+type ruleset struct {
+	enabled   bool
+	rules     []rule
+	metaPaths [][]string
+	hashKey   []byte
+}
+
+// New builds a redactor from cfg. A disabled redactor leaves logs unchanged
+// until Replace enables it.
+func New(cfg Config) (*Redactor, error) {
+	rs, err := compile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &Redactor{counts: make(map[countKey]int64)}
+	r.rules.Store(rs)
+	return r, nil
+}
+
+// Replace switches r to the rules of next, keeping r's counts
+func (r *Redactor) Replace(next *Redactor) {
+	r.rules.Store(next.rules.Load())
+}
+
+// Enabled reports whether r redacts logs
+func (r *Redactor) Enabled() bool {
+	return r.rules.Load().enabled
+}
+
+func compile(cfg Config) (*ruleset, error) {
+	ruleConfigs := cfg.Rules
+	if len(ruleConfigs) == 0 {
+		for _, name := range DetectorNames() {
+			ruleConfigs = append(ruleConfigs, RuleConfig{Detector: name})
+		}
+	}
+
+	r := &ruleset{
+		enabled: cfg.Enabled,
+		hashKey: []byte(cfg.HashKey),
+	}
+	for i, rc := range ruleConfigs {
+		compiled, err := compileRule(rc)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %d: %w", i, err)
+		}
+		if r.enabled && compiled.action == ActionHash && len(r.hashKey) == 0 {
+			slog.Warn("redaction rule hashes without a hash_key; hashes of guessable values can be reversed", "rule", compiled.name)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	for _, p := range cfg.MetaPaths {
+		if p == "" {
+			return nil, fmt.Errorf("empty meta path")
+		}
+		r.metaPaths = append(r.metaPaths, strings.Split(p, "."))
+	}
+	return r, nil
+}
+
+func compileRule(rc RuleConfig) (rule, error) {
+	r := rule{name: rc.Name, action: rc.Action}
+	if r.action == "" {
+		r.action = ActionMask
+	}
+	switch r.action {
+	case ActionMask, ActionHash, ActionDrop:
+	default:
+		return rule{}, fmt.Errorf("unknown action %q (want %q, %q or %q)", rc.Action, ActionMask, ActionHash, ActionDrop)
+	}
+
+	switch {
+	case rc.Detector != "" && rc.Pattern != "":
+		return rule{}, fmt.Errorf("set either detector or pattern, not both")
+	case rc.Detector != "":
+		d, ok := builtinDetectors[rc.Detector]
+		if !ok {
+			return rule{}, fmt.Errorf("unknown detector %q", rc.Detector)
+		}
+		r.detector = d
+		if r.name == "" {
+			r.name = rc.Detector
+		}
+	case rc.Pattern != "":
+		re, err := regexp.Compile(rc.Pattern)
+		if err != nil {
+			return rule{}, fmt.Errorf("invalid pattern: %w", err)
+		}
+		if r.name == "" {
+			return rule{}, fmt.Errorf("pattern rules need a name")
+		}
+		r.detector = detector{pattern: re}
+	default:
+		return rule{}, fmt.Errorf("set a detector or a pattern")
+	}
+	return r, nil
+}
+
+// Apply redacts the message and configured meta paths of req in place
+func (r *Redactor) Apply(tenantID string, req *models.LogRequest) error {
+	rs := r.rules.Load()
+	if !rs.enabled {
+		return nil
+	}
+	hits := make(map[string]int64)
+
+	req.Message, _ = rs.redactString(req.Message, hits)
+
+	if len(rs.metaPaths) > 0 && len(req.Meta) > 0 && string(req.Meta) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(req.Meta))
+		dec.UseNumber()
+		var meta interface{}
+		if err := dec.Decode(&meta); err != nil {
+			return fmt.Errorf("meta is not valid JSON: %w", err)
+		}
+
+		changed := false
+		for _, path := range rs.metaPaths {
+			var c bool
+			meta, _, c = rs.redactPath(meta, path, hits)
+			changed = changed || c
+		}
+		if changed {
+			data, err := json.Marshal(meta)
+			if err != nil {
+				return fmt.Errorf("failed to encode meta: %w", err)
+			}
+			req.Meta = data
+		}
+	}
+
+	if len(hits) > 0 {
+		r.mu.Lock()
+		for name, n := range hits {
+			r.counts[countKey{tenantID: tenantID, service: req.Service, rule: name}] += n
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// redactPath redacts the values under path. It reports whether node must
+// be removed from its parent and whether anything changed.
+func (r *ruleset) redactPath(node interface{}, path []string, hits map[string]int64) (interface{}, bool, bool) {
+	if len(path) == 0 {
+		return r.redactValue(node, hits)
+	}
+
+	switch v := node.(type) {
+	case map[string]interface{}:
+		changed := false
+		for key, child := range v {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			redacted, drop, c := r.redactPath(child, path[1:], hits)
+			if drop {
+				delete(v, key)
+			} else if c {
+				v[key] = redacted
+			}
+			changed = changed || c
+		}
+		return v, false, changed
+	case []interface{}:
+		// Arrays are transparent, so "users.email" covers a list of users
+		return r.redactSlice(v, func(child interface{}) (interface{}, bool, bool) {
+			return r.redactPath(child, path, hits)
+		})
+	default:
+		return node, false, false
+	}
+}
+
+// redactValue redacts every string in node
+func (r *ruleset) redactValue(node interface{}, hits map[string]int64) (interface{}, bool, bool) {
+	switch v := node.(type) {
+	case string:
+		out, drop := r.redactString(v, hits)
+		return out, drop, drop || out != v
+	case map[string]interface{}:
+		return r.redactPath(v, []string{"*"}, hits)
+	case []interface{}:
+		return r.redactSlice(v, func(child interface{}) (interface{}, bool, bool) {
+			return r.redactValue(child, hits)
+		})
+	default:
+		return node, false, false
+	}
+}
+
+func (r *ruleset) redactSlice(items []interface{}, redact func(interface{}) (interface{}, bool, bool)) (interface{}, bool, bool) {
+	kept := items[:0]
+	changed := false
+	for _, item := range items {
+		redacted, drop, c := redact(item)
+		changed = changed || c
+		if !drop {
+			kept = append(kept, redacted)
+		}
+	}
+	return kept, false, changed
+}
+
+// redactString applies every rule to s in order. It reports whether a
+// drop rule matched.
+func (r *ruleset) redactString(s string, hits map[string]int64) (string, bool) {
+	dropped := false
+	for _, rl := range r.rules {
+		matches := rl.pattern.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			value := s[start:end]
+			if value == "" || (rl.valid != nil && !rl.valid(value)) {
+				continue
+			}
+
+			b.WriteString(s[last:start])
+			b.WriteString(r.replacement(rl, value))
+			last = end
+			hits[rl.name]++
+			dropped = dropped || rl.action == ActionDrop
+		}
+		if last == 0 {
+			continue
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s, dropped
+}
+
+func (r *ruleset) replacement(rl rule, value string) string {
+	switch rl.action {
+	case ActionHash:
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		return "[" + rl.name + ":" + hex.EncodeToString(mac.Sum(nil))[:hashLength] + "]"
+	case ActionDrop:
+		return ""
+	default:
+		return "[REDACTED:" + rl.name + "]"
+	}
+}
+
+// Counts returns the redactions made by this process for tenantID, or for
+// every tenant when tenantID is empty, per tenant, service and rule, sorted
+// in that order
+func (r *Redactor) Counts(tenantID string) []models.RedactionCount {
+	r.mu.Lock()
+	counts := make([]models.RedactionCount, 0, len(r.counts))
+	for k, n := range r.counts {
+		if tenantID != "" && k.tenantID != tenantID {
+			continue
+		}
+		counts = append(counts, models.RedactionCount{TenantID: k.tenantID, Service: k.service, Rule: k.rule, Count: n})
+	}
+	r.mu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.TenantID != b.TenantID {
+			return a.TenantID < b.TenantID
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Rule < b.Rule
+	})
+	return counts
+}
@@ -0,0 +1,411 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yunjin08/logscale/internal/archive"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/partition"
+	"github.com/yunjin08/logscale/models"
+)
+
+// Config sets how long logs are kept
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often the rules are enforced
+	Interval time.Duration `yaml:"interval"`
+	// BatchSize bounds the logs removed by one DELETE
+	BatchSize int `yaml:"batch_size"`
+	// DefaultTTL applies to logs no rule matches; zero keeps them
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	// Rules are tried in order; the first matching a log sets its TTL
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule sets the TTL of the logs of matching services and level
+type Rule struct {
+	// Service is a glob of '*' and '?'; empty matches every service
+	Service string `yaml:"service"`
+	// Level is a log level; empty matches every level
+	Level string `yaml:"level"`
+	// TTL is how long matching logs are kept; zero keeps them
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// Validate checks the rules and normalizes their levels
+func (c *Config) Validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("batch_size must be positive")
+	}
+	if c.DefaultTTL < 0 {
+		return fmt.Errorf("default_ttl must not be negative")
+	}
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.TTL < 0 {
+			return fmt.Errorf("rule %d: ttl must not be negative", i)
+		}
+		if rule.Level != "" {
+			level, ok := models.NormalizeLevel(rule.Level)
+			if !ok {
+				return fmt.Errorf("rule %d: unknown level %q", i, rule.Level)
+			}
+			rule.Level = level
+		}
+	}
+	return nil
+}
+
+// matches reports whether r applies to logs of service at level
+func (r Rule) matches(service, level string) bool {
+	return (r.Service == "" || models.MatchGlob(r.Service, service)) &&
+		(r.Level == "" || r.Level == level)
+}
+
+// TTL returns how long logs of service at level are kept, zero meaning
+// forever
+func (c Config) TTL(service, level string) time.Duration {
+	for _, rule := range c.Rules {
+		if rule.matches(service, level) {
+			return rule.TTL
+		}
+	}
+	return c.DefaultTTL
+}
+
+// DropBefore returns the time before which every log has expired, whatever
+// its service and level, so that partitions ending by then can be dropped
+// whole. It returns false when some logs are kept forever.
+func (c Config) DropBefore(now time.Time) (time.Time, bool) {
+	var longest time.Duration
+	for _, rule := range c.Rules {
+		if rule.TTL == 0 {
+			return time.Time{}, false
+		}
+		longest = max(longest, rule.TTL)
+		// Later rules and the default never apply
+		if (rule.Service == "" || rule.Service == "*") && rule.Level == "" {
+			return now.Add(-longest), true
+		}
+	}
+	if c.DefaultTTL == 0 {
+		return time.Time{}, false
+	}
+	return now.Add(-max(longest, c.DefaultTTL)), true
+}
+
+// Report sums what one enforcement removed
+type Report struct {
+	// DroppedPartitions are the partitions dropped whole
+	DroppedPartitions []string
+	// Rows and Bytes count the logs removed. Bytes of deleted rows are their
+	// stored size, which the database reuses after vacuuming.
+	Rows  int64
+	Bytes int64
+	// Archived counts the logs archived before they were removed
+	Archived int64
+}
+
+// lockKey is the advisory lock letting one replica at a time enforce retention
+const lockKey = 0x6c6f6772657465 // "logrete"
+
+// Methods label how logs were removed
+const (
+	MethodDropPartition = "drop_partition"
+	MethodDelete        = "delete"
+)
+
+// Enforcer removes logs past their TTL
+type Enforcer struct {
+	db       *pgxpool.Pool
+	archiver *archive.Archiver
+	config   atomic.Pointer[Config]
+	now      func() time.Time
+}
+
+// NewEnforcer creates an enforcer of config, which must be valid. With an
+// archiver, logs are archived before they are removed, and kept if that
+// fails; archiver may be nil to remove them outright.
+func NewEnforcer(db *pgxpool.Pool, config Config, archiver *archive.Archiver) *Enforcer {
+	e := &Enforcer{db: db, archiver: archiver, now: time.Now}
+	e.config.Store(&config)
+	return e
+}
+
+// Replace swaps in a new, valid config. The interval only changes on restart.
+func (e *Enforcer) Replace(config Config) {
+	e.config.Store(&config)
+}
+
+// Enforce drops the partitions whose logs have all expired, then deletes
+// the expired logs left in batches. It returns what was removed, including
+// when it fails part way.
+func (e *Enforcer) Enforce(ctx context.Context) (Report, error) {
+	var report Report
+	cfg := *e.config.Load()
+	if !cfg.Enabled {
+		return report, nil
+	}
+	now := e.now()
+
+	if before, ok := cfg.DropBefore(now); ok {
+		names, err := partition.List(ctx, e.db)
+		if err != nil {
+			return report, err
+		}
+		for _, name := range names {
+			day, _ := partition.ParseName(name)
+			if day.AddDate(0, 0, 1).After(before) {
+				break
+			}
+			rows, bytes, archived, err := e.dropPartition(ctx, name)
+			if err != nil {
+				return report, err
+			}
+			report.DroppedPartitions = append(report.DroppedPartitions, name)
+			report.Archived += archived
+			report.record(MethodDropPartition, rows, bytes)
+		}
+	}
+
+	for _, d := range cfg.deletions(now) {
+		for {
+			rows, bytes, err := e.deleteBatch(ctx, d, cfg.BatchSize)
+			if err != nil {
+				return report, err
+			}
+			report.record(MethodDelete, rows, bytes)
+			if e.archiver != nil {
+				report.Archived += rows
+			}
+			if rows < int64(cfg.BatchSize) {
+				break
+			}
+		}
+	}
+	return report, nil
+}
+
+// dropPartition drops a partition, archiving it first when an archiver is
+// set. The partition is locked against writes in the same transaction, so
+// that no log written after the archive is dropped with it, and is kept if
+// archiving fails.
+func (e *Enforcer) dropPartition(ctx context.Context, name string) (rows, bytes, archived int64, err error) {
+	tx, err := e.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := partition.Lock(ctx, tx, name); err != nil {
+		return 0, 0, 0, err
+	}
+	if e.archiver != nil {
+		m, err := e.archiver.ArchivePartition(ctx, tx, name)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		archived = m.Logs
+	}
+	rows, bytes, err = partition.Drop(ctx, tx, name)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to commit dropping partition %s: %w", name, err)
+	}
+	return rows, bytes, archived, nil
+}
+
+// record adds removed logs to the report and to the retention metrics
+func (r *Report) record(method string, rows, bytes int64) {
+	r.Rows += rows
+	r.Bytes += bytes
+	metrics.RetentionRows.WithLabelValues(method).Add(float64(rows))
+	metrics.RetentionBytes.WithLabelValues(method).Add(float64(bytes))
+}
+
+// deletion selects the expired logs of one rule, or of the default TTL
+type deletion struct {
+	// where matches the logs of the rule, with args from $2 on
+	where  string
+	args   []interface{}
+	before time.Time
+}
+
+// deletions returns a deletion for every rule and the default TTL that
+// expire logs. Logs matched by an earlier rule are excluded from later ones.
+func (c Config) deletions(now time.Time) []deletion {
+	var deletions []deletion
+	var earlier []string
+	var args []interface{}
+
+	for _, rule := range c.Rules {
+		var conds []string
+		if rule.Service != "" {
+			args = append(args, models.GlobToLike(rule.Service))
+			conds = append(conds, fmt.Sprintf("service LIKE $%d", len(args)+1))
+		}
+		if rule.Level != "" {
+			args = append(args, rule.Level)
+			conds = append(conds, fmt.Sprintf("level = $%d", len(args)+1))
+		}
+		match := "TRUE"
+		if len(conds) > 0 {
+			match = strings.Join(conds, " AND ")
+		}
+
+		if rule.TTL > 0 {
+			deletions = append(deletions, deletion{
+				where:  excluding(match, earlier),
+				args:   append([]interface{}(nil), args...),
+				before: now.Add(-rule.TTL),
+			})
+		}
+		earlier = append(earlier, "("+match+")")
+	}
+
+	if c.DefaultTTL > 0 {
+		deletions = append(deletions, deletion{
+			where:  excluding("TRUE", earlier),
+			args:   args,
+			before: now.Add(-c.DefaultTTL),
+		})
+	}
+	return deletions
+}
+
+// excluding returns match restricted to logs none of earlier matches
+func excluding(match string, earlier []string) string {
+	if len(earlier) == 0 {
+		return match
+	}
+	return fmt.Sprintf("%s AND NOT (%s)", match, strings.Join(earlier, " OR "))
+}
+
+// expired returns a WITH query, named expired, selecting up to limit logs
+// of d, and its args
+func (d deletion) expired(limit int) (string, []interface{}) {
+	args := append([]interface{}{d.before}, d.args...)
+	args = append(args, limit)
+	return fmt.Sprintf(`expired AS (
+			SELECT id, timestamp FROM logs
+			WHERE timestamp < $1 AND %s
+			LIMIT $%d
+		)`, d.where, len(args)), args
+}
+
+// deleteExpired deletes the logs selected by expired
+const deleteExpired = `DELETE FROM logs l USING expired e
+		WHERE l.id = e.id AND l.timestamp = e.timestamp`
+
+// deleteBatch deletes up to limit logs of d, archiving them first when an
+// archiver is set, and returns their number and stored size
+func (e *Enforcer) deleteBatch(ctx context.Context, d deletion, limit int) (rows, bytes int64, err error) {
+	defer metrics.ObserveDBQuery("retention_delete", time.Now())
+
+	expired, args := d.expired(limit)
+	if e.archiver != nil {
+		return e.archiveBatch(ctx, `WITH `+expired+`
+		`+deleteExpired+`
+		RETURNING l.id, l.tenant_id, l.service, l.level, l.message, l.timestamp, l.meta, l.created_at, pg_column_size(l.*)`, args)
+	}
+
+	query := `WITH ` + expired + `, deleted AS (
+		` + deleteExpired + `
+		RETURNING pg_column_size(l.*) AS size
+	)
+	SELECT count(*), COALESCE(sum(size), 0) FROM deleted`
+	if err := e.db.QueryRow(ctx, query, args...).Scan(&rows, &bytes); err != nil {
+		return 0, 0, fmt.Errorf("failed to delete expired logs: %w", err)
+	}
+	return rows, bytes, nil
+}
+
+// archiveBatch runs the DELETE query and archives the logs it returns,
+// keeping them when archiving fails
+func (e *Enforcer) archiveBatch(ctx context.Context, query string, args []interface{}) (rows, bytes int64, err error) {
+	tx, err := e.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	m, err := e.archiver.ArchiveDeleted(ctx, tx, query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit archived deletes: %w", err)
+	}
+	return m.Logs, m.StoredBytes, nil
+}
+
+// enforceLocked runs Enforce while holding lockKey, and skips the run when
+// another replica holds it
+func (e *Enforcer) enforceLocked(ctx context.Context) (Report, error) {
+	conn, err := e.db.Acquire(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, int64(lockKey)).Scan(&locked); err != nil {
+		return Report{}, fmt.Errorf("failed to lock retention: %w", err)
+	}
+	if !locked {
+		slog.DebugContext(ctx, "retention enforced by another replica")
+		return Report{}, nil
+	}
+	defer func() {
+		// The lock belongs to the session, so a connection that fails to
+		// unlock is closed rather than returned to the pool still holding it
+		unlockCtx := context.WithoutCancel(ctx)
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, int64(lockKey)); err != nil {
+			slog.WarnContext(ctx, "failed to unlock retention", logging.Err(err))
+			_ = conn.Conn().Close(unlockCtx)
+		}
+	}()
+
+	return e.Enforce(ctx)
+}
+
+// Run enforces the rules now and then every interval until ctx is done. With
+// several replicas, each run is skipped by all but the one taking the lock.
+func (e *Enforcer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		report, err := e.enforceLocked(ctx)
+		if report.Rows > 0 || len(report.DroppedPartitions) > 0 {
+			slog.InfoContext(ctx, "removed expired logs",
+				"rows", report.Rows,
+				"bytes", report.Bytes,
+				"archived", report.Archived,
+				"dropped_partitions", report.DroppedPartitions,
+				"duration_ms", time.Since(start).Milliseconds())
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to enforce retention", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
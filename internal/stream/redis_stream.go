@@ -3,11 +3,17 @@ package stream
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/tracing"
 	"github.com/yunjin08/logscale/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RedisStreamService struct {
@@ -17,46 +23,41 @@ type RedisStreamService struct {
 }
 
 // NewRedisStreamService creates a new Redis Stream service
-func NewRedisStreamService(redisURL, streamName string) (*RedisStreamService, error) {
-	log.Printf("DEBUG: Initializing Redis Stream service with URL: %s, stream: %s", redisURL, streamName)
-
-	opts, err := redis.ParseURL(redisURL)
-	if err != nil {
-		log.Printf("DEBUG: Failed to parse Redis URL: %v", err)
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
-	}
-
+func NewRedisStreamService(opts *redis.Options, config models.StreamConfig) (*RedisStreamService, error) {
 	client := redis.NewClient(opts)
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	log.Printf("DEBUG: Testing Redis connection...")
-	if err = client.Ping(ctx).Err(); err != nil {
-		log.Printf("DEBUG: Failed to ping Redis: %v", err)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
-	log.Printf("DEBUG: Redis connection successful")
-
-	config := models.StreamConfig{
-		StreamName:    streamName,
-		ConsumerGroup: "logscale-workers",
-		ConsumerName:  "worker-1",
-	}
 
-	log.Printf("DEBUG: Creating Redis Stream service with config: %+v", config)
+	slog.Debug("connected to Redis stream", logging.KeyStream, config.StreamName)
 	return &RedisStreamService{
 		client:     client,
-		streamName: streamName,
+		streamName: config.StreamName,
 		config:     config,
 	}, nil
 }
 
-// PublishLogEvent publishes a log event to Redis Stream
+// PublishLogEvent publishes a log event to Redis Stream. The trace context
+// of ctx travels in the message fields to the worker.
 func (s *RedisStreamService) PublishLogEvent(ctx context.Context, logEntry models.Log) error {
+	ctx, span := tracing.Tracer().Start(ctx, "stream publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", s.streamName),
+			attribute.Int64("logscale.log_id", logEntry.ID),
+		))
+	defer span.End()
+
 	eventMap := map[string]interface{}{
 		"id":         fmt.Sprintf("%d", logEntry.ID),
+		"tenant_id":  logEntry.TenantID,
 		"service":    logEntry.Service,
 		"level":      logEntry.Level,
 		"message":    logEntry.Message,
@@ -65,16 +66,28 @@ func (s *RedisStreamService) PublishLogEvent(ctx context.Context, logEntry model
 		"created_at": logEntry.Timestamp.Format(time.RFC3339),
 	}
 
+	tracing.InjectStream(ctx, eventMap)
+
 	result := s.client.XAdd(ctx, &redis.XAddArgs{
 		Stream: s.streamName,
 		Values: eventMap,
 	})
 
 	if result.Err() != nil {
+		metrics.StreamPublishes.WithLabelValues(metrics.ResultFailed).Inc()
+		span.RecordError(result.Err())
+		span.SetStatus(codes.Error, result.Err().Error())
 		return fmt.Errorf("failed to publish event to stream: %w", result.Err())
 	}
-
-	log.Printf("Published event to stream %s: %s", s.streamName, result.Val())
+	metrics.StreamPublishes.WithLabelValues(metrics.ResultOK).Inc()
+	span.SetAttributes(attribute.String("messaging.message.id", result.Val()))
+
+	slog.DebugContext(ctx, "published log event",
+		logging.KeyStream, s.streamName,
+		logging.KeyMessageID, result.Val(),
+		logging.KeyLogID, logEntry.ID,
+		logging.KeyTenant, logEntry.TenantID,
+		logging.KeyService, logEntry.Service)
 	return nil
 }
 
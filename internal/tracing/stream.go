@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+)
+
+// StreamCarrier carries trace context in the fields of a Redis stream
+// message, such as the traceparent field
+type StreamCarrier map[string]interface{}
+
+// Get implements propagation.TextMapCarrier
+func (c StreamCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+// Set implements propagation.TextMapCarrier
+func (c StreamCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys implements propagation.TextMapCarrier
+func (c StreamCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// InjectStream adds the trace context of ctx to the fields of a stream message
+func InjectStream(ctx context.Context, values map[string]interface{}) {
+	otel.GetTextMapPropagator().Inject(ctx, StreamCarrier(values))
+}
+
+// ExtractStream returns ctx carrying the trace context of a stream message
+func ExtractStream(ctx context.Context, values map[string]interface{}) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, StreamCarrier(values))
+}
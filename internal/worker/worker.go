@@ -4,13 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/yunjin08/logscale/internal/analytics"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/tracing"
 	"github.com/yunjin08/logscale/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Worker processes events from Redis Streams
@@ -20,17 +28,18 @@ type Worker struct {
 	streamName    string
 	consumerGroup string
 	consumerName  string
-	maxRetries    int
+	batchSize     int
+	blockTimeout  time.Duration
 	retryDelay    time.Duration
+	logger        *slog.Logger
+
+	// lastProgress is when the processing loop last finished a round, in
+	// Unix nanoseconds
+	lastProgress atomic.Int64
 }
 
 // NewWorker creates a new worker instance
-func NewWorker(redisURL string, analyticsSvc *analytics.Service, streamName string) (*Worker, error) {
-	opts, err := redis.ParseURL(redisURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
-	}
-
+func NewWorker(opts *redis.Options, analyticsSvc *analytics.Service, config models.StreamConfig) (*Worker, error) {
 	client := redis.NewClient(opts)
 
 	// Test connection
@@ -38,17 +47,24 @@ func NewWorker(redisURL string, analyticsSvc *analytics.Service, streamName stri
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
 	return &Worker{
 		redisClient:   client,
 		analyticsSvc:  analyticsSvc,
-		streamName:    streamName,
-		consumerGroup: "logscale-workers",
-		consumerName:  "worker-1",
-		maxRetries:    3,
-		retryDelay:    5 * time.Second,
+		streamName:    config.StreamName,
+		consumerGroup: config.ConsumerGroup,
+		consumerName:  config.ConsumerName,
+		batchSize:     config.BatchSize,
+		blockTimeout:  config.BlockTimeout,
+		retryDelay:    config.RetryDelay,
+		logger: slog.With(
+			logging.KeyStream, config.StreamName,
+			logging.KeyGroup, config.ConsumerGroup,
+			logging.KeyConsumer, config.ConsumerName,
+		),
 	}, nil
 }
 
@@ -60,19 +76,19 @@ func (w *Worker) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to create consumer group: %w", err)
 	}
 
-	log.Printf("Worker started. Listening to stream: %s, group: %s, consumer: %s",
-		w.streamName, w.consumerGroup, w.consumerName)
+	w.logger.Info("worker started")
 
 	// Process events in a loop
 	for {
+		w.lastProgress.Store(time.Now().UnixNano())
 		select {
 		case <-ctx.Done():
-			log.Println("Worker stopped by context cancellation")
+			w.logger.Info("worker stopped")
 			return nil
 		default:
 			err := w.processEvents(ctx)
 			if err != nil {
-				log.Printf("Error processing events: %v", err)
+				w.logger.Error("failed to process events", logging.Err(err), "retry_in", w.retryDelay)
 				time.Sleep(w.retryDelay)
 			}
 		}
@@ -96,8 +112,8 @@ func (w *Worker) processEvents(ctx context.Context) error {
 		Group:    w.consumerGroup,
 		Consumer: w.consumerName,
 		Streams:  []string{w.streamName, ">"},
-		Count:    10,
-		Block:    1 * time.Second,
+		Count:    int64(w.batchSize),
+		Block:    w.blockTimeout,
 	}).Result()
 
 	if err != nil && err != redis.Nil {
@@ -112,20 +128,23 @@ func (w *Worker) processEvents(ctx context.Context) error {
 	for _, stream := range streams {
 		for _, message := range stream.Messages {
 
+			start := time.Now()
 			err := w.processEvent(ctx, message)
+			metrics.WorkerEventDuration.Observe(time.Since(start).Seconds())
 			if err != nil {
-				log.Printf("Failed to process event %s: %v", message.ID, err)
+				metrics.WorkerEvents.WithLabelValues(metrics.ResultFailed).Inc()
 				// Acknowledge the message to prevent reprocessing
 				if ackErr := w.acknowledgeMessage(ctx, message.ID); ackErr != nil {
-					log.Printf("Failed to acknowledge failed message %s: %v", message.ID, ackErr)
+					w.logger.Error("failed to acknowledge failed message", logging.KeyMessageID, message.ID, logging.Err(ackErr))
 				}
 				continue
 			}
 
 			// Acknowledge successful processing
+			metrics.WorkerEvents.WithLabelValues(metrics.ResultOK).Inc()
 			err = w.acknowledgeMessage(ctx, message.ID)
 			if err != nil {
-				log.Printf("Failed to acknowledge message %s: %v", message.ID, err)
+				w.logger.Error("failed to acknowledge message", logging.KeyMessageID, message.ID, logging.Err(err))
 			}
 		}
 	}
@@ -133,8 +152,35 @@ func (w *Worker) processEvents(ctx context.Context) error {
 	return nil
 }
 
-// processEvent processes a single event
-func (w *Worker) processEvent(ctx context.Context, message redis.XMessage) error {
+// processEvent processes a single event, continuing the trace of the
+// request that published it
+func (w *Worker) processEvent(ctx context.Context, message redis.XMessage) (err error) {
+	ctx = tracing.ExtractStream(ctx, message.Values)
+	ctx, span := tracing.Tracer().Start(ctx, "stream process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", w.streamName),
+			attribute.String("messaging.consumer.group.name", w.consumerGroup),
+			attribute.String("messaging.message.id", message.ID),
+		))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			w.logger.ErrorContext(ctx, "failed to process log event", logging.KeyMessageID, message.ID, logging.Err(err))
+		}
+		span.End()
+	}()
+
+	// Stream IDs start with the publish time in milliseconds, so the span
+	// shows how long the event waited in the stream
+	if millis, _, ok := strings.Cut(message.ID, "-"); ok {
+		if published, err := strconv.ParseInt(millis, 10, 64); err == nil {
+			span.SetAttributes(attribute.Int64("logscale.stream_wait_ms", time.Now().UnixMilli()-published))
+		}
+	}
+
 	// Parse the event from message values
 	event, err := w.parseEvent(message.Values)
 	if err != nil {
@@ -146,8 +192,12 @@ func (w *Worker) processEvent(ctx context.Context, message redis.XMessage) error
 		return fmt.Errorf("failed to update analytics: %w", err)
 	}
 
-	log.Printf("Processed event: service=%s, level=%s, id=%s",
-		event.Service, event.Level, event.ID)
+	w.logger.DebugContext(ctx, "processed log event",
+		logging.KeyMessageID, message.ID,
+		logging.KeyLogID, event.ID,
+		logging.KeyTenant, event.TenantID,
+		logging.KeyService, event.Service,
+		logging.KeyLogLevel, event.Level)
 	return nil
 }
 
@@ -159,6 +209,12 @@ func (w *Worker) parseEvent(values map[string]interface{}) (*models.LogEvent, er
 		return nil, fmt.Errorf("invalid id field")
 	}
 
+	// Events published before multi-tenancy carry no tenant
+	tenantID, ok := values["tenant_id"].(string)
+	if !ok || tenantID == "" {
+		tenantID = models.DefaultTenant
+	}
+
 	service, ok := values["service"].(string)
 	if !ok {
 		return nil, fmt.Errorf("invalid service field")
@@ -203,6 +259,7 @@ func (w *Worker) parseEvent(values map[string]interface{}) (*models.LogEvent, er
 
 	return &models.LogEvent{
 		ID:        id,
+		TenantID:  tenantID,
 		Service:   service,
 		Level:     level,
 		Message:   message,
@@ -217,6 +274,21 @@ func (w *Worker) acknowledgeMessage(ctx context.Context, messageID string) error
 	return w.redisClient.XAck(ctx, w.streamName, w.consumerGroup, messageID).Err()
 }
 
+// Client returns the underlying Redis client
+func (w *Worker) Client() *redis.Client {
+	return w.redisClient
+}
+
+// LastProgress returns when the processing loop last finished a round. It
+// is zero until Start runs.
+func (w *Worker) LastProgress() time.Time {
+	nanos := w.lastProgress.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
 // Close closes the Redis connection
 func (w *Worker) Close() error {
 	return w.redisClient.Close()
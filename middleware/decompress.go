@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Decompress transparently inflates request bodies sent with
+// Content-Encoding: gzip, as the log shipper agent does. Inflated bodies
+// larger than maxBytes fail with *http.MaxBytesError when read, since
+// BodyLimit only sees the compressed bytes.
+func Decompress(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+			c.Next()
+			return
+		}
+
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid gzip body"})
+			return
+		}
+		defer gz.Close()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, gz, maxBytes)
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// APIKey represents an API key. The secret is never stored or returned
+// after creation; only its hash is kept.
+type APIKey struct {
+	ID          int64      `json:"id" db:"id"`
+	TenantID    string     `json:"tenant_id" db:"tenant_id"`
+	Name        string     `json:"name" db:"name"`
+	Prefix      string     `json:"prefix" db:"prefix"`
+	Scopes      []string   `json:"scopes" db:"scopes"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RotatedFrom *int64     `json:"rotated_from,omitempty" db:"rotated_from"`
+	RoleID      *int64     `json:"role_id,omitempty" db:"role_id"`
+	CertSubject *string    `json:"cert_subject,omitempty" db:"cert_subject"`
+}
+
+// CreateAPIKeyRequest represents the payload for creating an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// TenantID defaults to the tenant of the key making the request
+	TenantID string `json:"tenant_id"`
+	// RoleID restricts what the key can read; omit for unrestricted access
+	RoleID *int64 `json:"role_id"`
+	// CertSubject lets clients authenticate as the key with a TLS client
+	// certificate: a full subject DN such as "CN=shipper,O=Payments", or a
+	// common name
+	CertSubject *string `json:"cert_subject"`
+}
+
+// SetAPIKeyRoleRequest represents the payload for changing a key's role
+type SetAPIKeyRoleRequest struct {
+	// RoleID of null removes the key's restrictions
+	RoleID *int64 `json:"role_id"`
+}
+
+// RotateAPIKeyRequest represents the payload for rotating an API key
+type RotateAPIKeyRequest struct {
+	// GracePeriod is how long the old key keeps working, e.g. "24h" (default: immediately expired)
+	GracePeriod string `json:"grace_period"`
+}
+
+// APIKeyResponse is returned when a key is created or rotated. Token is
+// the only time the full secret is shown.
+type APIKeyResponse struct {
+	Key   APIKey `json:"key"`
+	Token string `json:"token"`
+}
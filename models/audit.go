@@ -0,0 +1,81 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// GenesisHash is the prev_hash of the first audit event
+var GenesisHash = strings.Repeat("0", 64)
+
+// AuditEvent records one authenticated query or administrative change
+type AuditEvent struct {
+	ID         int64           `json:"id" db:"id"`
+	TenantID   string          `json:"tenant_id" db:"tenant_id"`
+	OccurredAt time.Time       `json:"occurred_at" db:"occurred_at"`
+	ActorKeyID *int64          `json:"actor_key_id,omitempty" db:"actor_key_id"`
+	Actor      string          `json:"actor" db:"actor"`
+	Action     string          `json:"action" db:"action"`
+	Resource   string          `json:"resource" db:"resource"`
+	Status     int             `json:"status" db:"status"`
+	Details    json.RawMessage `json:"details" db:"details"`
+	PrevHash   string          `json:"prev_hash" db:"prev_hash"`
+	Hash       string          `json:"hash" db:"hash"`
+}
+
+// AuditQuery represents query parameters for filtering audit events
+type AuditQuery struct {
+	TenantID   string `form:"tenant_id"`
+	ActorKeyID *int64 `form:"actor_key_id"`
+	Action     string `form:"action"`
+	From       string `form:"from"`
+	To         string `form:"to"`
+}
+
+// AuditVerification is the result of checking the audit hash chain
+type AuditVerification struct {
+	Valid   bool  `json:"valid"`
+	Checked int64 `json:"checked"`
+	// BrokenAt is the id of the first event whose hash does not match
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	// LastHash can be kept elsewhere to detect later removal of the newest events
+	LastHash string `json:"last_hash"`
+}
+
+// ComputeHash returns the SHA-256 chaining e to PrevHash. Details are
+// canonicalized so the hash survives the JSONB round trip.
+func (e *AuditEvent) ComputeHash() string {
+	var keyID interface{}
+	if e.ActorKeyID != nil {
+		keyID = *e.ActorKeyID
+	}
+	content, _ := json.Marshal([]interface{}{
+		e.PrevHash,
+		e.TenantID,
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		keyID,
+		e.Actor,
+		e.Action,
+		e.Resource,
+		e.Status,
+		canonicalJSON(e.Details),
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON decodes and re-encodes raw so that key order and spacing
+// do not matter
+func canonicalJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return map[string]interface{}{}
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	return v
+}
@@ -0,0 +1,49 @@
+package models
+
+import "strings"
+
+// Canonical log levels, from most to least severe
+const (
+	LevelFatal = "fatal"
+	LevelError = "error"
+	LevelWarn  = "warn"
+	LevelInfo  = "info"
+	LevelDebug = "debug"
+	LevelTrace = "trace"
+)
+
+// Levels lists the canonical levels from most to least severe
+var Levels = []string{LevelFatal, LevelError, LevelWarn, LevelInfo, LevelDebug, LevelTrace}
+
+// levelAliases maps the spellings used by common logging libraries onto canonical levels
+var levelAliases = map[string]string{
+	"fatal":         LevelFatal,
+	"critical":      LevelFatal,
+	"crit":          LevelFatal,
+	"emergency":     LevelFatal,
+	"emerg":         LevelFatal,
+	"alert":         LevelFatal,
+	"panic":         LevelFatal,
+	"error":         LevelError,
+	"err":           LevelError,
+	"severe":        LevelError,
+	"warn":          LevelWarn,
+	"warning":       LevelWarn,
+	"info":          LevelInfo,
+	"information":   LevelInfo,
+	"informational": LevelInfo,
+	"notice":        LevelInfo,
+	"debug":         LevelDebug,
+	"fine":          LevelDebug,
+	"trace":         LevelTrace,
+	"finer":         LevelTrace,
+	"finest":        LevelTrace,
+	"verbose":       LevelTrace,
+}
+
+// NormalizeLevel maps a level name onto its canonical form, ignoring case
+// and surrounding whitespace. It returns false for unrecognised levels.
+func NormalizeLevel(level string) (string, bool) {
+	canonical, ok := levelAliases[strings.ToLower(strings.TrimSpace(level))]
+	return canonical, ok
+}
@@ -8,6 +8,7 @@ import (
 // Log represents a log entry
 type Log struct {
 	ID        int64           `json:"id" db:"id"`
+	TenantID  string          `json:"tenant_id" db:"tenant_id"`
 	Service   string          `json:"service" db:"service"`
 	Level     string          `json:"level" db:"level"`
 	Message   string          `json:"message" db:"message"`
@@ -42,6 +43,16 @@ type LogResponse struct {
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
-	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
+	Status    string        `json:"status"`
+	Timestamp string        `json:"timestamp"`
+	Checks    []HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of checking one dependency
+type HealthCheck struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
 }
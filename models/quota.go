@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Quota hard actions
+const (
+	QuotaActionReject = "reject"
+	QuotaActionSample = "sample"
+)
+
+// Quota is a daily ingestion budget for a tenant, or for one service of a
+// tenant. Zero limits are not enforced.
+type Quota struct {
+	ID         int64     `json:"id" db:"id"`
+	TenantID   string    `json:"tenant_id" db:"tenant_id"`
+	Service    string    `json:"service,omitempty" db:"service"`
+	SoftLines  int64     `json:"soft_lines" db:"soft_lines"`
+	SoftBytes  int64     `json:"soft_bytes" db:"soft_bytes"`
+	HardLines  int64     `json:"hard_lines" db:"hard_lines"`
+	HardBytes  int64     `json:"hard_bytes" db:"hard_bytes"`
+	HardAction string    `json:"hard_action" db:"hard_action"`
+	SampleRate float64   `json:"sample_rate" db:"sample_rate"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// QuotaReservation is volume about to be stored, counted against a quota's
+// hard limits
+type QuotaReservation struct {
+	Quota Quota
+	Lines int64
+	Bytes int64
+}
+
+// QuotaRequest represents the payload for creating or replacing a quota
+type QuotaRequest struct {
+	// TenantID defaults to the tenant of the key making the request
+	TenantID   string   `json:"tenant_id"`
+	Service    string   `json:"service"`
+	SoftLines  int64    `json:"soft_lines" binding:"min=0"`
+	SoftBytes  int64    `json:"soft_bytes" binding:"min=0"`
+	HardLines  int64    `json:"hard_lines" binding:"min=0"`
+	HardBytes  int64    `json:"hard_bytes" binding:"min=0"`
+	HardAction string   `json:"hard_action" binding:"omitempty,oneof=reject sample"`
+	SampleRate *float64 `json:"sample_rate" binding:"omitempty,min=0,max=1"`
+}
+
+// Usage is the volume ingested for a service on one UTC day
+type Usage struct {
+	Day          string `json:"day"`
+	Service      string `json:"service"`
+	Lines        int64  `json:"lines"`
+	Bytes        int64  `json:"bytes"`
+	DroppedLines int64  `json:"dropped_lines"`
+}
+
+// UsageQuery represents query parameters for GET /v1/usage
+type UsageQuery struct {
+	Service string `form:"service"`
+	From    string `form:"from"`
+	To      string `form:"to"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// DroppedLogs counts logs of a service rejected by rate limiting
+type DroppedLogs struct {
+	TenantID string `json:"tenant_id"`
+	Service  string `json:"service"`
+	Count    int64  `json:"count"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// RedactionCount counts values a redaction rule removed from a service's logs
+type RedactionCount struct {
+	TenantID string `json:"tenant_id"`
+	Service  string `json:"service"`
+	Rule     string `json:"rule"`
+	Count    int64  `json:"count"`
+}
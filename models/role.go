@@ -0,0 +1,208 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Meta modes decide what happens to meta keys a role may not read
+const (
+	// MetaModeRedact replaces the values of disallowed keys
+	MetaModeRedact = "redact"
+	// MetaModeDeny hides logs that carry disallowed keys
+	MetaModeDeny = "deny"
+)
+
+// RedactedValue replaces meta values a principal may not read
+const RedactedValue = "[REDACTED]"
+
+// Role is a read policy that can be assigned to API keys
+type Role struct {
+	ID              int64     `json:"id" db:"id"`
+	TenantID        string    `json:"tenant_id" db:"tenant_id"`
+	Name            string    `json:"name" db:"name"`
+	ServicePatterns []string  `json:"service_patterns" db:"service_patterns"`
+	MetaFields      []string  `json:"meta_fields" db:"meta_fields"`
+	MetaMode        string    `json:"meta_mode" db:"meta_mode"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RoleRequest represents the payload for creating or updating a role
+type RoleRequest struct {
+	// TenantID defaults to the tenant of the key making the request
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name" binding:"required"`
+	// ServicePatterns are globs such as "payments-*"; empty allows every service
+	ServicePatterns []string `json:"service_patterns"`
+	// MetaFields lists readable top-level meta keys; null allows every key
+	MetaFields []string `json:"meta_fields"`
+	MetaMode   string   `json:"meta_mode" binding:"omitempty,oneof=redact deny"`
+}
+
+// AccessPolicy restricts which logs and meta fields a principal may read.
+// A nil policy allows everything.
+type AccessPolicy struct {
+	ServicePatterns []string
+	MetaFields      []string
+	MetaMode        string
+}
+
+// Policy returns the access policy of the role
+func (r *Role) Policy() *AccessPolicy {
+	mode := r.MetaMode
+	if mode == "" {
+		mode = MetaModeRedact
+	}
+	return &AccessPolicy{
+		ServicePatterns: r.ServicePatterns,
+		MetaFields:      r.MetaFields,
+		MetaMode:        mode,
+	}
+}
+
+// ValidateServicePatterns checks that every pattern is a valid glob. Only
+// '*', '?' and '\' escapes are supported so that patterns mean the same in
+// Go and in SQL.
+func ValidateServicePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if p == "" {
+			return fmt.Errorf("empty service pattern")
+		}
+		trailing := len(p) - len(strings.TrimRight(p, `\`))
+		if trailing%2 == 1 {
+			return fmt.Errorf("invalid service pattern %q: trailing escape", p)
+		}
+	}
+	return nil
+}
+
+// AllowsService reports whether the policy lets the principal read service
+func (p *AccessPolicy) AllowsService(service string) bool {
+	if p == nil || len(p.ServicePatterns) == 0 {
+		return true
+	}
+	for _, pattern := range p.ServicePatterns {
+		if MatchGlob(pattern, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// RestrictsMeta reports whether only some meta keys may be read
+func (p *AccessPolicy) RestrictsMeta() bool {
+	return p != nil && p.MetaFields != nil
+}
+
+// ServiceLikePatterns converts the service globs to SQL LIKE patterns
+// (escape character '\'), or nil when every service is allowed
+func (p *AccessPolicy) ServiceLikePatterns() []string {
+	if p == nil || len(p.ServicePatterns) == 0 {
+		return nil
+	}
+	likes := make([]string, len(p.ServicePatterns))
+	for i, glob := range p.ServicePatterns {
+		likes[i] = GlobToLike(glob)
+	}
+	return likes
+}
+
+// RedactMeta replaces the values of meta keys the policy does not allow
+func (p *AccessPolicy) RedactMeta(meta json.RawMessage) json.RawMessage {
+	if !p.RestrictsMeta() || len(meta) == 0 {
+		return meta
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(meta, &fields); err != nil || fields == nil {
+		return meta
+	}
+
+	allowed := make(map[string]bool, len(p.MetaFields))
+	for _, key := range p.MetaFields {
+		allowed[key] = true
+	}
+
+	redacted := json.RawMessage(`"` + RedactedValue + `"`)
+	changed := false
+	for key := range fields {
+		if !allowed[key] {
+			fields[key] = redacted
+			changed = true
+		}
+	}
+	if !changed {
+		return meta
+	}
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return meta
+	}
+	return out
+}
+
+// GlobToLike converts a glob using * and ? into a SQL LIKE pattern
+func GlobToLike(glob string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range glob {
+		switch {
+		case escaped:
+			writeLikeLiteral(&b, r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteRune('%')
+		case r == '?':
+			b.WriteRune('_')
+		default:
+			writeLikeLiteral(&b, r)
+		}
+	}
+	return b.String()
+}
+
+// MatchGlob matches s against a glob of '*' (any run), '?' (one character)
+// and '\' escapes, with the same semantics as GlobToLike
+func MatchGlob(glob, s string) bool {
+	pattern := []rune(glob)
+	text := []rune(s)
+
+	// Backtracking over the last '*' keeps this linear for typical patterns
+	p, t := 0, 0
+	starP, starT := -1, 0
+	for t < len(text) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starT = p, t
+			p++
+		case p < len(pattern) && pattern[p] == '\\' && p+1 < len(pattern) && pattern[p+1] == text[t]:
+			p += 2
+			t++
+		case p < len(pattern) && (pattern[p] == '?' || (pattern[p] != '\\' && pattern[p] == text[t])):
+			p++
+			t++
+		case starP >= 0:
+			starT++
+			p, t = starP+1, starT
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func writeLikeLiteral(b *strings.Builder, r rune) {
+	if r == '%' || r == '_' || r == '\\' {
+		b.WriteRune('\\')
+	}
+	b.WriteRune(r)
+}
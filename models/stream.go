@@ -8,6 +8,7 @@ import (
 // LogEvent represents an event published to Redis Stream
 type LogEvent struct {
 	ID        string          `json:"id"`
+	TenantID  string          `json:"tenant_id"`
 	Service   string          `json:"service"`
 	Level     string          `json:"level"`
 	Message   string          `json:"message"`
@@ -18,20 +19,29 @@ type LogEvent struct {
 
 // StreamConfig holds Redis Stream configuration
 type StreamConfig struct {
-	StreamName    string `json:"stream_name"`
-	ConsumerGroup string `json:"consumer_group"`
-	ConsumerName  string `json:"consumer_name"`
+	StreamName    string `json:"stream_name" yaml:"name"`
+	ConsumerGroup string `json:"consumer_group" yaml:"consumer_group"`
+	ConsumerName  string `json:"consumer_name" yaml:"consumer_name"`
+	// BatchSize is the most events a worker reads at once
+	BatchSize int `json:"batch_size" yaml:"batch_size"`
+	// BlockTimeout is how long a read waits for new events
+	BlockTimeout time.Duration `json:"block_timeout" yaml:"block_timeout"`
+	// RetryDelay is the pause after a failed read
+	RetryDelay time.Duration `json:"retry_delay" yaml:"retry_delay"`
 }
 
 // ServiceMetrics represents aggregated analytics
 type ServiceMetrics struct {
 	ID           int64     `json:"id" db:"id"`
+	TenantID     string    `json:"tenant_id" db:"tenant_id"`
 	Service      string    `json:"service" db:"service"`
 	TotalLogs    int64     `json:"total_logs" db:"total_logs"`
+	FatalCount   int64     `json:"fatal_count" db:"fatal_count"`
 	ErrorCount   int64     `json:"error_count" db:"error_count"`
 	WarningCount int64     `json:"warning_count" db:"warning_count"`
 	InfoCount    int64     `json:"info_count" db:"info_count"`
 	DebugCount   int64     `json:"debug_count" db:"debug_count"`
+	TraceCount   int64     `json:"trace_count" db:"trace_count"`
 	ErrorRate    float64   `json:"error_rate" db:"error_rate"`
 	LastLogTime  time.Time `json:"last_log_time" db:"last_log_time"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
@@ -41,6 +51,7 @@ type ServiceMetrics struct {
 // DeadLetterEvent represents failed events
 type DeadLetterEvent struct {
 	ID         string          `json:"id"`
+	TenantID   string          `json:"tenant_id"`
 	OriginalID string          `json:"original_id"`
 	Event      json.RawMessage `json:"event"`
 	Error      string          `json:"error"`
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultTenant owns data written before multi-tenancy and data from
+// inputs that have no tenant configured
+const DefaultTenant = "default"
+
+// tenantIDPattern keeps tenant ids safe to use in keys, URLs and metric labels
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidateTenantID checks that id is a well-formed tenant id
+func ValidateTenantID(id string) error {
+	if !tenantIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid tenant id %q: use up to 64 lowercase letters, digits, '-' or '_'", id)
+	}
+	return nil
+}
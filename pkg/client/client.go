@@ -0,0 +1,203 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yunjin08/logscale/models"
+	"github.com/yunjin08/logscale/pkg/pagination"
+)
+
+// Client is a typed client for the LogScale HTTP API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    http.Header
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header to every request
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Set(key, value)
+	}
+}
+
+// WithAPIKey authenticates every request with an API key
+func WithAPIKey(key string) Option {
+	return WithHeader("Authorization", "Bearer "+key)
+}
+
+// APIError is returned when the API answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("logscale: %d %s", e.StatusCode, e.Message)
+}
+
+// LogPage is one page of a log query
+type LogPage struct {
+	Logs       []models.Log          `json:"data"`
+	Pagination pagination.Pagination `json:"pagination"`
+}
+
+// New creates a client for the API at baseURL (e.g. http://logscale:8080)
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		headers:    make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CreateLog sends a single log
+// POST /v1/logs
+func (c *Client) CreateLog(ctx context.Context, req models.LogRequest) (*models.Log, error) {
+	var log models.Log
+	if err := c.do(ctx, http.MethodPost, "/v1/logs", map[string]models.LogRequest{"log": req}, &log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// CreateLogs sends a batch of logs in a single request
+// POST /v1/logs
+func (c *Client) CreateLogs(ctx context.Context, reqs []models.LogRequest) ([]models.Log, error) {
+	var response struct {
+		Logs  []models.Log `json:"logs"`
+		Count int          `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/logs", map[string][]models.LogRequest{"logs": reqs}, &response); err != nil {
+		return nil, err
+	}
+	return response.Logs, nil
+}
+
+// QueryLogs fetches one page of logs matching query
+// GET /v1/logs
+func (c *Client) QueryLogs(ctx context.Context, query models.LogQuery, page, limit int) (*LogPage, error) {
+	params := url.Values{}
+	setParam(params, "service", query.Service)
+	setParam(params, "level", query.Level)
+	setParam(params, "start_time", query.StartTime)
+	setParam(params, "end_time", query.EndTime)
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page))
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	path := "/v1/logs"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var result LogPage
+	if err := c.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueryAllLogs walks every page of a query, calling fn for each page until
+// it returns false or the last page is reached
+func (c *Client) QueryAllLogs(ctx context.Context, query models.LogQuery, limit int, fn func(*LogPage) bool) error {
+	for page := 1; ; page++ {
+		result, err := c.QueryLogs(ctx, query, page, limit)
+		if err != nil {
+			return err
+		}
+		if !fn(result) || !result.Pagination.HasNext() {
+			return nil
+		}
+	}
+}
+
+// Health checks the API health endpoint
+// GET /health
+func (c *Client) Health(ctx context.Context) (*models.HealthResponse, error) {
+	var health models.HealthResponse
+	if err := c.do(ctx, http.MethodGet, "/health", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// do performs a request, encoding body as JSON and decoding the response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(data))
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func setParam(params url.Values, key, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
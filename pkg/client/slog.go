@@ -0,0 +1,395 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/yunjin08/logscale/models"
+)
+
+// ErrHandlerClosed is returned when logging through a closed Handler
+var ErrHandlerClosed = errors.New("logscale: handler closed")
+
+// HandlerOptions configures a slog Handler
+type HandlerOptions struct {
+	// Level is the minimum level sent to LogScale (default slog.LevelInfo)
+	Level slog.Leveler
+	// AddSource records the caller's file and line in meta.source
+	AddSource bool
+	// BatchSize is the maximum number of logs per request (default 100)
+	BatchSize int
+	// FlushInterval is the maximum time a log waits before being sent (default 2s)
+	FlushInterval time.Duration
+	// QueueSize is how many logs may wait to be sent before new ones are dropped (default 10000)
+	QueueSize int
+	// OnError is called when a batch fails to send or a log is dropped (default: print to stderr)
+	OnError func(error)
+}
+
+// Handler is a slog.Handler that ships records to LogScale asynchronously.
+// Records are queued and sent in batches when BatchSize is reached, every
+// FlushInterval, and on Close.
+type Handler struct {
+	core    *batcher
+	service string
+	level   slog.Leveler
+	source  bool
+	goas    []groupOrAttrs
+}
+
+// groupOrAttrs is one WithGroup or WithAttrs call, applied in order
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewHandler creates a Handler that logs as service through c.
+// Call Close on shutdown to flush pending records.
+func NewHandler(c *Client, service string, opts *HandlerOptions) *Handler {
+	if opts == nil {
+		opts = &HandlerOptions{}
+	}
+
+	var level slog.Leveler = slog.LevelInfo
+	if opts.Level != nil {
+		level = opts.Level
+	}
+
+	onError := opts.OnError
+	if onError == nil {
+		// Never log through slog here: it may route back into this handler
+		onError = func(err error) { fmt.Fprintln(os.Stderr, err) }
+	}
+
+	core := &batcher{
+		client:        c,
+		batchSize:     positiveOr(opts.BatchSize, 100),
+		flushInterval: opts.FlushInterval,
+		queue:         make(chan models.LogRequest, positiveOr(opts.QueueSize, 10000)),
+		flushReq:      make(chan chan struct{}),
+		done:          make(chan struct{}),
+		onError:       onError,
+	}
+	if core.flushInterval <= 0 {
+		core.flushInterval = 2 * time.Second
+	}
+	go core.run()
+
+	return &Handler{
+		core:    core,
+		service: service,
+		level:   level,
+		source:  opts.AddSource,
+	}
+}
+
+// Enabled reports whether records at level are sent
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle queues a record for sending. It never blocks on the network;
+// records are dropped if the queue is full.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	meta := make(map[string]interface{})
+
+	// Attributes from WithAttrs/WithGroup, then the record's own, nested by group
+	current := meta
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			next := make(map[string]interface{})
+			current[goa.group] = next
+			current = next
+			continue
+		}
+		for _, a := range goa.attrs {
+			addAttr(current, a)
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(current, a)
+		return true
+	})
+	pruneEmpty(meta)
+
+	if h.source && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		meta["source"] = map[string]interface{}{
+			"function": frame.Function,
+			"file":     frame.File,
+			"line":     frame.Line,
+		}
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("logscale: failed to encode attributes: %w", err)
+	}
+
+	ts := r.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	return h.core.enqueue(models.LogRequest{
+		Service:   h.service,
+		Level:     LevelName(r.Level),
+		Message:   r.Message,
+		Timestamp: &ts,
+		Meta:      data,
+	})
+}
+
+// WithAttrs returns a handler that adds attrs to every record
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+// WithGroup returns a handler that nests subsequent attributes under name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *Handler) with(goa groupOrAttrs) *Handler {
+	h2 := *h
+	h2.goas = make([]groupOrAttrs, len(h.goas)+1)
+	copy(h2.goas, h.goas)
+	h2.goas[len(h.goas)] = goa
+	return &h2
+}
+
+// Flush sends every queued record and waits for the request to finish
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.core.flush(ctx)
+}
+
+// Close flushes queued records and stops the background sender.
+// Handlers derived with WithAttrs/WithGroup share the sender and are closed too.
+func (h *Handler) Close(ctx context.Context) error {
+	return h.core.close(ctx)
+}
+
+// LevelName maps a slog level onto a LogScale level. Levels above
+// slog.LevelError are fatal and levels below slog.LevelDebug are trace.
+func LevelName(level slog.Level) string {
+	switch {
+	case level > slog.LevelError:
+		return models.LevelFatal
+	case level >= slog.LevelError:
+		return models.LevelError
+	case level >= slog.LevelWarn:
+		return models.LevelWarn
+	case level >= slog.LevelInfo:
+		return models.LevelInfo
+	case level >= slog.LevelDebug:
+		return models.LevelDebug
+	default:
+		return models.LevelTrace
+	}
+}
+
+// addAttr stores a resolved attribute in m, expanding groups into nested maps
+func addAttr(m map[string]interface{}, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		target := m
+		if a.Key != "" {
+			target = make(map[string]interface{})
+			m[a.Key] = target
+		}
+		for _, ga := range attrs {
+			addAttr(target, ga)
+		}
+		return
+	}
+
+	m[a.Key] = attrValue(a.Value)
+}
+
+// attrValue converts a slog value into something encoding/json handles well
+func attrValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		switch val := v.Any().(type) {
+		case error:
+			return val.Error()
+		case json.Marshaler:
+			return val
+		case fmt.Stringer:
+			return val.String()
+		default:
+			if _, err := json.Marshal(val); err != nil {
+				return fmt.Sprint(val)
+			}
+			return val
+		}
+	default:
+		return v.Any()
+	}
+}
+
+// pruneEmpty removes group maps left empty because they received no attributes
+func pruneEmpty(m map[string]interface{}) {
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			pruneEmpty(nested)
+			if len(nested) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+}
+
+func positiveOr(v, fallback int) int {
+	if v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// batcher owns the queue and background sender shared by a Handler and its derivatives
+type batcher struct {
+	client        *Client
+	batchSize     int
+	flushInterval time.Duration
+	queue         chan models.LogRequest
+	flushReq      chan chan struct{}
+	done          chan struct{}
+	onError       func(error)
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (b *batcher) enqueue(req models.LogRequest) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrHandlerClosed
+	}
+
+	select {
+	case b.queue <- req:
+		return nil
+	default:
+		err := errors.New("logscale: queue full, dropping log")
+		b.onError(err)
+		return err
+	}
+}
+
+// run batches queued records until the queue is closed
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.LogRequest, 0, b.batchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := b.client.CreateLogs(ctx, batch); err != nil {
+			b.onError(fmt.Errorf("logscale: failed to send %d logs: %w", len(batch), err))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case req, ok := <-b.queue:
+			if !ok {
+				send()
+				return
+			}
+			batch = append(batch, req)
+			if len(batch) >= b.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-b.flushReq:
+			// Drain whatever is already queued before acknowledging. A closed
+			// queue is left to the receive above, which sends and returns.
+			for drained := false; !drained; {
+				select {
+				case req, ok := <-b.queue:
+					if !ok {
+						drained = true
+						break
+					}
+					batch = append(batch, req)
+					if len(batch) >= b.batchSize {
+						send()
+					}
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(ack)
+		}
+	}
+}
+
+func (b *batcher) flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case b.flushReq <- ack:
+	case <-b.done:
+		return ErrHandlerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *batcher) close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
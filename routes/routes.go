@@ -1,24 +1,118 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/internal/audit"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/middleware"
 	"github.com/yunjin08/logscale/pkg/pagination"
 )
 
+// Handlers groups everything SetupRoutes wires into the router
+type Handlers struct {
+	Auth       *auth.Service
+	Audit      *audit.Service
+	Logs       *v1.LogHandler
+	GELF       *v1.GELFHandler
+	APIKeys    *v1.APIKeyHandler
+	RateLimits *v1.RateLimitHandler
+	Quotas     *v1.QuotaHandler
+	Roles      *v1.RoleHandler
+	Metrics    *v1.MetricsHandler
+	Redactions *v1.RedactionHandler
+	Pipelines  *v1.PipelineHandler
+	AuditLog   *v1.AuditHandler
+	Config     *v1.ConfigHandler
+	Health     *v1.HealthHandler
+
+	// MaxBodyBytes bounds request bodies after gzip decompression
+	MaxBodyBytes int64
+	// MetricsToken lets scrapers read /metrics without an API key
+	MetricsToken string
+}
+
+// scrapeAuditInterval is how often the audit trail records the requests of
+// one scraper
+const scrapeAuditInterval = 5 * time.Minute
+
 // SetupRoutes configures all the API routes
-func SetupRoutes(r *gin.Engine, logHandler *v1.LogHandler) {
-	// Health check endpoint
-	r.GET("/health", logHandler.Health)
+func SetupRoutes(r *gin.Engine, h Handlers) {
+	// Health check endpoints (unauthenticated); /health is kept for
+	// existing clients and reports readiness
+	r.GET("/livez", h.Health.Livez)
+	r.GET("/readyz", h.Health.Readyz)
+	r.GET("/health", h.Health.Readyz)
+
+	// Prometheus metrics about LogScale itself, for the scrape token or admin keys
+	r.GET("/metrics", h.Auth.ScrapeAuth(h.MetricsToken), gin.WrapH(metrics.Handler()))
+
+	authenticate := h.Auth.Middleware()
+	decompress := middleware.Decompress(h.MaxBodyBytes)
+
+	// Queries and admin changes are audited, including those denied for
+	// missing scopes
+	auditQueries := h.Audit.Queries()
+	auditMutations := h.Audit.Mutations()
+
+	// GELF HTTP input
+	r.POST("/gelf", authenticate, auth.RequireScope(auth.ScopeLogsWrite), decompress, h.GELF.CreateLog)
 
 	// API v1 routes
-	v1 := r.Group("/v1")
+	v1 := r.Group("/v1", authenticate)
 	{
 		// Logs endpoints
 		logs := v1.Group("/logs")
 		{
-			logs.POST("", logHandler.CreateLog)                       // POST /v1/logs
-			logs.GET("", pagination.Middleware(), logHandler.GetLogs) // GET /v1/logs with pagination
+			logs.POST("", auth.RequireScope(auth.ScopeLogsWrite), decompress, h.Logs.CreateLog)                        // POST /v1/logs (gzip bodies accepted)
+			logs.GET("", auditQueries, auth.RequireScope(auth.ScopeLogsRead), pagination.Middleware(), h.Logs.GetLogs) // GET /v1/logs with pagination
+		}
+
+		// Pipeline dry runs
+		v1.POST("/pipelines/dry-run", auth.RequireScope(auth.ScopeLogsWrite), h.Pipelines.DryRun)
+
+		// Usage endpoint
+		v1.GET("/usage", auditQueries, auth.RequireScope(auth.ScopeLogsRead), h.Quotas.GetUsage)
+
+		// Service metrics endpoints
+		services := v1.Group("/services", auditQueries, auth.RequireScope(auth.ScopeLogsRead))
+		{
+			services.GET("/metrics", h.Metrics.ListServiceMetrics)         // GET /v1/services/metrics
+			services.GET("/:service/metrics", h.Metrics.GetServiceMetrics) // GET /v1/services/:service/metrics
+		}
+
+		// Prometheus export of service metrics. Scrapers poll it every few
+		// seconds, so their requests are audited once per interval.
+		v1.GET("/services/metrics/prometheus", h.Audit.Coalesced(scrapeAuditInterval), auth.RequireScope(auth.ScopeLogsRead), h.Metrics.ExportServiceMetrics) // GET /v1/services/metrics/prometheus
+
+		// Audit trail endpoints
+		auditLog := v1.Group("/audit", auditQueries)
+		{
+			auditLog.GET("", auth.RequireScope(auth.ScopeAuditRead), pagination.Middleware(), h.AuditLog.ListEvents) // GET /v1/audit with pagination
+			auditLog.GET("/verify", auth.RequireScope(auth.ScopeAdmin), h.AuditLog.Verify)                           // GET /v1/audit/verify
+		}
+
+		// Admin endpoints
+		admin := v1.Group("/admin", auditMutations, auth.RequireScope(auth.ScopeAdmin))
+		{
+			admin.POST("/keys", h.APIKeys.CreateKey)
+			admin.GET("/keys", h.APIKeys.ListKeys)
+			admin.POST("/keys/:id/rotate", h.APIKeys.RotateKey)
+			admin.DELETE("/keys/:id", h.APIKeys.RevokeKey)
+			admin.PUT("/keys/:id/role", h.Roles.SetKeyRole)
+			admin.POST("/roles", h.Roles.CreateRole)
+			admin.GET("/roles", h.Roles.ListRoles)
+			admin.PUT("/roles/:id", h.Roles.UpdateRole)
+			admin.DELETE("/roles/:id", h.Roles.DeleteRole)
+			admin.GET("/rate-limits/dropped", h.RateLimits.Dropped)
+			admin.GET("/redactions", h.Redactions.Counts)
+			admin.PUT("/quotas", h.Quotas.SetQuota)
+			admin.GET("/quotas", h.Quotas.ListQuotas)
+			admin.DELETE("/quotas/:id", h.Quotas.DeleteQuota)
+			admin.GET("/config", h.Config.GetConfig)
 		}
 	}
 
@@ -28,8 +122,17 @@ func SetupRoutes(r *gin.Engine, logHandler *v1.LogHandler) {
 			"message": "LogScale API",
 			"version": "v1",
 			"endpoints": gin.H{
-				"health": "/health",
-				"logs":   "/v1/logs",
+				"health":   "/health",
+				"livez":    "/livez",
+				"readyz":   "/readyz",
+				"metrics":  "/metrics",
+				"logs":     "/v1/logs",
+				"gelf":     "/gelf",
+				"usage":    "/v1/usage",
+				"services": "/v1/services/metrics",
+				"keys":     "/v1/admin/keys",
+				"roles":    "/v1/admin/roles",
+				"audit":    "/v1/audit",
 			},
 		})
 	})
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/agent"
+	"github.com/yunjin08/logscale/middleware"
+	"github.com/yunjin08/logscale/models"
+)
+
+// logCollector is a fake /v1/logs endpoint that can be switched off
+type logCollector struct {
+	mu           sync.Mutex
+	logs         []models.LogRequest
+	down         atomic.Bool
+	unauthorized atomic.Bool
+}
+
+func (lc *logCollector) router() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/v1/logs", middleware.Decompress(1<<20), func(c *gin.Context) {
+		if lc.down.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "down"})
+			return
+		}
+		if lc.unauthorized.Load() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+		var request struct {
+			Logs []models.LogRequest `json:"logs"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		lc.mu.Lock()
+		lc.logs = append(lc.logs, request.Logs...)
+		lc.mu.Unlock()
+		c.JSON(http.StatusCreated, gin.H{"count": len(request.Logs)})
+	})
+	return r
+}
+
+func (lc *logCollector) messages() []string {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	out := make([]string, len(lc.logs))
+	for i, l := range lc.logs {
+		out[i] = l.Message
+	}
+	return out
+}
+
+func startAgent(t *testing.T, dir, endpoint string) context.CancelFunc {
+	t.Helper()
+
+	a, err := agent.New(agent.Config{
+		Paths:               []string{filepath.Join(dir, "*.log")},
+		Service:             "vm-app",
+		Endpoint:            endpoint,
+		CheckpointFile:      filepath.Join(dir, "state", "checkpoint.json"),
+		BufferDir:           filepath.Join(dir, "state", "buffer"),
+		MaxBufferBytes:      1 << 20,
+		BatchSize:           100,
+		FlushInterval:       50 * time.Millisecond,
+		PollInterval:        20 * time.Millisecond,
+		MultilineTimeout:    50 * time.Millisecond,
+		ContinuationPattern: agent.DefaultContinuationPattern,
+		MaxRetries:          0,
+		RetryBackoff:        10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, a.Run(ctx))
+	}()
+
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	defer f.Close()
+	for _, l := range lines {
+		_, err := f.WriteString(l + "\n")
+		require.NoError(t, err)
+	}
+}
+
+func TestAgentTailsMultilineAndRotation(t *testing.T) {
+	collector := &logCollector{}
+	server := httptest.NewServer(collector.router())
+	defer server.Close()
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	appendLines(t, logFile,
+		"INFO starting",
+		"ERROR request failed",
+		"java.lang.IllegalStateException: boom",
+		"    at com.example.Handler.run(Handler.java:10)",
+		"Caused by: java.io.IOException: closed",
+		"    ... 3 more",
+	)
+
+	startAgent(t, dir, server.URL)
+
+	require.Eventually(t, func() bool { return len(collector.messages()) == 3 }, 5*time.Second, 20*time.Millisecond)
+	msgs := collector.messages()
+	assert.Equal(t, "INFO starting", msgs[0])
+	assert.Equal(t, "ERROR request failed", msgs[1])
+	assert.Contains(t, msgs[2], "Caused by: java.io.IOException: closed")
+	assert.Contains(t, msgs[2], "... 3 more")
+
+	// Rotate: rename the file and start a new one at the same path
+	require.NoError(t, os.Rename(logFile, filepath.Join(dir, "app.log.1")))
+	appendLines(t, logFile, "WARN after rotation")
+	require.Eventually(t, func() bool { return len(collector.messages()) == 4 }, 5*time.Second, 20*time.Millisecond)
+
+	// Truncate in place and write again
+	require.NoError(t, os.Truncate(logFile, 0))
+	time.Sleep(100 * time.Millisecond)
+	appendLines(t, logFile, "after truncate")
+	require.Eventually(t, func() bool { return len(collector.messages()) == 5 }, 5*time.Second, 20*time.Millisecond)
+
+	msgs = collector.messages()
+	assert.Equal(t, "WARN after rotation", msgs[3])
+	assert.Equal(t, "after truncate", msgs[4])
+}
+
+func TestAgentBuffersWhileAPIDownAndResumesFromCheckpoint(t *testing.T) {
+	collector := &logCollector{}
+	collector.down.Store(true)
+	server := httptest.NewServer(collector.router())
+	defer server.Close()
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	appendLines(t, logFile, "one", "two")
+
+	stop := startAgent(t, dir, server.URL)
+
+	// Batches land in the on-disk buffer while the API is down
+	require.Eventually(t, func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, "state", "buffer", "*.json.gz"))
+		return len(files) > 0
+	}, 5*time.Second, 20*time.Millisecond)
+	stop()
+
+	// Restart: buffered logs are replayed and already-read lines are not re-sent
+	collector.down.Store(false)
+	appendLines(t, logFile, "three")
+	startAgent(t, dir, server.URL)
+
+	require.Eventually(t, func() bool { return len(collector.messages()) == 3 }, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, []string{"one", "two", "three"}, collector.messages())
+}
+
+func TestAgentKeepsBatchesRejectedForTheAPIKey(t *testing.T) {
+	collector := &logCollector{}
+	collector.unauthorized.Store(true)
+	server := httptest.NewServer(collector.router())
+	defer server.Close()
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	appendLines(t, logFile, "one", "two")
+
+	startAgent(t, dir, server.URL)
+
+	// A rejected key buffers the batch rather than dropping it
+	require.Eventually(t, func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, "state", "buffer", "*.json.gz"))
+		return len(files) > 0
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Empty(t, collector.messages())
+
+	// Once the key is accepted again the buffered batch is delivered
+	collector.unauthorized.Store(false)
+	require.Eventually(t, func() bool { return len(collector.messages()) == 2 }, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{"one", "two"}, collector.messages())
+}
+
+func TestAgentDetectLevel(t *testing.T) {
+	assert.Equal(t, "error", agent.DetectLevel("2024-01-15 ERROR payment failed"))
+	assert.Equal(t, "warn", agent.DetectLevel("[warning] disk almost full"))
+	assert.Equal(t, "trace", agent.DetectLevel("TRACE entering handler"))
+	assert.Equal(t, "fatal", agent.DetectLevel("panic: runtime error"))
+	assert.Equal(t, "info", agent.DetectLevel("plain line"))
+	assert.Equal(t, "info", agent.DetectLevel("INFO ok\nERROR in second line is ignored"))
+}
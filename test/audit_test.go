@@ -0,0 +1,249 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/internal/audit"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/models"
+	"github.com/yunjin08/logscale/pkg/pagination"
+)
+
+// fakeAuditStore chains events in memory the way AuditHelper does
+type fakeAuditStore struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func (f *fakeAuditStore) AppendAuditEvent(_ context.Context, event models.AuditEvent) (*models.AuditEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	event.ID = int64(len(f.events) + 1)
+	event.PrevHash = models.GenesisHash
+	if len(f.events) > 0 {
+		event.PrevHash = f.events[len(f.events)-1].Hash
+	}
+	event.Hash = event.ComputeHash()
+	f.events = append(f.events, event)
+	return &event, nil
+}
+
+func (f *fakeAuditStore) QueryAuditEvents(_ context.Context, query models.AuditQuery, _ pagination.Pagination) ([]models.AuditEvent, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var events []models.AuditEvent
+	for _, e := range f.events {
+		if query.TenantID == "" || e.TenantID == query.TenantID {
+			events = append(events, e)
+		}
+	}
+	return events, int64(len(events)), nil
+}
+
+func (f *fakeAuditStore) ForEachAuditEvent(_ context.Context, fn func(models.AuditEvent) bool) error {
+	f.mu.Lock()
+	events := append([]models.AuditEvent(nil), f.events...)
+	f.mu.Unlock()
+	for _, e := range events {
+		if !fn(e) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestAuditMiddlewareRecordsQueriesAndMutations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &fakeAuditStore{}
+	svc := audit.NewService(store)
+
+	principal := func(c *gin.Context) {
+		auth.SetPrincipal(c, &auth.Principal{KeyID: 7, TenantID: "billing", Name: "analyst", Scopes: []auth.Scope{auth.ScopeLogsRead}})
+	}
+	r := gin.New()
+	r.GET("/v1/logs", principal, svc.Queries(), func(c *gin.Context) {
+		audit.AddDetail(c, "result_count", 3)
+		c.Status(http.StatusOK)
+	})
+	admin := r.Group("/v1/admin", principal, svc.Mutations(), auth.RequireScope(auth.ScopeAdmin))
+	admin.GET("/keys", func(c *gin.Context) { c.Status(http.StatusOK) })
+	admin.POST("/keys", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/logs?service=api&level=error", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/admin/keys", nil))
+	post := httptest.NewRequest(http.MethodPost, "/v1/admin/keys", strings.NewReader(`{"name":"ci","scopes":["admin"]}`))
+	post.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), post)
+
+	// Events are written in the background; closing waits for them
+	require.NoError(t, svc.Close(context.Background()))
+	require.Len(t, store.events, 2, "admin reads are not audited")
+
+	query := store.events[0]
+	assert.Equal(t, "GET /v1/logs", query.Action)
+	assert.Equal(t, "billing", query.TenantID)
+	assert.Equal(t, "analyst", query.Actor)
+	require.NotNil(t, query.ActorKeyID)
+	assert.Equal(t, int64(7), *query.ActorKeyID)
+	assert.Equal(t, http.StatusOK, query.Status)
+	var details map[string]interface{}
+	require.NoError(t, json.Unmarshal(query.Details, &details))
+	assert.Equal(t, float64(3), details["result_count"])
+	assert.Equal(t, map[string]interface{}{"service": "api", "level": "error"}, details["query"])
+
+	// The denied mutation is recorded with its body
+	mutation := store.events[1]
+	assert.Equal(t, "POST /v1/admin/keys", mutation.Action)
+	assert.Equal(t, http.StatusForbidden, mutation.Status)
+	require.NoError(t, json.Unmarshal(mutation.Details, &details))
+	assert.Equal(t, map[string]interface{}{"name": "ci", "scopes": []interface{}{"admin"}}, details["request"])
+}
+
+func TestAuditMiddlewareKeepsBodyForHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := audit.NewService(&fakeAuditStore{})
+
+	var got map[string]string
+	r := gin.New()
+	r.PUT("/quotas", svc.Mutations(), func(c *gin.Context) {
+		require.NoError(t, c.ShouldBindJSON(&got))
+		c.Status(http.StatusOK)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/quotas", strings.NewReader(`{"tenant_id":"a"}`)))
+	assert.Equal(t, map[string]string{"tenant_id": "a"}, got)
+}
+
+func TestAuditVerifyDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeAuditStore{}
+	svc := audit.NewService(store)
+
+	for i := 0; i < 3; i++ {
+		svc.Record(ctx, models.AuditEvent{
+			TenantID:   "default",
+			OccurredAt: time.Date(2024, 1, 15, 10, 30, i, 0, time.UTC),
+			Actor:      "admin",
+			Action:     "DELETE /v1/admin/keys/:id",
+			Resource:   "/v1/admin/keys/1",
+			Status:     http.StatusOK,
+			Details:    json.RawMessage(`{"client_ip": "10.0.0.1", "n": 1}`),
+		})
+	}
+
+	result, err := svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, int64(3), result.Checked)
+	assert.Equal(t, store.events[2].Hash, result.LastHash)
+
+	// JSONB reorders keys and drops whitespace; the hash must not change
+	store.events[1].Details = json.RawMessage(`{"n":1,"client_ip":"10.0.0.1"}`)
+	result, err = svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+
+	store.events[1].Status = http.StatusForbidden
+	result, err = svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	require.NotNil(t, result.BrokenAt)
+	assert.Equal(t, int64(2), *result.BrokenAt)
+
+	// Removing an event breaks the link of its successor
+	store.events[1].Status = http.StatusOK
+	store.events = append(store.events[:1], store.events[2:]...)
+	result, err = svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, int64(3), *result.BrokenAt)
+}
+
+func TestAuditListEventsIsScopedToTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := &fakeAuditStore{}
+	svc := audit.NewService(store)
+	for _, tenant := range []string{"default", "billing", "payments"} {
+		svc.Record(ctx, models.AuditEvent{TenantID: tenant, OccurredAt: time.Now(), Actor: "admin", Action: "GET /v1/logs", Status: http.StatusOK})
+	}
+	h := v1.NewAuditHandler(svc)
+
+	list := func(p *auth.Principal, query string) []models.AuditEvent {
+		r := gin.New()
+		r.GET("/v1/audit", func(c *gin.Context) { auth.SetPrincipal(c, p) }, pagination.Middleware(), h.ListEvents)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/audit"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data []models.AuditEvent `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	// Admin keys, even of the default tenant, only read their own tenant
+	admin := &auth.Principal{KeyID: 3, TenantID: "billing", Name: "billing-admin", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	events := list(admin, "?tenant_id=payments")
+	require.Len(t, events, 1)
+	assert.Equal(t, "billing", events[0].TenantID)
+	global := &auth.Principal{KeyID: 4, TenantID: "default", Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	assert.Len(t, list(global, ""), 1)
+
+	// The bootstrap key reads and filters every tenant
+	bootstrap := &auth.Principal{TenantID: "default", Name: "bootstrap", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	assert.Len(t, list(bootstrap, ""), 3)
+	events = list(bootstrap, "?tenant_id=payments")
+	require.Len(t, events, 1)
+	assert.Equal(t, "payments", events[0].TenantID)
+}
+
+func TestAuditCoalescesScrapes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &fakeAuditStore{}
+	svc := audit.NewService(store)
+
+	scraper := func(keyID int64) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			auth.SetPrincipal(c, &auth.Principal{KeyID: keyID, TenantID: "default", Name: "prometheus", Scopes: []auth.Scope{auth.ScopeLogsRead}})
+		}
+	}
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/a/prometheus", scraper(1), svc.Coalesced(50*time.Millisecond), ok)
+	r.GET("/b/prometheus", scraper(2), svc.Coalesced(50*time.Millisecond), ok)
+	scrape := func(path string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Every scraper is recorded once per interval
+	for i := 0; i < 3; i++ {
+		scrape("/a/prometheus")
+	}
+	scrape("/b/prometheus")
+	time.Sleep(60 * time.Millisecond)
+	scrape("/a/prometheus")
+
+	require.NoError(t, svc.Close(context.Background()))
+	require.Len(t, store.events, 3)
+	assert.Equal(t, "/a/prometheus", store.events[0].Resource)
+	assert.Equal(t, "/b/prometheus", store.events[1].Resource)
+
+	// The next event counts the scrapes left out
+	var details map[string]interface{}
+	require.NoError(t, json.Unmarshal(store.events[2].Details, &details))
+	assert.Equal(t, "/a/prometheus", store.events[2].Resource)
+	assert.Equal(t, float64(2), details["coalesced"])
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/models"
+)
+
+func TestGenerateAndParseToken(t *testing.T) {
+	token, prefix, hash, err := auth.GenerateToken()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "lsk_"+prefix+"_"))
+
+	gotPrefix, secret, ok := auth.ParseToken(token)
+	require.True(t, ok)
+	assert.Equal(t, prefix, gotPrefix)
+	assert.Equal(t, hash, auth.HashSecret(secret))
+	assert.NotContains(t, hash, secret)
+
+	for _, bad := range []string{"", "lsk_abc", "xyz_abc_def", "lsk__def", "lsk_abc_def_ghi"} {
+		_, _, ok := auth.ParseToken(bad)
+		assert.False(t, ok, bad)
+	}
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	writer := &auth.Principal{Scopes: []auth.Scope{auth.ScopeLogsWrite}}
+	assert.True(t, writer.HasScope(auth.ScopeLogsWrite))
+	assert.False(t, writer.HasScope(auth.ScopeLogsRead))
+
+	admin := &auth.Principal{Scopes: []auth.Scope{auth.ScopeAdmin}}
+	assert.True(t, admin.HasScope(auth.ScopeLogsRead))
+
+	_, err := auth.ParseScopes([]string{"logs:read", "logs:delete"})
+	assert.Error(t, err)
+}
+
+func authRouter(svc *auth.Service) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/read", svc.Middleware(), auth.RequireScope(auth.ScopeLogsRead), func(c *gin.Context) {
+		c.JSON(http.StatusOK, auth.PrincipalFromContext(c))
+	})
+	return r
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	r := authRouter(auth.NewService(nil, nil, "bootstrap-secret", true))
+
+	tests := []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Basic Zm9vOmJhcg==", http.StatusUnauthorized},
+		{"Bearer not-a-key", http.StatusUnauthorized},
+		{"Bearer bootstrap-secret", http.StatusOK},
+		{"bearer bootstrap-secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/read", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.header)
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	r := authRouter(auth.NewService(nil, nil, "", false))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/read", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAuthScrapeToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scrape := func(svc *auth.Service, token, header string) int {
+		r := gin.New()
+		r.GET("/metrics", svc.ScrapeAuth(token), func(c *gin.Context) { c.Status(http.StatusOK) })
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	svc := auth.NewService(&fakeKeyStore{}, nil, "bootstrap-secret", true)
+
+	assert.Equal(t, http.StatusUnauthorized, scrape(svc, "scrape-secret", ""))
+	assert.Equal(t, http.StatusUnauthorized, scrape(svc, "scrape-secret", "Bearer wrong"))
+	assert.Equal(t, http.StatusOK, scrape(svc, "scrape-secret", "Bearer scrape-secret"))
+	assert.Equal(t, http.StatusOK, scrape(svc, "scrape-secret", "Bearer bootstrap-secret"))
+
+	// Without a scrape token only admin keys get in
+	assert.Equal(t, http.StatusUnauthorized, scrape(svc, "", ""))
+	assert.Equal(t, http.StatusUnauthorized, scrape(svc, "", "Bearer scrape-secret"))
+	assert.Equal(t, http.StatusOK, scrape(svc, "", "Bearer bootstrap-secret"))
+}
+
+func TestRequireScopeForbidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin", func(c *gin.Context) {
+		auth.SetPrincipal(c, &auth.Principal{Scopes: []auth.Scope{auth.ScopeLogsWrite}})
+	}, auth.RequireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestTenantFromContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	assert.Equal(t, models.DefaultTenant, auth.TenantFromContext(c))
+
+	auth.SetPrincipal(c, &auth.Principal{TenantID: "billing", Scopes: []auth.Scope{auth.ScopeLogsRead}})
+	assert.Equal(t, "billing", auth.TenantFromContext(c))
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/models"
+	"github.com/yunjin08/logscale/pkg/client"
+)
+
+func TestClientCreateAndQueryLogs(t *testing.T) {
+	server := httptest.NewServer(SetupTestRouter())
+	defer server.Close()
+
+	c := client.New(server.URL)
+	ctx := context.Background()
+
+	log, err := c.CreateLog(ctx, CreateTestLogRequest("test-service", "info", "single"))
+	require.NoError(t, err)
+	assert.Equal(t, "single", log.Message)
+
+	logs, err := c.CreateLogs(ctx, []models.LogRequest{
+		CreateTestLogRequest("service-1", "info", "first"),
+		CreateTestLogRequest("service-2", "error", "second"),
+	})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "service-2", logs[1].Service)
+
+	page, err := c.QueryLogs(ctx, models.LogQuery{Service: "test-service"}, 1, 50)
+	require.NoError(t, err)
+	require.Len(t, page.Logs, 1)
+	assert.Equal(t, "test-service", page.Logs[0].Service)
+	assert.Equal(t, int64(1), page.Pagination.Total)
+
+	health, err := c.Health(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "healthy", health.Status)
+}
+
+func TestClientAPIError(t *testing.T) {
+	server := httptest.NewServer(SetupTestRouter())
+	defer server.Close()
+
+	_, err := client.New(server.URL).CreateLogs(context.Background(), nil)
+
+	var apiErr *client.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "No logs provided", apiErr.Message)
+}
+
+func TestSlogHandlerBatchesRecords(t *testing.T) {
+	collector := &logCollector{}
+	server := httptest.NewServer(collector.router())
+	defer server.Close()
+
+	handler := client.NewHandler(client.New(server.URL), "checkout", &client.HandlerOptions{
+		Level:         slog.LevelDebug,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+	logger := slog.New(handler).With("region", "eu-west-1").WithGroup("http")
+
+	logger.Debug("cache miss", "path", "/cart")
+	logger.Warn("slow request", "duration", 1500*time.Millisecond)
+	logger.Error("payment failed", "err", errors.New("card declined"), slog.Group("user", "id", 42))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, handler.Close(ctx))
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	require.Len(t, collector.logs, 3)
+
+	assert.Equal(t, "checkout", collector.logs[0].Service)
+	assert.Equal(t, "debug", collector.logs[0].Level)
+	assert.Equal(t, "warn", collector.logs[1].Level)
+	assert.Equal(t, "error", collector.logs[2].Level)
+	assert.Equal(t, "payment failed", collector.logs[2].Message)
+
+	var meta map[string]interface{}
+	require.NoError(t, json.Unmarshal(collector.logs[2].Meta, &meta))
+	assert.Equal(t, "eu-west-1", meta["region"])
+	httpGroup := meta["http"].(map[string]interface{})
+	assert.Equal(t, "card declined", httpGroup["err"])
+	assert.Equal(t, float64(42), httpGroup["user"].(map[string]interface{})["id"])
+
+	require.NoError(t, json.Unmarshal(collector.logs[1].Meta, &meta))
+	assert.Equal(t, "1.5s", meta["http"].(map[string]interface{})["duration"])
+}
+
+func TestSlogHandlerLevelFilter(t *testing.T) {
+	handler := client.NewHandler(client.New("http://127.0.0.1:0"), "svc", nil)
+	defer handler.Close(context.Background())
+
+	assert.False(t, handler.Enabled(context.Background(), slog.LevelDebug))
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelInfo))
+	assert.Equal(t, "fatal", client.LevelName(slog.LevelError+4))
+	assert.Equal(t, "trace", client.LevelName(slog.LevelDebug-4))
+}
+
+func TestSlogHandlerFlushRacingClose(t *testing.T) {
+	collector := &logCollector{}
+	server := httptest.NewServer(collector.router())
+	defer server.Close()
+
+	for i := 0; i < 50; i++ {
+		handler := client.NewHandler(client.New(server.URL), "checkout", &client.HandlerOptions{
+			BatchSize:     100,
+			FlushInterval: time.Hour,
+		})
+		logger := slog.New(handler)
+		for j := 0; j < 20; j++ {
+			logger.Info("order placed")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		flushed := make(chan struct{})
+		go func() {
+			defer close(flushed)
+			_ = handler.Flush(ctx)
+		}()
+		require.NoError(t, handler.Close(ctx))
+		<-flushed
+		cancel()
+	}
+
+	// Closing mid-flush sends every record once and no empty ones
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	require.Len(t, collector.logs, 50*20)
+	for _, l := range collector.logs {
+		assert.Equal(t, "order placed", l.Message)
+	}
+}
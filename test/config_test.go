@@ -0,0 +1,230 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/config"
+	"github.com/yunjin08/logscale/internal/pipeline"
+	"github.com/yunjin08/logscale/internal/ratelimit"
+	"github.com/yunjin08/logscale/models"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+database:
+  url: postgres://file/logscale
+  max_conns: 20
+stream:
+  name: file:logs
+  batch_size: 50
+server:
+  read_timeout: 5s
+rate_limits:
+  per_key: {rate: 2.5}
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("STREAM_NAME", "env:logs")
+	t.Setenv("STREAM_BATCH_SIZE", "100")
+	t.Setenv("DATABASE_URL", "")
+
+	cfg, err := config.Load("test", []string{"-stream.batch_size", "200", "-server.write_timeout=90s"})
+	require.NoError(t, err)
+
+	// File over defaults, empty env vars ignored
+	assert.Equal(t, "postgres://file/logscale", cfg.Database.URL)
+	assert.Equal(t, 20, cfg.Database.MaxConns)
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "logscale-workers", cfg.Stream.ConsumerGroup)
+	// Env over file, flags over env
+	assert.Equal(t, "env:logs", cfg.Stream.StreamName)
+	assert.Equal(t, 200, cfg.Stream.BatchSize)
+	assert.Equal(t, 90*time.Second, cfg.Server.WriteTimeout)
+	// Bursts default to one second of logs
+	assert.Equal(t, 3, cfg.RateLimits.PerKey.Burst)
+
+	// -config wins over CONFIG_FILE
+	other := writeConfig(t, "database: {url: postgres://other/logscale}\n")
+	cfg, err = config.Load("test", []string{"-config", other})
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://other/logscale", cfg.Database.URL)
+	assert.Equal(t, 0, cfg.Database.MaxConns)
+	assert.Equal(t, 100, cfg.Stream.BatchSize)
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv("DATABASE_URL", "postgres://localhost/logscale")
+
+	_, err := config.Load("test", nil)
+	require.NoError(t, err)
+
+	for name, args := range map[string][]string{
+		"unknown level policy": {"-ingest.unknown_levels", "ignore"},
+		"zero batch size":      {"-stream.batch_size", "0"},
+		"cert without key":     {"-server.tls.cert_file", "tls.crt"},
+		"bad client auth":      {"-server.tls.client_auth", "maybe"},
+		"invalid tenant":       {"-inputs.gelf_udp.tenant", "Not A Tenant"},
+		"pool sizes":           {"-database.max_conns", "2", "-database.min_conns", "5"},
+		"unknown flag":         {"-stream.size", "5"},
+	} {
+		_, err := config.Load("test", args)
+		assert.Error(t, err, name)
+	}
+
+	t.Setenv("STREAM_BLOCK_TIMEOUT", "soon")
+	_, err = config.Load("test", nil)
+	assert.ErrorContains(t, err, "STREAM_BLOCK_TIMEOUT")
+}
+
+func TestConfigFileRejectsUnknownKeys(t *testing.T) {
+	_, err := config.LoadFile(writeConfig(t, "stream:\n  nmae: typo\n"))
+	assert.Error(t, err)
+
+	path := writeConfig(t, "")
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+	want := config.Default()
+	want.File = path
+	assert.Equal(t, want, cfg)
+}
+
+func TestShippedConfigLoads(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "../config/config.yaml")
+	t.Setenv("DATABASE_URL", "postgres://localhost/logscale")
+
+	cfg, err := config.Load("test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "logscale:logs", cfg.Stream.StreamName)
+}
+
+const reloadConfig = `
+database: {url: "postgres://logscale:hunter2@db:5432/logscale"}
+auth: {admin_api_key: bootstrap-secret}
+rate_limits:
+  per_key: {rate: %d}
+pipelines:
+  - name: tag
+    processors:
+      - enrich: {fields: {meta.version: "%d"}}
+`
+
+func TestConfigReload(t *testing.T) {
+	path := writeConfig(t, fmt.Sprintf(reloadConfig, 0, 1))
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := config.Load("test", nil)
+	require.NoError(t, err)
+	limiter := ratelimit.New(cfg.RateLimits, nil)
+	runner, err := pipeline.New(cfg.Pipelines)
+	require.NoError(t, err)
+
+	reloader := config.NewReloader("test", nil, cfg)
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		return func() { limiter.SetConfig(next.RateLimits) }, nil
+	})
+	reloader.OnReload(func(next *config.Config) (func(), error) {
+		r, err := pipeline.New(next.Pipelines)
+		if err != nil {
+			return nil, err
+		}
+		return func() { runner.Replace(r) }, nil
+	})
+
+	version := func() string {
+		req := models.LogRequest{Service: "api", Level: "info", Message: "hello"}
+		_, err := runner.Process(&req)
+		require.NoError(t, err)
+		var meta map[string]string
+		require.NoError(t, json.Unmarshal(req.Meta, &meta))
+		return meta["version"]
+	}
+	assert.False(t, limiter.Enabled())
+	assert.Equal(t, "1", version())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	// A changed file is picked up; sections read at startup keep their values
+	changed := fmt.Sprintf(reloadConfig, 50, 2) + "server: {addr: \":9090\"}\n"
+	require.NoError(t, os.WriteFile(path, []byte(changed), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool { return version() == "2" }, 2*time.Second, 10*time.Millisecond)
+	assert.True(t, limiter.Enabled())
+	assert.Equal(t, 50, reloader.Current().RateLimits.PerKey.Burst)
+	assert.Equal(t, ":8080", reloader.Current().Server.Addr)
+
+	// An invalid config is rejected as a whole
+	invalid := fmt.Sprintf(reloadConfig, 0, 3) + "    - grok: {pattern: '%{NOPE:x}'}\n"
+	require.NoError(t, os.WriteFile(path, []byte(invalid), 0o600))
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, "2", version())
+	assert.True(t, limiter.Enabled())
+	assert.Equal(t, 50, reloader.Current().RateLimits.PerKey.Burst)
+}
+
+func TestConfigEndpointMasksSecrets(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfig(t, fmt.Sprintf(reloadConfig, 10, 1)))
+	t.Setenv("REDIS_URL", "redis://:redis-secret@redis:6379/0")
+	t.Setenv("REDACTION_HASH_KEY", "hash-secret")
+	t.Setenv("ARCHIVE_S3_SECRET_ACCESS_KEY", "s3-secret")
+
+	cfg, err := config.Load("test", nil)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	h := v1.NewConfigHandler(config.NewReloader("test", nil, cfg))
+	get := func(tenant string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.GET("/v1/admin/config", func(c *gin.Context) {
+			auth.SetPrincipal(c, &auth.Principal{KeyID: 1, TenantID: tenant, Scopes: []auth.Scope{auth.ScopeAdmin}})
+		}, h.GetConfig)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/config", nil))
+		return w
+	}
+
+	// The config covers every tenant, so admins of one tenant cannot read it
+	assert.Equal(t, http.StatusForbidden, get("billing").Code)
+	w := get(models.DefaultTenant)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	for _, secret := range []string{"hunter2", "bootstrap-secret", "redis-secret", "hash-secret", "s3-secret"} {
+		assert.NotContains(t, body, secret)
+	}
+
+	var resp struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	section := func(name string) map[string]interface{} {
+		var values map[string]interface{}
+		require.NoError(t, json.Unmarshal(resp.Config[name], &values))
+		return values
+	}
+	assert.Equal(t, "postgres://logscale:xxxxx@db:5432/logscale", section("database")["url"])
+	assert.Equal(t, "[REDACTED]", section("auth")["admin_api_key"])
+	assert.Equal(t, "30s", section("server")["read_timeout"])
+	assert.Equal(t, "logscale:logs", section("stream")["name"])
+}
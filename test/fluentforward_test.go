@@ -0,0 +1,229 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/yunjin08/logscale/internal/ingest"
+	"github.com/yunjin08/logscale/internal/input/fluentforward"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/models"
+)
+
+// fakeIngester records every batch handed to it, or fails with err
+type fakeIngester struct {
+	mu      sync.Mutex
+	batches [][]models.LogRequest
+	err     error
+}
+
+func (f *fakeIngester) Ingest(_ context.Context, _ string, reqs []models.LogRequest) ([]models.Log, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.batches = append(f.batches, reqs)
+	return make([]models.Log, len(reqs)), nil
+}
+
+func (f *fakeIngester) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeIngester) all() []models.LogRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []models.LogRequest
+	for _, b := range f.batches {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func startForwardServer(t *testing.T) (net.Conn, *fakeIngester) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ingester := &fakeIngester{}
+	srv := fluentforward.NewServer(ln.Addr().String(), models.DefaultTenant, ingester)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = srv.Serve(ctx, ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+		<-done
+	})
+	return conn, ingester
+}
+
+func sendAndAwaitAck(t *testing.T, conn net.Conn, msg []interface{}, chunk string) {
+	t.Helper()
+
+	require.NoError(t, msgpack.NewEncoder(conn).Encode(msg))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var ack map[string]string
+	require.NoError(t, msgpack.NewDecoder(conn).Decode(&ack))
+	assert.Equal(t, chunk, ack["ack"])
+}
+
+func TestFluentForwardMessageMode(t *testing.T) {
+	conn, ingester := startForwardServer(t)
+
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC)
+	record := map[string]interface{}{
+		"log":       "user logged in",
+		"level":     "warn",
+		"pod":       "api-7f9c",
+		"namespace": "prod",
+	}
+
+	sendAndAwaitAck(t, conn, []interface{}{
+		"kube.api", &fluentforward.EventTime{Time: ts}, record, map[string]string{"chunk": "c1"},
+	}, "c1")
+
+	logs := ingester.all()
+	require.Len(t, logs, 1)
+	assert.Equal(t, "kube.api", logs[0].Service)
+	assert.Equal(t, "warn", logs[0].Level)
+	assert.Equal(t, "user logged in", logs[0].Message)
+	require.NotNil(t, logs[0].Timestamp)
+	assert.True(t, ts.Equal(*logs[0].Timestamp))
+
+	var meta map[string]interface{}
+	require.NoError(t, json.Unmarshal(logs[0].Meta, &meta))
+	assert.Equal(t, "api-7f9c", meta["pod"])
+	assert.Equal(t, "kube.api", meta["fluent_tag"])
+	assert.NotContains(t, meta, "log")
+}
+
+func TestFluentForwardForwardMode(t *testing.T) {
+	conn, ingester := startForwardServer(t)
+
+	entries := []interface{}{
+		[]interface{}{int64(1705314600), map[string]interface{}{"service": "billing", "message": "first"}},
+		[]interface{}{int64(1705314601), map[string]interface{}{"service": "billing", "message": "second"}},
+	}
+	sendAndAwaitAck(t, conn, []interface{}{"app", entries, map[string]interface{}{"chunk": "c2", "size": 2}}, "c2")
+
+	logs := ingester.all()
+	require.Len(t, logs, 2)
+	assert.Equal(t, "billing", logs[0].Service)
+	assert.Equal(t, "info", logs[0].Level)
+	assert.Equal(t, "second", logs[1].Message)
+}
+
+func packEntries(t *testing.T, n int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for i := 0; i < n; i++ {
+		require.NoError(t, enc.Encode([]interface{}{
+			&fluentforward.EventTime{Time: time.Now()},
+			map[string]interface{}{"msg": "packed", "level": "error"},
+		}))
+	}
+	return buf.Bytes()
+}
+
+func TestFluentForwardPackedForwardMode(t *testing.T) {
+	conn, ingester := startForwardServer(t)
+
+	sendAndAwaitAck(t, conn, []interface{}{"packed", packEntries(t, 3), map[string]interface{}{"chunk": "c3"}}, "c3")
+
+	logs := ingester.all()
+	require.Len(t, logs, 3)
+	assert.Equal(t, "packed", logs[2].Service)
+	assert.Equal(t, "error", logs[2].Level)
+}
+
+func TestFluentForwardCompressedPackedForwardMode(t *testing.T) {
+	conn, ingester := startForwardServer(t)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write(packEntries(t, 2))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	sendAndAwaitAck(t, conn, []interface{}{
+		"compressed", gz.Bytes(), map[string]interface{}{"chunk": "c4", "compressed": "gzip"},
+	}, "c4")
+
+	assert.Len(t, ingester.all(), 2)
+}
+
+// awaitClose asserts that the server closes conn without acknowledging
+func awaitClose(t *testing.T, conn net.Conn) {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err := conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestFluentForwardRejectsOversizedCompressedChunk(t *testing.T) {
+	conn, ingester := startForwardServer(t)
+
+	// A few KiB inflating past the message limit
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	require.NoError(t, msgpack.NewEncoder(w).Encode([]interface{}{
+		int64(1705314600), map[string]interface{}{"message": strings.Repeat("a", 32<<20)},
+	}))
+	require.NoError(t, w.Close())
+	require.Less(t, gz.Len(), 1<<20)
+
+	require.NoError(t, msgpack.NewEncoder(conn).Encode([]interface{}{
+		"compressed", gz.Bytes(), map[string]interface{}{"chunk": "c5", "compressed": "gzip"},
+	}))
+	awaitClose(t, conn)
+	assert.Empty(t, ingester.all())
+}
+
+func TestFluentForwardAcksRejectedChunks(t *testing.T) {
+	entries := []interface{}{[]interface{}{int64(1705314600), map[string]interface{}{"level": "bogus"}}}
+
+	// Rejected logs would be rejected again, so the chunk is dropped
+	for _, err := range []error{
+		fmt.Errorf("log 0: %w: unknown level", ingest.ErrInvalidLog),
+		&quota.ExceededError{RetryAfter: time.Hour},
+	} {
+		conn, ingester := startForwardServer(t)
+		ingester.fail(err)
+		sendAndAwaitAck(t, conn, []interface{}{"app", entries, map[string]interface{}{"chunk": "c6"}}, "c6")
+	}
+
+	// Store errors leave the chunk unacknowledged for the client to retry
+	conn, ingester := startForwardServer(t)
+	ingester.fail(errors.New("connection refused"))
+	require.NoError(t, msgpack.NewEncoder(conn).Encode([]interface{}{"app", entries, map[string]interface{}{"chunk": "c7"}}))
+	awaitClose(t, conn)
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/input/gelf"
+	"github.com/yunjin08/logscale/models"
+)
+
+const sampleGELF = `{
+	"version": "1.1",
+	"host": "payments-01",
+	"short_message": "Payment declined",
+	"full_message": "Payment declined\nat com.example.Pay.run(Pay.java:42)",
+	"timestamp": 1705314600.250,
+	"level": 3,
+	"_service": "payment-service",
+	"_transaction_id": "tx_123",
+	"_id": "ignored"
+}`
+
+func TestGELFParse(t *testing.T) {
+	req, err := gelf.Parse([]byte(sampleGELF))
+	require.NoError(t, err)
+
+	assert.Equal(t, "payment-service", req.Service)
+	assert.Equal(t, "error", req.Level)
+	assert.Equal(t, "Payment declined", req.Message)
+	require.NotNil(t, req.Timestamp)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 30, 0, 250000000, time.UTC), *req.Timestamp)
+
+	var meta map[string]interface{}
+	require.NoError(t, json.Unmarshal(req.Meta, &meta))
+	assert.Equal(t, "payments-01", meta["host"])
+	assert.Equal(t, "tx_123", meta["transaction_id"])
+	assert.Contains(t, meta["full_message"], "Pay.java:42")
+	assert.NotContains(t, meta, "id")
+	assert.NotContains(t, meta, "service")
+}
+
+func TestGELFParseLevels(t *testing.T) {
+	tests := map[string]string{
+		`{"host":"h","short_message":"m","level":0}`: "fatal",
+		`{"host":"h","short_message":"m","level":3}`: "error",
+		`{"host":"h","short_message":"m","level":4}`: "warn",
+		`{"host":"h","short_message":"m","level":5}`: "info",
+		`{"host":"h","short_message":"m","level":7}`: "debug",
+		`{"host":"h","short_message":"m"}`:           "info",
+	}
+
+	for payload, level := range tests {
+		req, err := gelf.Parse([]byte(payload))
+		require.NoError(t, err, payload)
+		assert.Equal(t, level, req.Level, payload)
+		assert.Equal(t, "h", req.Service, payload)
+	}
+
+	_, err := gelf.Parse([]byte(`{"host":"h","short_message":"m","level":9}`))
+	assert.Error(t, err)
+	_, err = gelf.Parse([]byte(`{"host":"h"}`))
+	assert.Error(t, err)
+}
+
+func TestGELFParseCompressed(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write([]byte(sampleGELF))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	var zl bytes.Buffer
+	zw := zlib.NewWriter(&zl)
+	_, err = zw.Write([]byte(sampleGELF))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	for _, payload := range [][]byte{gz.Bytes(), zl.Bytes()} {
+		req, err := gelf.Parse(payload)
+		require.NoError(t, err)
+		assert.Equal(t, "payment-service", req.Service)
+	}
+}
+
+// fakeSingleIngester captures logs ingested one at a time
+type fakeSingleIngester struct {
+	received chan models.LogRequest
+}
+
+func (f *fakeSingleIngester) IngestOne(_ context.Context, _ string, req models.LogRequest) (*models.Log, error) {
+	f.received <- req
+	return &models.Log{}, nil
+}
+
+func TestGELFUDPChunked(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ingester := &fakeSingleIngester{received: make(chan models.LogRequest, 1)}
+	srv := gelf.NewServer(conn.LocalAddr().String(), models.DefaultTenant, ingester)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Serve(ctx, conn) }()
+
+	var payload bytes.Buffer
+	zw := zlib.NewWriter(&payload)
+	_, err = zw.Write([]byte(sampleGELF))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	// Split into three chunks and send them out of order
+	data := payload.Bytes()
+	size := len(data)/3 + 1
+	var chunks [][]byte
+	for i := 0; i*size < len(data); i++ {
+		end := min((i+1)*size, len(data))
+		header := []byte{0x1e, 0x0f, 1, 2, 3, 4, 5, 6, 7, 8, byte(i), 3}
+		chunks = append(chunks, append(header, data[i*size:end]...))
+	}
+	require.Len(t, chunks, 3)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	for _, i := range []int{2, 0, 1} {
+		_, err := client.Write(chunks[i])
+		require.NoError(t, err)
+	}
+
+	select {
+	case req := <-ingester.received:
+		assert.Equal(t, "payment-service", req.Service)
+		assert.Equal(t, "Payment declined", req.Message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("chunked GELF message was not ingested")
+	}
+}
+
+func TestGELFUDPBoundsPendingChunks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ingester := &fakeSingleIngester{received: make(chan models.LogRequest, 1)}
+	srv := gelf.NewServer(conn.LocalAddr().String(), models.DefaultTenant, ingester)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Serve(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	chunk := func(id uint32, seq, count byte, data []byte) []byte {
+		header := []byte{0x1e, 0x0f, 0, 0, 0, 0, byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id), seq, count}
+		return append(header, data...)
+	}
+	// A plain message answered once every datagram before it was handled
+	received := func(message string) models.LogRequest {
+		_, err := client.Write([]byte(`{"version":"1.1","host":"h","short_message":"` + message + `"}`))
+		require.NoError(t, err)
+		select {
+		case req := <-ingester.received:
+			return req
+		case <-time.After(5 * time.Second):
+			t.Fatal("GELF message was not ingested")
+			return models.LogRequest{}
+		}
+	}
+
+	// First chunks of messages whose other chunks never arrive
+	for id := uint32(0); id < 4096; id++ {
+		_, err := client.Write(chunk(id, 0, 128, []byte("x")))
+		require.NoError(t, err)
+		if id%64 == 63 {
+			assert.Equal(t, "barrier", received("barrier").Message)
+		}
+	}
+
+	// Once full, chunks of new messages are dropped
+	_, err = client.Write(chunk(5000, 0, 1, []byte(`{"version":"1.1","host":"h","short_message":"chunked"}`)))
+	require.NoError(t, err)
+	assert.Equal(t, "after", received("after").Message)
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/health"
+	"github.com/yunjin08/logscale/models"
+)
+
+// schemaRow answers the schema version query
+type schemaRow struct {
+	version int64
+	dirty   bool
+	err     error
+}
+
+func (r schemaRow) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return r
+}
+
+func (r schemaRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*int64) = r.version
+	*dest[1].(*bool) = r.dirty
+	return nil
+}
+
+func passing(ctx context.Context) error { return nil }
+
+func failing(ctx context.Context) error { return errors.New("down") }
+
+func TestHealthCheckerStatuses(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(c *health.Checker)
+		wantStatus string
+		wantCode   int
+	}{
+		{"all passing", func(c *health.Checker) {
+			c.Critical("postgres", passing)
+			c.Informational("stream_lag", passing)
+		}, health.StatusHealthy, http.StatusOK},
+		{"informational failure", func(c *health.Checker) {
+			c.Critical("postgres", passing)
+			c.Informational("stream_lag", failing)
+		}, health.StatusDegraded, http.StatusOK},
+		{"critical failure", func(c *health.Checker) {
+			c.Informational("stream_lag", failing)
+			c.Critical("postgres", failing)
+		}, health.StatusUnhealthy, http.StatusServiceUnavailable},
+		{"no checks", func(c *health.Checker) {}, health.StatusHealthy, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			tt.setup(checker)
+			resp := checker.Run(context.Background())
+			assert.Equal(t, tt.wantStatus, resp.Status)
+			assert.Equal(t, tt.wantCode, health.StatusCode(resp))
+		})
+	}
+}
+
+func TestHealthCheckerReportsEachCheck(t *testing.T) {
+	checker := health.NewChecker(50 * time.Millisecond)
+	checker.Critical("postgres", passing)
+	checker.Critical("redis", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	resp := checker.Run(context.Background())
+	require.Len(t, resp.Checks, 2)
+
+	assert.Equal(t, "postgres", resp.Checks[0].Name)
+	assert.Equal(t, health.StatusHealthy, resp.Checks[0].Status)
+	assert.True(t, resp.Checks[0].Critical)
+	assert.Empty(t, resp.Checks[0].Error)
+
+	// Hanging checks end at the timeout
+	assert.Equal(t, "redis", resp.Checks[1].Name)
+	assert.Equal(t, health.StatusUnhealthy, resp.Checks[1].Status)
+	assert.Contains(t, resp.Checks[1].Error, "deadline exceeded")
+	assert.GreaterOrEqual(t, resp.Checks[1].LatencyMS, float64(50))
+}
+
+func TestHealthSchemaCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		row     schemaRow
+		wantErr string
+	}{
+		{"current", schemaRow{version: 9}, ""},
+		{"newer", schemaRow{version: 10}, ""},
+		{"behind", schemaRow{version: 8}, "behind 9"},
+		{"dirty", schemaRow{version: 9, dirty: true}, "dirty"},
+		{"never migrated", schemaRow{err: pgx.ErrNoRows}, "no migrations applied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := health.Schema(tt.row, 9)(context.Background())
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestHealthSchemaVersionMatchesMigrations(t *testing.T) {
+	entries, err := os.ReadDir("../db/migrations")
+	require.NoError(t, err)
+
+	var latest int64
+	for _, e := range entries {
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		require.NoError(t, err, e.Name())
+		latest = max(latest, version)
+	}
+	assert.Equal(t, latest, int64(health.SchemaVersion), "bump health.SchemaVersion with new migrations")
+}
+
+func TestHealthBacklogAndHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	backlog := int64(5)
+	pending := func() int64 { return backlog }
+
+	assert.NoError(t, health.Backlog("logs", pending, 5)(ctx))
+	backlog = 6
+	assert.ErrorContains(t, health.Backlog("logs", pending, 5)(ctx), "6 logs pending")
+
+	last := time.Now()
+	progress := func() time.Time { return last }
+	assert.NoError(t, health.Heartbeat("loop", progress, time.Minute)(ctx))
+	last = time.Now().Add(-2 * time.Minute)
+	assert.ErrorContains(t, health.Heartbeat("loop", progress, time.Minute)(ctx), "no progress")
+}
+
+func TestHealthHandler(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Critical("redis", failing)
+
+	w := httptest.NewRecorder()
+	health.Handler(checker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var resp models.HealthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, health.StatusUnhealthy, resp.Status)
+	require.Len(t, resp.Checks, 1)
+	assert.Equal(t, "down", resp.Checks[0].Error)
+}
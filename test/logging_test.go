@@ -0,0 +1,159 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/logging"
+	"github.com/yunjin08/logscale/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureLogs installs a JSON logger writing to a buffer at level
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		_ = logging.SetLevel("info")
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, logging.Setup(&buf, "logscale-test", logging.Config{Level: level, Format: logging.FormatJSON}))
+	return &buf
+}
+
+// logRecords decodes the JSON records written to buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggingConfigValidate(t *testing.T) {
+	assert.NoError(t, logging.Config{}.Validate())
+	assert.NoError(t, logging.Config{Level: "debug", Format: logging.FormatText}.Validate())
+	assert.ErrorContains(t, logging.Config{Level: "verbose"}.Validate(), "unknown level")
+	assert.ErrorContains(t, logging.Config{Format: "xml"}.Validate(), "unknown format")
+}
+
+func TestLoggingContextFields(t *testing.T) {
+	buf := captureLogs(t, "info")
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = logging.WithRequestID(ctx, "req-1")
+
+	slog.InfoContext(ctx, "stored logs", logging.KeyCount, 3)
+
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "stored logs", records[0]["msg"])
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "logscale-test", records[0]["app"])
+	assert.Equal(t, "req-1", records[0][logging.KeyRequestID])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", records[0][logging.KeyTraceID])
+	assert.Equal(t, "00f067aa0ba902b7", records[0][logging.KeySpanID])
+	assert.Equal(t, float64(3), records[0][logging.KeyCount])
+}
+
+func TestLoggingLevel(t *testing.T) {
+	buf := captureLogs(t, "warn")
+
+	slog.Info("dropped")
+	slog.Warn("kept")
+	require.NoError(t, logging.SetLevel("debug"))
+	slog.Debug("kept after reload")
+	assert.Error(t, logging.SetLevel("loud"))
+
+	records := logRecords(t, buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "kept", records[0]["msg"])
+	assert.Equal(t, "kept after reload", records[1]["msg"])
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID())
+
+	var seen string
+	r.GET("/v1/logs", func(c *gin.Context) {
+		seen = logging.RequestID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"caller ID", "abc-123.x_y", true},
+		{"missing", "", false},
+		{"invalid characters", "abc\n{\"admin\":true}", false},
+		{"too long", string(bytes.Repeat([]byte("a"), 65)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/logs", nil)
+			if tt.header != "" {
+				req.Header.Set(middleware.RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(middleware.RequestIDHeader)
+			assert.Equal(t, id, seen)
+			if tt.keep {
+				assert.Equal(t, tt.header, id)
+			} else {
+				assert.Len(t, id, 16)
+			}
+		})
+	}
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	buf := captureLogs(t, "info")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.AccessLog("/livez"))
+	r.GET("/livez", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/v1/logs/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/v1/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	for _, path := range []string{"/livez", "/v1/logs/7", "/v1/fail"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// The probe is logged at debug level and so filtered out
+	records := logRecords(t, buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "/v1/logs/7", records[0]["path"])
+	assert.Equal(t, "/v1/logs/:id", records[0]["route"])
+	assert.Equal(t, float64(http.StatusOK), records[0]["status"])
+	assert.NotEmpty(t, records[0][logging.KeyRequestID])
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), records[1]["status"])
+}
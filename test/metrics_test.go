@@ -0,0 +1,162 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/metrics"
+	"github.com/yunjin08/logscale/internal/pipeline"
+	"github.com/yunjin08/logscale/middleware"
+	"github.com/yunjin08/logscale/models"
+)
+
+func TestMetricsMiddlewareLabelsRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Metrics())
+	r.GET("/v1/logs/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	matched := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/v1/logs/:id", "204")
+	unmatched := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "unmatched", "404")
+	matchedBefore := testutil.ToFloat64(matched)
+	unmatchedBefore := testutil.ToFloat64(unmatched)
+
+	// Path parameters share the route's label
+	for _, path := range []string{"/v1/logs/1", "/v1/logs/2", "/nope/1", "/nope/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Equal(t, matchedBefore+2, testutil.ToFloat64(matched))
+	assert.Equal(t, unmatchedBefore+2, testutil.ToFloat64(unmatched))
+
+	// Made-up methods share one label
+	other := metrics.HTTPRequests.WithLabelValues("other", "unmatched", "404")
+	otherBefore := testutil.ToFloat64(other)
+	for _, method := range []string{"PURGE", "X-RANDOM-1", "X-RANDOM-2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/v1/logs/1", nil))
+	}
+	assert.Equal(t, otherBefore+3, testutil.ToFloat64(other))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	for _, name := range []string{
+		"logscale_http_requests_total",
+		"logscale_http_request_duration_seconds",
+		"go_goroutines",
+	} {
+		assert.Contains(t, string(body), name)
+	}
+}
+
+func TestPipelineProcessorErrorsCounted(t *testing.T) {
+	runner, err := pipeline.New([]pipeline.Config{{
+		Name: "rename-user",
+		Processors: []pipeline.ProcessorConfig{
+			{Rename: &pipeline.RenameConfig{From: "meta.user", To: "meta.account"}},
+		},
+	}})
+	require.NoError(t, err)
+
+	failures := metrics.ProcessorErrors.WithLabelValues("rename-user", "0:rename")
+	before := testutil.ToFloat64(failures)
+
+	// Meta that is not an object fails the rename
+	req := models.LogRequest{Service: "api", Level: "info", Message: "m", Meta: json.RawMessage(`[1]`)}
+	_, err = runner.Process(&req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rename-user/0:rename")
+	assert.Equal(t, before+1, testutil.ToFloat64(failures))
+
+	// Dry runs do not count
+	results := runner.DryRun([]models.LogRequest{req})
+	require.NotEmpty(t, results[0].Error)
+	assert.Equal(t, before+1, testutil.ToFloat64(failures))
+}
+
+func TestServiceCollectorFoldsServicesBeyondLimit(t *testing.T) {
+	last := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	services := []models.ServiceMetrics{
+		{Service: "small", TotalLogs: 1, InfoCount: 1, LastLogTime: last},
+		{Service: "api", TotalLogs: 10, ErrorCount: 2, InfoCount: 7, ErrorRate: 0.2, LastLogTime: last},
+		{Service: "tiny", TotalLogs: 1, FatalCount: 1, LastLogTime: last.Add(time.Second)},
+	}
+	collector := metrics.NewServiceCollector(services, 1)
+
+	expected := `
+# HELP logscale_service_error_rate Share of a service's logs at error or fatal level.
+# TYPE logscale_service_error_rate gauge
+logscale_service_error_rate{service="__other__"} 0.5
+logscale_service_error_rate{service="api"} 0.2
+# HELP logscale_service_logs_total Logs stored per service and level.
+# TYPE logscale_service_logs_total counter
+logscale_service_logs_total{level="debug",service="__other__"} 0
+logscale_service_logs_total{level="debug",service="api"} 0
+logscale_service_logs_total{level="error",service="__other__"} 0
+logscale_service_logs_total{level="error",service="api"} 2
+logscale_service_logs_total{level="fatal",service="__other__"} 1
+logscale_service_logs_total{level="fatal",service="api"} 0
+logscale_service_logs_total{level="info",service="__other__"} 1
+logscale_service_logs_total{level="info",service="api"} 7
+logscale_service_logs_total{level="trace",service="__other__"} 0
+logscale_service_logs_total{level="trace",service="api"} 0
+logscale_service_logs_total{level="unknown",service="api"} 1
+logscale_service_logs_total{level="warn",service="__other__"} 0
+logscale_service_logs_total{level="warn",service="api"} 0
+# HELP logscale_service_metrics_folded_services Services reported together as __other__: those beyond the series limit and any named __other__.
+# TYPE logscale_service_metrics_folded_services gauge
+logscale_service_metrics_folded_services 2
+# HELP logscale_service_metrics_services Services with metrics, including those folded into the other series.
+# TYPE logscale_service_metrics_services gauge
+logscale_service_metrics_services 3
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"logscale_service_error_rate", "logscale_service_logs_total",
+		"logscale_service_metrics_folded_services", "logscale_service_metrics_services"))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "logscale_service_last_log_timestamp_seconds"))
+}
+
+func TestServiceCollectorMergesServiceNamedOther(t *testing.T) {
+	services := []models.ServiceMetrics{
+		{Service: metrics.OtherServices, TotalLogs: 20, InfoCount: 20},
+		{Service: "api", TotalLogs: 10, InfoCount: 10},
+		{Service: "small", TotalLogs: 1, ErrorCount: 1},
+	}
+
+	// A duplicate series would fail the whole scrape
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(metrics.NewServiceCollector(services, 1)))
+	_, err := registry.Gather()
+	require.NoError(t, err)
+
+	expected := `
+# HELP logscale_service_logs_total Logs stored per service and level.
+# TYPE logscale_service_logs_total counter
+logscale_service_logs_total{level="debug",service="__other__"} 0
+logscale_service_logs_total{level="debug",service="api"} 0
+logscale_service_logs_total{level="error",service="__other__"} 1
+logscale_service_logs_total{level="error",service="api"} 0
+logscale_service_logs_total{level="fatal",service="__other__"} 0
+logscale_service_logs_total{level="fatal",service="api"} 0
+logscale_service_logs_total{level="info",service="__other__"} 20
+logscale_service_logs_total{level="info",service="api"} 10
+logscale_service_logs_total{level="trace",service="__other__"} 0
+logscale_service_logs_total{level="trace",service="api"} 0
+logscale_service_logs_total{level="warn",service="__other__"} 0
+logscale_service_logs_total{level="warn",service="api"} 0
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "logscale_service_logs_total"))
+}
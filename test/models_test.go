@@ -2,6 +2,7 @@ package test
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -186,3 +187,40 @@ func TestHealthResponse(t *testing.T) {
 	assert.Equal(t, response.Status, unmarshaledResponse.Status)
 	assert.Equal(t, response.Timestamp, unmarshaledResponse.Timestamp)
 }
+
+func TestNormalizeLevel(t *testing.T) {
+	tests := map[string]string{
+		"ERROR":     "error",
+		"err":       "error",
+		"Warning":   "warn",
+		" info ":    "info",
+		"notice":    "info",
+		"critical":  "fatal",
+		"FATAL":     "fatal",
+		"panic":     "fatal",
+		"TRACE":     "trace",
+		"verbose":   "trace",
+		"debug":     "debug",
+		"emergency": "fatal",
+	}
+
+	for input, expected := range tests {
+		level, ok := models.NormalizeLevel(input)
+		assert.True(t, ok, input)
+		assert.Equal(t, expected, level, input)
+	}
+
+	_, ok := models.NormalizeLevel("loud")
+	assert.False(t, ok)
+	_, ok = models.NormalizeLevel("")
+	assert.False(t, ok)
+}
+
+func TestValidateTenantID(t *testing.T) {
+	for _, id := range []string{"default", "billing", "team-42", "bu_eu"} {
+		assert.NoError(t, models.ValidateTenantID(id), id)
+	}
+	for _, id := range []string{"", "Billing", "-team", "a b", "x/y", strings.Repeat("a", 65)} {
+		assert.Error(t, models.ValidateTenantID(id), id)
+	}
+}
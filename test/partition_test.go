@@ -0,0 +1,62 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/internal/partition"
+)
+
+func TestPartitionNames(t *testing.T) {
+	// Partitions follow UTC days whatever the zone of the time
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	late := time.Date(2026, 1, 17, 22, 30, 0, 0, zone)
+	assert.Equal(t, time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC), partition.Day(late))
+	assert.Equal(t, "logs_p20260118", partition.Name(late))
+
+	day, ok := partition.ParseName("logs_p20260118")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC), day)
+
+	for _, name := range []string{partition.DefaultPartition, "logs_p2026011", "logs_p20261318", "audit_p20260118"} {
+		_, ok := partition.ParseName(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestPartitionDays(t *testing.T) {
+	days := partition.Days(time.Date(2026, 2, 27, 15, 0, 0, 0, time.UTC), 2)
+	require.Len(t, days, 3)
+	assert.Equal(t, "logs_p20260227", partition.Name(days[0]))
+	assert.Equal(t, "logs_p20260228", partition.Name(days[1]))
+	assert.Equal(t, "logs_p20260301", partition.Name(days[2]))
+}
+
+func TestGetLogsTimeRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v1/logs", v1.NewLogHandler(nil, nil, nil, time.Hour).GetLogs)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"invalid start", "start_time=yesterday"},
+		{"invalid end", "end_time=2026-13-01"},
+		{"start after end", "start_time=2026-01-02T00:00:00Z&end_time=2026-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/logs?"+tt.query, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
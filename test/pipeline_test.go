@@ -0,0 +1,272 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/internal/config"
+	"github.com/yunjin08/logscale/internal/pipeline"
+	"github.com/yunjin08/logscale/models"
+)
+
+const nginxPipeline = `
+pipelines:
+  - name: nginx-access
+    services: ["nginx*"]
+    processors:
+      - grok:
+          pattern: '%{IPORHOST:client} - %{NOTSPACE:user} \[%{HTTPDATE:time}\] "%{WORD:method} %{URIPATHPARAM:path} [^"]*" %{INT:status:int} %{INT:bytes:int}'
+      - drop: {}
+        if: {field: meta.path, equals: /healthz}
+      - enrich:
+          fields: {meta.source: nginx, meta.env: prod}
+`
+
+func loadPipelines(t *testing.T, yamlConfig string) *pipeline.Runner {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yamlConfig), 0o600))
+
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+	runner, err := pipeline.New(cfg.Pipelines)
+	require.NoError(t, err)
+	return runner
+}
+
+func decodeMeta(t *testing.T, raw json.RawMessage) map[string]interface{} {
+	t.Helper()
+	var meta map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &meta))
+	return meta
+}
+
+func TestPipelineGrokEnrichAndDrop(t *testing.T) {
+	runner := loadPipelines(t, nginxPipeline)
+
+	req := models.LogRequest{
+		Service: "nginx",
+		Level:   "info",
+		Message: `10.0.0.1 - - [15/Jan/2024:10:30:00 +0000] "GET /api/users?page=2 HTTP/1.1" 200 512`,
+		Meta:    json.RawMessage(`{"env":"staging"}`),
+	}
+	keep, err := runner.Process(&req)
+	require.NoError(t, err)
+	assert.True(t, keep)
+
+	meta := decodeMeta(t, req.Meta)
+	assert.Equal(t, "10.0.0.1", meta["client"])
+	assert.Equal(t, "GET", meta["method"])
+	assert.Equal(t, "/api/users?page=2", meta["path"])
+	assert.Equal(t, float64(200), meta["status"])
+	assert.Equal(t, float64(512), meta["bytes"])
+	assert.Equal(t, "nginx", meta["source"])
+	assert.Equal(t, "staging", meta["env"], "enrich keeps existing values")
+
+	health := models.LogRequest{
+		Service: "nginx-edge",
+		Level:   "info",
+		Message: `10.0.0.1 - - [15/Jan/2024:10:30:00 +0000] "GET /healthz HTTP/1.1" 200 2`,
+	}
+	keep, err = runner.Process(&health)
+	require.NoError(t, err)
+	assert.False(t, keep)
+
+	other := models.LogRequest{Service: "api", Level: "info", Message: "unrelated"}
+	keep, err = runner.Process(&other)
+	require.NoError(t, err)
+	assert.True(t, keep)
+	assert.Nil(t, other.Meta, "pipelines of other services do not run")
+}
+
+func TestPipelineJSONRenameRemove(t *testing.T) {
+	runner, err := pipeline.New([]pipeline.Config{{
+		Name: "json-apps",
+		Processors: []pipeline.ProcessorConfig{
+			{JSON: &pipeline.JSONConfig{}},
+			{Rename: &pipeline.RenameConfig{From: "meta.msg", To: "message"}},
+			{Rename: &pipeline.RenameConfig{From: "meta.severity", To: "level"}},
+			{Remove: &pipeline.RemoveConfig{Fields: []string{"meta.password", "meta.user.token"}}},
+			{Regex: &pipeline.RegexConfig{Field: "message", Pattern: `order (?P<order_id>\d+)`}},
+		},
+	}})
+	require.NoError(t, err)
+
+	req := models.LogRequest{
+		Service: "api",
+		Level:   "info",
+		Message: `{"msg":"order 42 paid","severity":"warning","password":"hunter2","user":{"id":7,"token":"t"},"amount":12.5}`,
+	}
+	keep, err := runner.Process(&req)
+	require.NoError(t, err)
+	require.True(t, keep)
+
+	assert.Equal(t, "order 42 paid", req.Message)
+	assert.Equal(t, "warning", req.Level)
+	meta := decodeMeta(t, req.Meta)
+	assert.Equal(t, map[string]interface{}{
+		"user":     map[string]interface{}{"id": float64(7)},
+		"amount":   12.5,
+		"order_id": "42",
+	}, meta)
+
+	// Messages that are not JSON pass through untouched
+	plain := models.LogRequest{Service: "api", Level: "info", Message: "not json"}
+	keep, err = runner.Process(&plain)
+	require.NoError(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, "not json", plain.Message)
+	assert.Nil(t, plain.Meta)
+}
+
+func TestPipelineConditions(t *testing.T) {
+	yes := true
+	runner, err := pipeline.New([]pipeline.Config{{
+		Processors: []pipeline.ProcessorConfig{
+			{Drop: &pipeline.DropConfig{}, If: &pipeline.Condition{Field: "level", In: []interface{}{"debug", "trace"}}},
+			{Drop: &pipeline.DropConfig{}, If: &pipeline.Condition{Field: "message", Matches: `^GET /metrics`}},
+			{Enrich: &pipeline.EnrichConfig{Fields: map[string]interface{}{"meta.traced": true}}, If: &pipeline.Condition{Field: "meta.trace_id", Exists: &yes}},
+		},
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		req  models.LogRequest
+		keep bool
+	}{
+		{"debug dropped", models.LogRequest{Service: "a", Level: "debug", Message: "x"}, false},
+		{"metrics scrape dropped", models.LogRequest{Service: "a", Level: "info", Message: "GET /metrics 200"}, false},
+		{"kept", models.LogRequest{Service: "a", Level: "info", Message: "GET /users 200"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, err := runner.Process(&tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.keep, keep)
+		})
+	}
+
+	traced := models.LogRequest{Service: "a", Level: "info", Message: "x", Meta: json.RawMessage(`{"trace_id":"abc"}`)}
+	_, err = runner.Process(&traced)
+	require.NoError(t, err)
+	assert.Equal(t, true, decodeMeta(t, traced.Meta)["traced"])
+
+	invalidMeta := models.LogRequest{Service: "a", Level: "info", Message: "x", Meta: json.RawMessage(`[1,2]`)}
+	_, err = runner.Process(&invalidMeta)
+	assert.Error(t, err)
+}
+
+func TestPipelineInvalidConfig(t *testing.T) {
+	invalid := []pipeline.ProcessorConfig{
+		{},
+		{Grok: &pipeline.GrokConfig{Pattern: "%{NOPE:x}"}},
+		{Grok: &pipeline.GrokConfig{Pattern: "%{WORD}"}},
+		{Regex: &pipeline.RegexConfig{Pattern: "("}},
+		{Rename: &pipeline.RenameConfig{From: "message", To: "meta.raw"}},
+		{Remove: &pipeline.RemoveConfig{Fields: []string{"service"}}},
+		{Enrich: &pipeline.EnrichConfig{Fields: map[string]interface{}{"env": "prod"}}},
+		{Drop: &pipeline.DropConfig{}, If: &pipeline.Condition{Field: "level"}},
+		{Drop: &pipeline.DropConfig{}, Enrich: &pipeline.EnrichConfig{Fields: map[string]interface{}{"meta.a": 1}}},
+		// Nesting stays shallow, but every level doubles the expansion
+		{Grok: &pipeline.GrokConfig{Pattern: "%{P0:x}", Patterns: doublingGrokPatterns(14)}},
+	}
+	for _, pc := range invalid {
+		_, err := pipeline.New([]pipeline.Config{{Processors: []pipeline.ProcessorConfig{pc}}})
+		assert.Error(t, err, "%+v", pc)
+	}
+	// The shipped config must stay valid
+	cfg, err := config.LoadFile("../config/config.yaml")
+	require.NoError(t, err)
+	_, err = pipeline.New(cfg.Pipelines)
+	assert.NoError(t, err)
+}
+
+// doublingGrokPatterns returns patterns P0 to Pn where each references the
+// next twice, so P0 expands to 2^n copies of Pn
+func doublingGrokPatterns(n int) map[string]string {
+	patterns := map[string]string{fmt.Sprintf("P%d", n): "[a-z]+"}
+	for i := 0; i < n; i++ {
+		patterns[fmt.Sprintf("P%d", i)] = fmt.Sprintf("%%{P%d}%%{P%d}", i+1, i+1)
+	}
+	return patterns
+}
+
+func TestPipelineDryRunEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/v1/pipelines/dry-run", v1.NewPipelineHandler(loadPipelines(t, nginxPipeline)).DryRun)
+
+	post := func(body interface{}) (*httptest.ResponseRecorder, []pipeline.Result) {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/pipelines/dry-run", bytes.NewReader(data)))
+
+		var resp struct {
+			Data []pipeline.Result `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp.Data
+	}
+
+	// Configured pipelines
+	w, results := post(gin.H{"logs": []models.LogRequest{
+		{Service: "nginx", Level: "info", Message: `1.2.3.4 - - [15/Jan/2024:10:30:00 +0000] "GET /healthz HTTP/1.1" 200 2`},
+		{Service: "api", Level: "info", Message: "hello"},
+	}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Dropped)
+	assert.Equal(t, "nginx-access/1:drop", results[0].DroppedBy)
+	assert.Equal(t, []string{"nginx-access"}, results[0].Pipelines)
+	assert.Nil(t, results[0].Log)
+	assert.Equal(t, []string{}, results[1].Pipelines)
+	require.NotNil(t, results[1].Log)
+	assert.Equal(t, "hello", results[1].Log.Message)
+
+	// Pipelines from the request
+	w, results = post(gin.H{
+		"pipelines": []pipeline.Config{{Name: "tag", Processors: []pipeline.ProcessorConfig{
+			{Enrich: &pipeline.EnrichConfig{Fields: map[string]interface{}{"meta.team": "core"}}},
+		}}},
+		"logs": []models.LogRequest{{Service: "api", Level: "info", Message: "hello"}},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, results, 1)
+	assert.JSONEq(t, `{"team":"core"}`, string(results[0].Log.Meta))
+
+	// Invalid pipelines are reported
+	w, _ = post(gin.H{
+		"pipelines": []pipeline.Config{{Processors: []pipeline.ProcessorConfig{{}}}},
+		"logs":      []models.LogRequest{{Service: "api", Level: "info", Message: "hello"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// So are patterns that expand too far
+	w, _ = post(gin.H{
+		"pipelines": []pipeline.Config{{Processors: []pipeline.ProcessorConfig{
+			{Grok: &pipeline.GrokConfig{Pattern: "%{P0:x}", Patterns: doublingGrokPatterns(14)}},
+		}}},
+		"logs": []models.LogRequest{{Service: "api", Level: "info", Message: "hello"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "expands to more than")
+
+	// And oversized requests
+	w, _ = post(gin.H{
+		"logs": []models.LogRequest{{Service: "api", Level: "info", Message: strings.Repeat("x", 2<<20)}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
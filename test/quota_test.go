@@ -0,0 +1,234 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/models"
+)
+
+// fakeQuotaStore keeps quotas and today's usage in memory
+type fakeQuotaStore struct {
+	mu      sync.Mutex
+	quotas  []models.Quota
+	lines   map[string]int64
+	bytes   map[string]int64
+	dropped map[string]int64
+	// reserved is the usage counted against each quota's hard limits
+	reserved map[int64][2]int64
+}
+
+func newFakeQuotaStore(quotas ...models.Quota) *fakeQuotaStore {
+	return &fakeQuotaStore{
+		quotas:   quotas,
+		lines:    make(map[string]int64),
+		bytes:    make(map[string]int64),
+		dropped:  make(map[string]int64),
+		reserved: make(map[int64][2]int64),
+	}
+}
+
+func (f *fakeQuotaStore) UpsertQuota(_ context.Context, q models.Quota) (*models.Quota, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.quotas = append(f.quotas, q)
+	return &q, nil
+}
+
+func (f *fakeQuotaStore) ListQuotas(context.Context) ([]models.Quota, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.Quota(nil), f.quotas...), nil
+}
+
+func (f *fakeQuotaStore) DeleteQuota(_ context.Context, tenantID string, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, q := range f.quotas {
+		if q.ID == id && (tenantID == "" || q.TenantID == tenantID) {
+			f.quotas = append(f.quotas[:i], f.quotas[i+1:]...)
+			return nil
+		}
+	}
+	return helpers.ErrNotFound
+}
+
+func (f *fakeQuotaStore) GetDailyUsage(_ context.Context, tenantID, service, _ string) (int64, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if service != "" {
+		return f.lines[tenantID+"/"+service], f.bytes[tenantID+"/"+service], nil
+	}
+	var lines, bytes int64
+	for key, n := range f.lines {
+		if len(key) > len(tenantID) && key[:len(tenantID)+1] == tenantID+"/" {
+			lines += n
+			bytes += f.bytes[key]
+		}
+	}
+	return lines, bytes, nil
+}
+
+func (f *fakeQuotaStore) AddUsage(_ context.Context, tenantID, service, _ string, lines, bytes, dropped int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lines[tenantID+"/"+service] += lines
+	f.bytes[tenantID+"/"+service] += bytes
+	f.dropped[tenantID+"/"+service] += dropped
+	return nil
+}
+
+func (f *fakeQuotaStore) ReserveUsage(_ context.Context, tenantID, day string, reservations []models.QuotaReservation) (*models.Quota, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	next := make(map[int64][2]int64)
+	for _, r := range reservations {
+		used, ok := f.reserved[r.Quota.ID]
+		if !ok {
+			used = [2]int64{f.lines[tenantID+"/"+r.Quota.Service], f.bytes[tenantID+"/"+r.Quota.Service]}
+		}
+		used[0] += r.Lines
+		used[1] += r.Bytes
+		if (r.Quota.HardLines > 0 && used[0] > r.Quota.HardLines) || (r.Quota.HardBytes > 0 && used[1] > r.Quota.HardBytes) {
+			q := r.Quota
+			return &q, nil
+		}
+		next[r.Quota.ID] = used
+	}
+	for id, used := range next {
+		f.reserved[id] = used
+	}
+	return nil, nil
+}
+
+func (f *fakeQuotaStore) QueryUsage(context.Context, string, string, string, string) ([]models.Usage, error) {
+	return nil, nil
+}
+
+func logBatch(service string, n int) []models.LogRequest {
+	reqs := make([]models.LogRequest, n)
+	for i := range reqs {
+		reqs[i] = CreateTestLogRequest(service, "info", "hello")
+	}
+	return reqs
+}
+
+func TestQuotaSoftAndHardReject(t *testing.T) {
+	store := newFakeQuotaStore(models.Quota{
+		ID: 1, TenantID: "default", SoftLines: 5, HardLines: 10, HardAction: models.QuotaActionReject,
+	})
+	svc := quota.NewService(store)
+	ctx := context.Background()
+
+	ingest := func(n int) ([]models.LogRequest, error) {
+		kept, err := svc.Apply(ctx, "default", logBatch("api", n))
+		if err != nil {
+			return nil, err
+		}
+		return svc.Record(ctx, "default", kept), nil
+	}
+
+	events, err := ingest(4)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// Crossing the soft limit emits one warning
+	events, err = ingest(2)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, quota.InternalService, events[0].Service)
+	assert.Equal(t, models.LevelWarn, events[0].Level)
+
+	var meta map[string]interface{}
+	require.NoError(t, json.Unmarshal(events[0].Meta, &meta))
+	assert.Equal(t, "soft", meta["threshold"])
+
+	// Crossing the hard limit emits an error; the batch itself is still stored
+	events, err = ingest(4)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.LevelError, events[0].Level)
+
+	// Once over the hard limit, requests are rejected until midnight UTC
+	_, err = ingest(1)
+	require.ErrorIs(t, err, quota.ErrQuotaExceeded)
+	var exceeded *quota.ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.LessOrEqual(t, exceeded.RetryAfter, 24*time.Hour)
+	assert.Equal(t, int64(1), store.dropped["default/api"])
+	assert.Equal(t, int64(10), store.lines["default/api"])
+
+	// Other tenants are unaffected
+	kept, err := svc.Apply(ctx, "billing", logBatch("api", 50))
+	require.NoError(t, err)
+	assert.Len(t, kept, 50)
+}
+
+func TestQuotaHardSampleForService(t *testing.T) {
+	store := newFakeQuotaStore(models.Quota{
+		ID: 2, TenantID: "default", Service: "noisy", HardBytes: 1, HardAction: models.QuotaActionSample, SampleRate: 0,
+	})
+	svc := quota.NewService(store)
+	ctx := context.Background()
+
+	kept, err := svc.Apply(ctx, "default", logBatch("noisy", 3))
+	require.NoError(t, err)
+	svc.Record(ctx, "default", kept)
+	assert.Len(t, kept, 3)
+
+	// Over the limit, only the quota's service is sampled (rate 0 drops everything)
+	batch := append(logBatch("noisy", 3), logBatch("quiet", 2)...)
+	kept, err = svc.Apply(ctx, "default", batch)
+	require.NoError(t, err)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "quiet", kept[0].Service)
+	assert.Equal(t, int64(3), store.dropped["default/noisy"])
+}
+
+func TestQuotaHardRejectUnderConcurrency(t *testing.T) {
+	store := newFakeQuotaStore(models.Quota{
+		ID: 3, TenantID: "default", HardLines: 10, HardAction: models.QuotaActionReject,
+	})
+	svc := quota.NewService(store)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if kept, err := svc.Apply(ctx, "default", logBatch("api", 1)); err == nil {
+				accepted.Add(int64(len(kept)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Concurrent requests never take the tenant past its hard limit
+	assert.Equal(t, int64(10), accepted.Load())
+	assert.Equal(t, int64(40), store.dropped["default/api"])
+}
+
+func TestQuotaSoftLimitAboveHard(t *testing.T) {
+	svc := quota.NewService(newFakeQuotaStore())
+	ctx := context.Background()
+
+	_, err := svc.SetQuota(ctx, models.Quota{TenantID: "default", SoftLines: 20, HardLines: 10, HardAction: models.QuotaActionReject})
+	assert.ErrorIs(t, err, quota.ErrInvalidQuota)
+	_, err = svc.SetQuota(ctx, models.Quota{TenantID: "default", SoftBytes: 20, HardBytes: 10, HardAction: models.QuotaActionReject})
+	assert.ErrorIs(t, err, quota.ErrInvalidQuota)
+
+	// Without a hard limit the soft limit stands alone
+	_, err = svc.SetQuota(ctx, models.Quota{TenantID: "default", SoftLines: 20, HardAction: models.QuotaActionReject})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/internal/ratelimit"
+	"github.com/yunjin08/logscale/models"
+)
+
+func TestMemoryBackendTakeAll(t *testing.T) {
+	backend := ratelimit.NewMemoryBackend()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Rate: 10, Burst: 5}
+
+	decision, err := backend.TakeAll(ctx, []ratelimit.Take{{Key: "a", Limit: limit, Cost: 5}})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = backend.TakeAll(ctx, []ratelimit.Take{{Key: "a", Limit: limit, Cost: 1}})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Greater(t, decision.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, decision.RetryAfter, 100*time.Millisecond)
+
+	// A denied multi-bucket take charges none of the buckets
+	decision, err = backend.TakeAll(ctx, []ratelimit.Take{
+		{Key: "b", Limit: limit, Cost: 3},
+		{Key: "a", Limit: limit, Cost: 1},
+	})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	decision, err = backend.TakeAll(ctx, []ratelimit.Take{{Key: "b", Limit: limit, Cost: 5}})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestMemoryBackendOversizedBatchGoesIntoDebt(t *testing.T) {
+	backend := ratelimit.NewMemoryBackend()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Rate: 100, Burst: 10}
+
+	decision, err := backend.TakeAll(ctx, []ratelimit.Take{{Key: "k", Limit: limit, Cost: 50}})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = backend.TakeAll(ctx, []ratelimit.Take{{Key: "k", Limit: limit, Cost: 1}})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Greater(t, decision.RetryAfter, 350*time.Millisecond)
+}
+
+func TestLimiterPerServiceAndDropped(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{
+		PerService: ratelimit.Limit{Rate: 0.001, Burst: 2},
+	}, nil)
+	ctx := context.Background()
+
+	noisy := []models.LogRequest{
+		CreateTestLogRequest("crashloop", "error", "boom"),
+		CreateTestLogRequest("crashloop", "error", "boom"),
+	}
+	assert.True(t, limiter.AllowLogs(ctx, "1", "default", noisy).Allowed)
+	assert.False(t, limiter.AllowLogs(ctx, "1", "default", noisy).Allowed)
+
+	// Other services and tenants have their own buckets
+	assert.True(t, limiter.AllowLogs(ctx, "1", "default", []models.LogRequest{CreateTestLogRequest("checkout", "info", "ok")}).Allowed)
+	assert.True(t, limiter.AllowLogs(ctx, "2", "billing", noisy).Allowed)
+
+	assert.False(t, limiter.AllowLogs(ctx, "2", "billing", noisy[:1]).Allowed)
+
+	dropped, err := limiter.Dropped(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []models.DroppedLogs{
+		{TenantID: "billing", Service: "crashloop", Count: 1},
+		{TenantID: "default", Service: "crashloop", Count: 2},
+	}, dropped)
+
+	// Tenant admins only see their own tenant
+	dropped, err = limiter.Dropped(ctx, "billing")
+	require.NoError(t, err)
+	assert.Equal(t, []models.DroppedLogs{{TenantID: "billing", Service: "crashloop", Count: 1}}, dropped)
+}
+
+func TestCreateLogRateLimited(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{
+		PerKey: ratelimit.Limit{Rate: 0.5, Burst: 1},
+	}, nil)
+	// Use up the anonymous key's bucket
+	require.True(t, limiter.AllowLogs(context.Background(), "anonymous", models.DefaultTenant,
+		[]models.LogRequest{CreateTestLogRequest("svc", "info", "first")}).Allowed)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/v1/logs", v1.NewLogHandler(nil, nil, limiter, time.Hour).CreateLog)
+
+	body, err := json.Marshal(gin.H{"log": CreateTestLogRequest("svc", "info", "second")})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/models"
+)
+
+func TestGlobToLike(t *testing.T) {
+	tests := []struct {
+		glob string
+		like string
+	}{
+		{"payments-*", "payments-%"},
+		{"api-?", "api-_"},
+		{"user_service", `user\_service`},
+		{"100%", `100\%`},
+		{`literal\*`, "literal*"},
+		{`back\\slash`, `back\\slash`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			assert.Equal(t, tt.like, models.GlobToLike(tt.glob))
+		})
+	}
+}
+
+func TestAccessPolicyAllowsService(t *testing.T) {
+	var unrestricted *models.AccessPolicy
+	assert.True(t, unrestricted.AllowsService("anything"))
+	assert.Nil(t, unrestricted.ServiceLikePatterns())
+
+	policy := &models.AccessPolicy{ServicePatterns: []string{"payments-*", "auth-?", `exact\*`}}
+	assert.True(t, policy.AllowsService("payments-api"))
+	assert.True(t, policy.AllowsService("payments-"))
+	assert.True(t, policy.AllowsService("auth-1"))
+	assert.True(t, policy.AllowsService("exact*"))
+	assert.False(t, policy.AllowsService("auth-12"))
+	assert.False(t, policy.AllowsService("exactly"))
+	assert.False(t, policy.AllowsService("billing"))
+	assert.Equal(t, []string{"payments-%", "auth-_", "exact*"}, policy.ServiceLikePatterns())
+}
+
+func TestValidateServicePatterns(t *testing.T) {
+	assert.NoError(t, models.ValidateServicePatterns([]string{"api-*", `a\\`}))
+	assert.Error(t, models.ValidateServicePatterns([]string{""}))
+	assert.Error(t, models.ValidateServicePatterns([]string{`trailing\`}))
+}
+
+func TestAccessPolicyRedactMeta(t *testing.T) {
+	meta := json.RawMessage(`{"user_id":"u1","email":"a@example.com","region":"eu"}`)
+
+	var unrestricted *models.AccessPolicy
+	assert.JSONEq(t, string(meta), string(unrestricted.RedactMeta(meta)))
+
+	policy := &models.AccessPolicy{MetaFields: []string{"region"}, MetaMode: models.MetaModeRedact}
+	var got map[string]string
+	require.NoError(t, json.Unmarshal(policy.RedactMeta(meta), &got))
+	assert.Equal(t, map[string]string{
+		"user_id": models.RedactedValue,
+		"email":   models.RedactedValue,
+		"region":  "eu",
+	}, got)
+
+	// An empty allow-list redacts every key, while non-object meta is left alone
+	none := &models.AccessPolicy{MetaFields: []string{}}
+	assert.JSONEq(t, `{"region":"[REDACTED]"}`, string(none.RedactMeta(json.RawMessage(`{"region":"eu"}`))))
+	assert.Equal(t, `"plain"`, string(none.RedactMeta(json.RawMessage(`"plain"`))))
+}
+
+func TestRolePolicyDefaultsToRedact(t *testing.T) {
+	role := models.Role{ServicePatterns: []string{"api-*"}}
+	policy := role.Policy()
+	assert.Equal(t, models.MetaModeRedact, policy.MetaMode)
+	assert.False(t, policy.RestrictsMeta())
+}
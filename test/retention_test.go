@@ -0,0 +1,94 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/config"
+	"github.com/yunjin08/logscale/internal/retention"
+)
+
+const oneDay = 24 * time.Hour
+
+func TestRetentionRulesFromConfig(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfig(t, `
+database: {url: postgres://localhost/logscale}
+retention:
+  enabled: true
+  default_ttl: 720h
+  rules:
+    - service: "audit*"
+      ttl: 8760h
+    - level: DEBUG
+      ttl: 72h
+    - level: error
+      ttl: 2160h
+`))
+	cfg, err := config.Load("test", nil)
+	require.NoError(t, err)
+
+	ret := cfg.Retention
+	assert.Equal(t, "debug", ret.Rules[1].Level)
+
+	tests := []struct {
+		service string
+		level   string
+		ttl     time.Duration
+	}{
+		{"audit-log", "debug", 365 * oneDay},
+		{"checkout", "debug", 3 * oneDay},
+		{"checkout", "error", 90 * oneDay},
+		{"checkout", "info", 30 * oneDay},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ttl, ret.TTL(tt.service, tt.level), "%s %s", tt.service, tt.level)
+	}
+}
+
+func TestRetentionValidate(t *testing.T) {
+	valid := retention.Config{Interval: time.Hour, BatchSize: 100}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name   string
+		config retention.Config
+		want   string
+	}{
+		{"unknown level", retention.Config{Interval: time.Hour, BatchSize: 100, Rules: []retention.Rule{{Level: "loud"}}}, "unknown level"},
+		{"negative ttl", retention.Config{Interval: time.Hour, BatchSize: 100, Rules: []retention.Rule{{TTL: -time.Hour}}}, "ttl"},
+		{"no batch size", retention.Config{Interval: time.Hour}, "batch_size"},
+		{"no interval", retention.Config{BatchSize: 100}, "interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.config.Validate(), tt.want)
+		})
+	}
+}
+
+func TestRetentionDropBefore(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		config retention.Config
+		want   time.Duration
+		drop   bool
+	}{
+		{"longest TTL", retention.Config{DefaultTTL: 30 * oneDay, Rules: []retention.Rule{{Level: "debug", TTL: 3 * oneDay}, {Service: "audit*", TTL: 365 * oneDay}}}, 365 * oneDay, true},
+		{"default kept forever", retention.Config{Rules: []retention.Rule{{Level: "debug", TTL: 3 * oneDay}}}, 0, false},
+		{"rule kept forever", retention.Config{DefaultTTL: 30 * oneDay, Rules: []retention.Rule{{Service: "billing", TTL: 0}}}, 0, false},
+		{"catch-all rule", retention.Config{Rules: []retention.Rule{{Level: "debug", TTL: 3 * oneDay}, {Service: "*", TTL: 7 * oneDay}}}, 7 * oneDay, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, ok := tt.config.DropBefore(now)
+			assert.Equal(t, tt.drop, ok)
+			if tt.drop {
+				assert.Equal(t, now.Add(-tt.want), before)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/internal/ingest"
+	"github.com/yunjin08/logscale/middleware"
+)
+
+// unsized hides a reader's length so requests are sent without Content-Length
+type unsized struct{ io.Reader }
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ingestSvc := ingest.NewService(nil, nil, ingest.Options{})
+	r := gin.New()
+	r.Use(middleware.BodyLimit(64))
+	r.POST("/v1/logs", v1.NewLogHandler(nil, ingestSvc, nil, time.Hour).CreateLog)
+
+	large := `{"log":{"service":"api","level":"info","message":"` + strings.Repeat("x", 100) + `"}}`
+
+	// Declared lengths are rejected before the handler runs
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(large)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Streamed bodies fail when the handler reads past the limit
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", unsized{strings.NewReader(large)})
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Small bodies reach the handler
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDecompressLimitsInflatedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ingestSvc := ingest.NewService(nil, nil, ingest.Options{})
+	r := gin.New()
+	r.Use(middleware.BodyLimit(4096))
+	r.POST("/v1/logs", middleware.Decompress(4096), v1.NewLogHandler(nil, ingestSvc, nil, time.Hour).CreateLog)
+
+	// A few compressed kilobytes inflate well past the limit
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, err := gz.Write([]byte(`{"log":{"service":"api","level":"info","message":"` + strings.Repeat("x", 1<<20) + `"}}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.Less(t, body.Len(), 4096)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", &body)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,376 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/yunjin08/logscale/handlers/v1"
+	"github.com/yunjin08/logscale/helpers"
+	"github.com/yunjin08/logscale/internal/auth"
+	"github.com/yunjin08/logscale/internal/quota"
+	"github.com/yunjin08/logscale/internal/redact"
+	"github.com/yunjin08/logscale/models"
+)
+
+// fakeKeyStore keeps API keys in memory, filtering by tenant like
+// helpers.APIKeyHelper
+type fakeKeyStore struct {
+	mu   sync.Mutex
+	keys []models.APIKey
+}
+
+func (f *fakeKeyStore) find(tenantID string, id int64) *models.APIKey {
+	for i := range f.keys {
+		if f.keys[i].ID == id && (tenantID == "" || f.keys[i].TenantID == tenantID) {
+			return &f.keys[i]
+		}
+	}
+	return nil
+}
+
+func (f *fakeKeyStore) CreateAPIKey(_ context.Context, key models.APIKey, _ string) (*models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key.ID = int64(len(f.keys) + 1)
+	f.keys = append(f.keys, key)
+	return &key, nil
+}
+
+func (f *fakeKeyStore) GetAPIKeyByPrefix(context.Context, string) (*models.APIKey, string, error) {
+	return nil, "", helpers.ErrNotFound
+}
+
+func (f *fakeKeyStore) GetAPIKeyByCertSubject(context.Context, []string) (*models.APIKey, error) {
+	return nil, helpers.ErrNotFound
+}
+
+func (f *fakeKeyStore) GetAPIKey(_ context.Context, tenantID string, id int64) (*models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key := f.find(tenantID, id); key != nil {
+		k := *key
+		return &k, nil
+	}
+	return nil, helpers.ErrNotFound
+}
+
+func (f *fakeKeyStore) ListAPIKeys(_ context.Context, tenantID string) ([]models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := []models.APIKey{}
+	for _, k := range f.keys {
+		if tenantID == "" || k.TenantID == tenantID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeKeyStore) RevokeAPIKey(_ context.Context, tenantID string, id int64) (*models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := f.find(tenantID, id)
+	if key == nil {
+		return nil, helpers.ErrNotFound
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	k := *key
+	return &k, nil
+}
+
+func (f *fakeKeyStore) RotateAPIKey(ctx context.Context, tenantID string, oldID int64, key models.APIKey, keyHash string, _ time.Time) (*models.APIKey, error) {
+	f.mu.Lock()
+	found := f.find(tenantID, oldID) != nil
+	f.mu.Unlock()
+	if !found {
+		return nil, helpers.ErrNotFound
+	}
+	key.RotatedFrom = &oldID
+	return f.CreateAPIKey(ctx, key, keyHash)
+}
+
+func (f *fakeKeyStore) SetAPIKeyRole(_ context.Context, tenantID string, id int64, roleID *int64) (*models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := f.find(tenantID, id)
+	if key == nil {
+		return nil, helpers.ErrNotFound
+	}
+	key.RoleID = roleID
+	k := *key
+	return &k, nil
+}
+
+func (f *fakeKeyStore) TouchAPIKey(context.Context, int64) error { return nil }
+
+// fakeRoleStore keeps roles in memory, filtering by tenant like
+// helpers.RoleHelper
+type fakeRoleStore struct {
+	mu    sync.Mutex
+	roles []models.Role
+}
+
+func (f *fakeRoleStore) find(tenantID string, id int64) int {
+	for i := range f.roles {
+		if f.roles[i].ID == id && (tenantID == "" || f.roles[i].TenantID == tenantID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeRoleStore) CreateRole(_ context.Context, role models.Role) (*models.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	role.ID = int64(len(f.roles) + 1)
+	f.roles = append(f.roles, role)
+	return &role, nil
+}
+
+func (f *fakeRoleStore) UpdateRole(_ context.Context, tenantID string, role models.Role) (*models.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := f.find(tenantID, role.ID)
+	if i < 0 {
+		return nil, helpers.ErrNotFound
+	}
+	role.TenantID = f.roles[i].TenantID
+	f.roles[i] = role
+	return &role, nil
+}
+
+func (f *fakeRoleStore) GetRole(_ context.Context, tenantID string, id int64) (*models.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := f.find(tenantID, id)
+	if i < 0 {
+		return nil, helpers.ErrNotFound
+	}
+	role := f.roles[i]
+	return &role, nil
+}
+
+func (f *fakeRoleStore) ListRoles(_ context.Context, tenantID string) ([]models.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	roles := []models.Role{}
+	for _, r := range f.roles {
+		if tenantID == "" || r.TenantID == tenantID {
+			roles = append(roles, r)
+		}
+	}
+	return roles, nil
+}
+
+func (f *fakeRoleStore) DeleteRole(_ context.Context, tenantID string, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := f.find(tenantID, id)
+	if i < 0 {
+		return helpers.ErrNotFound
+	}
+	f.roles = append(f.roles[:i], f.roles[i+1:]...)
+	return nil
+}
+
+// adminRouter serves the key and role admin endpoints as an admin of tenant
+func adminRouter(keys *fakeKeyStore, roles *fakeRoleStore, tenant string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	svc := auth.NewService(keys, roles, "", true)
+	h := v1.NewAPIKeyHandler(svc)
+	rh := v1.NewRoleHandler(svc)
+
+	r := gin.New()
+	admin := r.Group("/v1/admin", func(c *gin.Context) {
+		auth.SetPrincipal(c, &auth.Principal{KeyID: 100, TenantID: tenant, Name: tenant + "-admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	})
+	admin.POST("/keys", h.CreateKey)
+	admin.GET("/keys", h.ListKeys)
+	admin.POST("/keys/:id/rotate", h.RotateKey)
+	admin.DELETE("/keys/:id", h.RevokeKey)
+	admin.PUT("/keys/:id/role", rh.SetKeyRole)
+	admin.POST("/roles", rh.CreateRole)
+	admin.GET("/roles", rh.ListRoles)
+	admin.PUT("/roles/:id", rh.UpdateRole)
+	admin.DELETE("/roles/:id", rh.DeleteRole)
+	return r
+}
+
+func serve(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestAPIKeyAdminIsScopedToTenant(t *testing.T) {
+	keys, roles := &fakeKeyStore{}, &fakeRoleStore{}
+	payments := adminRouter(keys, roles, "payments")
+	global := adminRouter(keys, roles, models.DefaultTenant)
+
+	// A tenant admin naming another tenant still creates a key of its own
+	w := serve(payments, http.MethodPost, "/v1/admin/keys", `{"name":"shipper","scopes":["logs:write"],"tenant_id":"billing"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "payments", created.Key.TenantID)
+
+	// Admins of the default tenant create keys for any tenant
+	w = serve(global, http.MethodPost, "/v1/admin/keys", `{"name":"billing-shipper","scopes":["logs:write"],"tenant_id":"billing"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var billing models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &billing))
+	assert.Equal(t, "billing", billing.Key.TenantID)
+
+	// Listing only returns the caller's tenant
+	var list struct {
+		Data []models.APIKey `json:"data"`
+	}
+	w = serve(payments, http.MethodGet, "/v1/admin/keys", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "payments", list.Data[0].TenantID)
+
+	w = serve(global, http.MethodGet, "/v1/admin/keys", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Data, 2)
+
+	// Another tenant's key cannot be rotated or revoked
+	billingKey := fmt.Sprintf("/v1/admin/keys/%d", billing.Key.ID)
+	assert.Equal(t, http.StatusNotFound, serve(payments, http.MethodPost, billingKey+"/rotate", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(payments, http.MethodDelete, billingKey, "").Code)
+	key, err := keys.GetAPIKey(context.Background(), "", billing.Key.ID)
+	require.NoError(t, err)
+	assert.Nil(t, key.RevokedAt)
+
+	assert.Equal(t, http.StatusOK, serve(global, http.MethodDelete, billingKey, "").Code)
+	assert.Equal(t, http.StatusCreated, serve(payments, http.MethodPost, fmt.Sprintf("/v1/admin/keys/%d/rotate", created.Key.ID), "").Code)
+}
+
+func TestRoleAdminIsScopedToTenant(t *testing.T) {
+	keys, roles := &fakeKeyStore{}, &fakeRoleStore{}
+	payments := adminRouter(keys, roles, "payments")
+	global := adminRouter(keys, roles, models.DefaultTenant)
+
+	// Roles are created in the caller's tenant, and names only clash within one
+	w := serve(payments, http.MethodPost, "/v1/admin/roles", `{"name":"support","service_patterns":["api-*"],"tenant_id":"billing"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var paymentsRole models.Role
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &paymentsRole))
+	assert.Equal(t, "payments", paymentsRole.TenantID)
+
+	w = serve(global, http.MethodPost, "/v1/admin/roles", `{"name":"support","tenant_id":"billing"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var billingRole models.Role
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &billingRole))
+	assert.Equal(t, "billing", billingRole.TenantID)
+
+	var list struct {
+		Data []models.Role `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(serve(payments, http.MethodGet, "/v1/admin/roles", "").Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, paymentsRole.ID, list.Data[0].ID)
+
+	// Another tenant's role cannot be changed or deleted
+	billingPath := fmt.Sprintf("/v1/admin/roles/%d", billingRole.ID)
+	assert.Equal(t, http.StatusNotFound, serve(payments, http.MethodPut, billingPath, `{"name":"mine"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(payments, http.MethodDelete, billingPath, "").Code)
+
+	// Keys can only be given roles of their own tenant
+	w = serve(payments, http.MethodPost, "/v1/admin/keys", fmt.Sprintf(`{"name":"reader","scopes":["logs:read"],"role_id":%d}`, billingRole.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(payments, http.MethodPost, "/v1/admin/keys", `{"name":"reader","scopes":["logs:read"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var reader models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reader))
+
+	readerRole := fmt.Sprintf("/v1/admin/keys/%d/role", reader.Key.ID)
+	assert.Equal(t, http.StatusBadRequest, serve(payments, http.MethodPut, readerRole, fmt.Sprintf(`{"role_id":%d}`, billingRole.ID)).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(global, http.MethodPut, readerRole, fmt.Sprintf(`{"role_id":%d}`, billingRole.ID)).Code)
+	assert.Equal(t, http.StatusOK, serve(payments, http.MethodPut, readerRole, fmt.Sprintf(`{"role_id":%d}`, paymentsRole.ID)).Code)
+
+	// Nor can another tenant's key be given a role
+	w = serve(global, http.MethodPost, "/v1/admin/keys", `{"name":"billing-reader","scopes":["logs:read"],"tenant_id":"billing"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var billingKey models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &billingKey))
+	assert.Equal(t, http.StatusNotFound, serve(payments, http.MethodPut, fmt.Sprintf("/v1/admin/keys/%d/role", billingKey.Key.ID), `{"role_id":null}`).Code)
+}
+
+func TestQuotaAdminIsScopedToTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newFakeQuotaStore(models.Quota{ID: 1, TenantID: "billing", HardLines: 100, HardAction: models.QuotaActionReject})
+	h := v1.NewQuotaHandler(quota.NewService(store))
+
+	router := func(tenant string) *gin.Engine {
+		r := gin.New()
+		admin := r.Group("/v1/admin", func(c *gin.Context) {
+			auth.SetPrincipal(c, &auth.Principal{TenantID: tenant, Scopes: []auth.Scope{auth.ScopeAdmin}})
+		})
+		admin.PUT("/quotas", h.SetQuota)
+		admin.GET("/quotas", h.ListQuotas)
+		admin.DELETE("/quotas/:id", h.DeleteQuota)
+		return r
+	}
+	payments, global := router("payments"), router(models.DefaultTenant)
+
+	w := serve(payments, http.MethodPut, "/v1/admin/quotas", `{"tenant_id":"billing","hard_lines":1}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var saved models.Quota
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	assert.Equal(t, "payments", saved.TenantID)
+
+	var list struct {
+		Data []models.Quota `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(serve(payments, http.MethodGet, "/v1/admin/quotas", "").Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "payments", list.Data[0].TenantID)
+	require.NoError(t, json.Unmarshal(serve(global, http.MethodGet, "/v1/admin/quotas", "").Body.Bytes(), &list))
+	assert.Len(t, list.Data, 2)
+
+	assert.Equal(t, http.StatusNotFound, serve(payments, http.MethodDelete, "/v1/admin/quotas/1", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve(global, http.MethodDelete, "/v1/admin/quotas/1", "").Code)
+}
+
+func TestRedactionCountsAreScopedToTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	redactor, err := redact.New(redact.Config{Enabled: true})
+	require.NoError(t, err)
+	for _, tenant := range []string{"billing", "payments"} {
+		req := models.LogRequest{Service: tenant + "-api", Level: "info", Message: "signup from jane@example.com"}
+		require.NoError(t, redactor.Apply(tenant, &req))
+	}
+	h := v1.NewRedactionHandler(redactor)
+
+	counts := func(tenant string) []models.RedactionCount {
+		r := gin.New()
+		r.GET("/v1/admin/redactions", func(c *gin.Context) {
+			auth.SetPrincipal(c, &auth.Principal{TenantID: tenant, Scopes: []auth.Scope{auth.ScopeAdmin}})
+		}, h.Counts)
+		w := serve(r, http.MethodGet, "/v1/admin/redactions", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data []models.RedactionCount `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	billing := counts("billing")
+	require.Len(t, billing, 1)
+	assert.Equal(t, "billing-api", billing[0].Service)
+	assert.Len(t, counts(models.DefaultTenant), 2)
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yunjin08/logscale/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestTracingConfigValidate(t *testing.T) {
+	assert.NoError(t, tracing.Config{Exporter: tracing.ExporterOTLP, SampleRatio: 0.5}.Validate())
+	assert.NoError(t, tracing.Config{}.Validate())
+	assert.ErrorContains(t, tracing.Config{Exporter: "jaeger"}.Validate(), "unknown exporter")
+	assert.ErrorContains(t, tracing.Config{SampleRatio: 2}.Validate(), "sample_ratio")
+}
+
+func TestTracingStreamPropagation(t *testing.T) {
+	recordSpans(t)
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "publish")
+	values := map[string]interface{}{"id": "1", "service": "api"}
+	tracing.InjectStream(ctx, values)
+	span.End()
+
+	// The trace context travels as a string field next to the event
+	require.IsType(t, "", values["traceparent"])
+	assert.Equal(t, "1", values["id"])
+
+	extracted := trace.SpanContextFromContext(tracing.ExtractStream(context.Background(), values))
+	assert.True(t, extracted.IsRemote())
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+
+	// Events published without trace context start new traces
+	none := trace.SpanContextFromContext(tracing.ExtractStream(context.Background(), map[string]interface{}{"id": "2"}))
+	assert.False(t, none.IsValid())
+}
+
+func TestTracingQueryTracer(t *testing.T) {
+	recorder := recordSpans(t)
+	tracer := tracing.QueryTracer{}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "\n\t\tselect id FROM logs"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "INSERT INTO logs"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("conflict")})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "postgres SELECT", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, "postgres INSERT", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestTracingContinuesCallerTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(otelgin.Middleware("logscale-api"))
+
+	var handled trace.SpanContext
+	r.GET("/v1/logs", func(c *gin.Context) {
+		_, span := tracing.Tracer().Start(c.Request.Context(), "query")
+		handled = span.SpanContext()
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/logs", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", handled.TraceID().String())
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "GET /v1/logs", spans[1].Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+}